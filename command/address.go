@@ -0,0 +1,180 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+const (
+	// defaultLightPort is the port that Elgato accessories serve their HTTP
+	// API on.
+	defaultLightPort = 9123
+
+	// hostLookupTimeout is the maximum time we wait for a hostname to resolve
+	// when deciding whether it is an address or the name of a light.
+	hostLookupTimeout = 2 * time.Second
+)
+
+// lightAddress is a light that was provided by address rather than by name,
+// and can be reached without discovery.
+type lightAddress struct {
+	Host string
+	Port int
+}
+
+// isDirectLightAddress returns whether the provided light requirement is an
+// address that we can use to reach a light, rather than a selector that has to
+// be matched against known lights. Bare IPs, bracketed IPv6 addresses,
+// host:port pairs, URLs, and hostnames that contain a dot, e.g: keylight.local,
+// are addresses. Single label hostnames are indistinguishable from short IDs
+// and display names, and so have to be given with a port or as a URL. Dotted
+// hostnames without a port may still be names, which the resolver decides
+// using the inventory and DNS.
+func isDirectLightAddress(light string) bool {
+	switch {
+	case strings.Contains(light, "://"):
+		return true
+	case strings.HasPrefix(light, "["):
+		end := strings.Index(light, "]")
+		if end < 0 {
+			return false
+		}
+		_, err := netip.ParseAddr(light[1:end])
+		return err == nil
+	}
+
+	if _, err := netip.ParseAddr(light); err == nil {
+		return true
+	}
+
+	if host, port, err := net.SplitHostPort(light); err == nil {
+		_, err := strconv.Atoi(port)
+		return err == nil && host != ""
+	}
+
+	return strings.Contains(light, ".") && isHostname(light)
+}
+
+// isBareHostname returns whether an address that was accepted by
+// isDirectLightAddress is a hostname without a scheme or port, e.g:
+// keylight.local. These may also be the name of a light, e.g: desk.left.
+func isBareHostname(light string) bool {
+	if strings.ContainsAny(light, ":[") {
+		return false
+	}
+	_, err := netip.ParseAddr(light)
+	return err != nil
+}
+
+// parseLightAddress parses an address that was accepted by
+// isDirectLightAddress, defaulting to the Elgato port if none is provided.
+func parseLightAddress(light string) (lightAddress, error) {
+	addr := light
+	if strings.Contains(light, "://") {
+		u, err := url.Parse(light)
+		if err != nil {
+			return lightAddress{}, fmt.Errorf("invalid light address '%s', err: %w", light, err)
+		}
+		if u.Scheme != "http" {
+			return lightAddress{}, fmt.Errorf("invalid light address '%s', only http URLs are supported", light)
+		}
+		addr = u.Host
+	}
+
+	host, port := addr, defaultLightPort
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		host = h
+		port, err = strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return lightAddress{}, fmt.Errorf("invalid light address '%s', invalid port '%s'", light, p)
+		}
+	} else if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		host = addr[1 : len(addr)-1]
+	}
+
+	if _, err := netip.ParseAddr(host); err != nil && !isHostname(host) {
+		return lightAddress{}, fmt.Errorf("invalid light address '%s', '%s' is not an IP address or hostname", light, host)
+	}
+
+	return lightAddress{Host: host, Port: port}, nil
+}
+
+// String returns the host:port pair of the address.
+func (a lightAddress) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// KeyLight returns an unnamed client for the light at the address.
+func (a lightAddress) KeyLight() *keylight.KeyLight {
+	host := a.Host
+	if strings.Contains(host, ":") {
+		// keylight-go does not bracket IPv6 addresses when building URLs.
+		host = "[" + strings.ReplaceAll(host, "%", "%25") + "]"
+	}
+	return &keylight.KeyLight{
+		DNSAddr: host,
+		Port:    a.Port,
+	}
+}
+
+// joinLightAddress returns the host:port pair of a light. The hosts of lights
+// are kept ready for use in URLs, so IPv6 addresses are already bracketed.
+func joinLightAddress(host string, port int) string {
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+// nameDirectLight populates the name of a light that was provided by address
+// from its accessory info, and returns its serial number. Lights that are
+// already known to the inventory keep their mDNS instance name. Otherwise the
+// name is derived from the product name and serial number, and the resolver
+// uses the serial number to avoid controlling the light twice when it is also
+// discovered under its mDNS instance name.
+func nameDirectLight(ctx context.Context, light *keylight.KeyLight, inv *inventory) (string, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, displayNameTimeout)
+	defer cancelFn()
+
+	info, err := light.FetchAccessoryInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if inv != nil && info.SerialNumber != "" {
+		if entry := inv.GetBySerialNumber(info.SerialNumber); entry != nil {
+			light.Name = entry.Name
+			return info.SerialNumber, nil
+		}
+	}
+
+	name := strings.TrimSpace(info.ProductName + " " + info.SerialNumber)
+	light.Name = strings.ReplaceAll(name, " ", `\ `)
+	return info.SerialNumber, nil
+}
+
+// isHostname returns whether s is a syntactically valid hostname.
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,181 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The ranges of values that are accepted by the lights.
+const (
+	minBrightness  = 3
+	maxBrightness  = 100
+	minTemperature = 143
+	maxTemperature = 344
+)
+
+// lightAdjustment is a flag.Value that accepts either an absolute value, e.g:
+// `50`, or a value relative to the current state of the light, e.g: `+10` or
+// `-5`.
+type lightAdjustment struct {
+	// IsSet is true if the flag was provided.
+	IsSet bool
+
+	// Relative is true if Value should be added to the current value rather
+	// than replacing it.
+	Relative bool
+
+	Value int
+}
+
+func (a *lightAdjustment) String() string {
+	if !a.IsSet {
+		return ""
+	}
+	if a.Relative {
+		return fmt.Sprintf("%+d", a.Value)
+	}
+	return strconv.Itoa(a.Value)
+}
+
+func (a *lightAdjustment) Set(value string) error {
+	value = strings.TrimSpace(value)
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("must be a number or a relative change such as +10 or -5")
+	}
+
+	a.IsSet = true
+	a.Relative = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+	a.Value = v
+	return nil
+}
+
+// UnmarshalJSON accepts either a JSON number or a string in the same format as
+// the flag, e.g: `50` or `"+10"`.
+func (a *lightAdjustment) UnmarshalJSON(data []byte) error {
+	return a.Set(unquoteJSONValue(data))
+}
+
+// UnmarshalYAML accepts the same formats as the flag.
+func (a *lightAdjustment) UnmarshalYAML(value *yaml.Node) error {
+	return a.Set(value.Value)
+}
+
+// Validate ensures that an absolute adjustment is within [min, max]. Relative
+// adjustments are clamped when they are applied instead.
+func (a *lightAdjustment) Validate(name string, min, max int) error {
+	if !a.IsSet || a.Relative {
+		return nil
+	}
+
+	if a.Value < min || a.Value > max {
+		return fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+
+	return nil
+}
+
+// Apply returns the result of applying the adjustment to current, clamped to
+// [min, max].
+func (a *lightAdjustment) Apply(current, min, max int) int {
+	if !a.IsSet {
+		return current
+	}
+
+	result := a.Value
+	if a.Relative {
+		result = current + a.Value
+	}
+
+	return clamp(result, min, max)
+}
+
+// lightAdjustments is a flag.Value that accepts a comma separated list of
+// adjustments in the same formats as lightAdjustment, one for each selected
+// light of an accessory, e.g: `20,+10`. A single adjustment applies to every
+// light.
+type lightAdjustments []lightAdjustment
+
+func (a *lightAdjustments) String() string {
+	var values []string
+	for idx := range *a {
+		values = append(values, (*a)[idx].String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (a *lightAdjustments) Set(value string) error {
+	var result lightAdjustments
+	for _, v := range strings.Split(value, ",") {
+		var adj lightAdjustment
+		if err := adj.Set(v); err != nil {
+			return err
+		}
+		result = append(result, adj)
+	}
+	*a = result
+	return nil
+}
+
+// IsSet returns whether the flag was provided.
+func (a lightAdjustments) IsSet() bool {
+	return len(a) != 0
+}
+
+// Validate ensures that every absolute adjustment is within [min, max].
+func (a lightAdjustments) Validate(name string, min, max int) error {
+	for idx := range a {
+		if err := a[idx].Validate(name, min, max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// At returns the adjustment for the idx'th selected light. Lists with several
+// values must have been checked with checkChannelValues.
+func (a lightAdjustments) At(idx int) *lightAdjustment {
+	switch len(a) {
+	case 0:
+		return &lightAdjustment{}
+	case 1:
+		return &a[0]
+	default:
+		return &a[idx]
+	}
+}
+
+// checkChannelValues returns an error if a list of n values, such as the
+// per-light values of set, does not provide a value for each of count lights.
+// A single value applies to every light.
+func checkChannelValues(name string, n, count int) error {
+	if n > 1 && n != count {
+		return fmt.Errorf("%d values were provided for %s, but %d light(s) are selected", n, name, count)
+	}
+	return nil
+}
+
+// unquoteJSONValue returns the contents of a JSON string, or the raw value for
+// any other JSON type.
+func unquoteJSONValue(data []byte) string {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return str
+	}
+	return string(data)
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,670 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	// agentAddrFileName is the name of the file within the config dir that a
+	// running agent advertises its API address in.
+	agentAddrFileName = "agent.addr"
+
+	// agentLightTimeout is the maximum time the agent spends on a single
+	// request to a light, whether polling it or applying a change.
+	agentLightTimeout = 5 * time.Second
+)
+
+// agentLightRecord is the representation of a light in the agent API.
+type agentLightRecord struct {
+	discoveredLightRecord
+
+	LastSeen  time.Time         `json:"last_seen"`
+	Reachable bool              `json:"reachable"`
+	Error     string            `json:"error,omitempty"`
+	Lights    []agentLightState `json:"lights"`
+}
+
+// agentLightState is the cached state of an individual light within an
+// accessory.
+type agentLightState struct {
+	Power       string `json:"power"`
+	Brightness  int    `json:"brightness"`
+	Temperature int    `json:"temperature"`
+	Kelvin      int    `json:"kelvin"`
+}
+
+// agentSwitchRequest is the body of a switch request, where Power is one of
+// `on`, `off` or `toggle`.
+type agentSwitchRequest struct {
+	Power string `json:"power"`
+}
+
+// agentSetRequest is the body of a set request. Values accept the same
+// formats as the flags of the set command, e.g: `50`, `"+10"` or `"4500K"`.
+type agentSetRequest struct {
+	Brightness  lightAdjustment  `json:"brightness"`
+	Temperature lightAdjustment  `json:"temperature"`
+	Kelvin      kelvinAdjustment `json:"kelvin"`
+}
+
+// agentErrorResponse is returned by the agent API for every failed request.
+type agentErrorResponse struct {
+	Error      string   `json:"error"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// agentLight is the state the agent tracks for a single accessory.
+type agentLight struct {
+	light       *keylight.KeyLight
+	displayName string
+	lastSeen    time.Time
+	options     *keylight.KeyLightOptions
+	err         error
+}
+
+func (l *agentLight) record() agentLightRecord {
+	record := agentLightRecord{
+		discoveredLightRecord: newDiscoveredLightRecord(l.light, l.displayName),
+		LastSeen:              l.lastSeen,
+		Reachable:             l.options != nil && l.err == nil,
+		Lights:                []agentLightState{},
+	}
+	if l.err != nil {
+		record.Error = l.err.Error()
+	}
+	if l.options != nil {
+		record.Lights = lightStates(l.options)
+	}
+	return record
+}
+
+// agent continuously discovers lights and caches their state so that it can
+// answer API requests without waiting for discovery.
+type agent struct {
+	UI cli.Ui
+
+	// DiscoveryInterval is the time between the start of each mDNS discovery
+	// run, and DiscoveryTimeout is how long each run listens for. Discovery is
+	// disabled if DiscoveryInterval is zero.
+	DiscoveryInterval time.Duration
+	DiscoveryTimeout  time.Duration
+
+	// PollInterval is the time between refreshes of the cached light state.
+	// Polling is disabled if PollInterval is zero.
+	PollInterval time.Duration
+
+	// OnEvent, if set, is called whenever a light appears, disappears, or
+	// its state changes.
+	OnEvent func(e *lightEvent)
+
+	// lights is keyed by the label of each light.
+	mu     sync.RWMutex
+	lights map[string]*agentLight
+}
+
+// Seed adds the lights from the inventory to the cache, so that lights are
+// available before the first discovery run completes.
+func (a *agent) Seed(inv *inventory) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lights == nil {
+		a.lights = make(map[string]*agentLight)
+	}
+	for _, entry := range inv.Lights {
+		a.lights[entry.Name] = &agentLight{
+			light:       entry.KeyLight(),
+			displayName: entry.DisplayName,
+			lastSeen:    entry.LastSeen,
+		}
+	}
+}
+
+// Run discovers and polls lights until ctx is cancelled.
+func (a *agent) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	if a.DiscoveryInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.every(ctx, a.DiscoveryInterval, a.discover)
+		}()
+	}
+	if a.PollInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.every(ctx, a.PollInterval, a.poll)
+		}()
+	}
+	wg.Wait()
+}
+
+// every calls fn immediately and then every interval until ctx is cancelled.
+func (a *agent) every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover runs a single round of mDNS discovery and records any lights that
+// were found.
+func (a *agent) discover(ctx context.Context) {
+	discovery, err := keylight.NewDiscovery()
+	if err != nil {
+		a.UI.Warn(fmt.Sprintf("Failed to setup discoverer, err: %v", err))
+		return
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, a.DiscoveryTimeout)
+	defer cancelFn()
+
+	discoverer := lightDiscoverer{
+		Discovery: discovery,
+		AllLights: true,
+	}
+	found, err := discoverer.Run(discoveryCtx)
+	if err != nil {
+		a.UI.Warn(fmt.Sprintf("Failed to discover lights, err: %v", err))
+		return
+	}
+
+	for _, light := range found {
+		a.observe(ctx, light)
+	}
+}
+
+// observe records that light was seen at its current address.
+func (a *agent) observe(ctx context.Context, light *keylight.KeyLight) {
+	key := lightLabel(light)
+
+	a.mu.Lock()
+	existing, ok := a.lights[key]
+	if ok {
+		existing.light = light
+		existing.lastSeen = time.Now().UTC()
+	}
+	a.mu.Unlock()
+
+	if ok {
+		return
+	}
+
+	fetchCtx, cancelFn := context.WithTimeout(ctx, agentLightTimeout)
+	defer cancelFn()
+	displayName := fetchDisplayName(fetchCtx, light)
+
+	a.mu.Lock()
+	if a.lights == nil {
+		a.lights = make(map[string]*agentLight)
+	}
+	a.lights[key] = &agentLight{
+		light:       light,
+		displayName: displayName,
+		lastSeen:    time.Now().UTC(),
+	}
+	a.mu.Unlock()
+
+	a.refresh(ctx, key)
+}
+
+// poll refreshes the cached state of every known light.
+func (a *agent) poll(ctx context.Context) {
+	a.mu.RLock()
+	var names []string
+	for name := range a.lights {
+		names = append(names, name)
+	}
+	a.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			a.refresh(ctx, name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+// refresh fetches the current state of the named light into the cache.
+func (a *agent) refresh(ctx context.Context, name string) {
+	a.mu.RLock()
+	entry, ok := a.lights[name]
+	var light *keylight.KeyLight
+	if ok {
+		light = entry.light
+	}
+	a.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	fetchCtx, cancelFn := context.WithTimeout(ctx, agentLightTimeout)
+	defer cancelFn()
+	opts, err := light.FetchLightOptions(fetchCtx)
+
+	a.mu.Lock()
+	events := a.update(entry, opts, err)
+	a.mu.Unlock()
+
+	if a.OnEvent != nil {
+		for _, event := range events {
+			a.OnEvent(event)
+		}
+	}
+}
+
+// update stores the result of polling a light and returns the events that
+// describe how it changed. The caller must hold the lock.
+func (a *agent) update(entry *agentLight, opts *keylight.KeyLightOptions, err error) []*lightEvent {
+	reachable := entry.options != nil && entry.err == nil
+
+	if err != nil {
+		entry.err = err
+		if !reachable {
+			return nil
+		}
+
+		event := newLightEvent(lightEventDisappeared, entry.light, entry.displayName)
+		event.Error = err.Error()
+		return []*lightEvent{event}
+	}
+
+	previous := entry.options
+	entry.options = opts
+	entry.err = nil
+
+	if !reachable {
+		event := newLightEvent(lightEventAppeared, entry.light, entry.displayName)
+		event.State = lightStates(opts)
+		return []*lightEvent{event}
+	}
+
+	return diffLightOptions(entry.light, entry.displayName, previous, opts)
+}
+
+// Records returns the API representation of every known light, sorted by name.
+func (a *agent) Records() []agentLightRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]agentLightRecord, 0, len(a.lights))
+	for _, entry := range a.lights {
+		result = append(result, entry.record())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// lookup returns the name of the single light that matches req. It returns an
+// agentLookupError if no light or several lights match.
+func (a *agent) lookup(req string) (string, error) {
+	selector, err := parseLightSelector(req)
+	if err != nil {
+		return "", &agentLookupError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	matches := a.match(selector)
+	switch len(matches) {
+	case 0:
+		return "", &agentLookupError{Status: http.StatusNotFound, Message: fmt.Sprintf("no light found for '%s'", req)}
+	case 1:
+		return matches[0], nil
+	default:
+		return "", &agentLookupError{
+			Status:     http.StatusConflict,
+			Message:    fmt.Sprintf("'%s' matches %d lights", req, len(matches)),
+			Candidates: matches,
+		}
+	}
+}
+
+// match returns the keys of all lights that match the selector, in sorted
+// order.
+func (a *agent) match(selector *lightSelector) []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var matches []string
+	for key, entry := range a.lights {
+		identity := newLightIdentity(entry.light)
+		identity.DisplayName = entry.displayName
+		if selector.Matches(identity) {
+			matches = append(matches, key)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// Keys returns the keys of all known lights, in sorted order.
+func (a *agent) Keys() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	keys := make([]string, 0, len(a.lights))
+	for key := range a.lights {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Modify applies mutate to every light in the named accessory and refreshes
+// its cached state.
+func (a *agent) Modify(ctx context.Context, name string, mutate func(l *keylight.KeyLightLight)) error {
+	return a.ModifyChannels(ctx, name, nil, mutate)
+}
+
+// ModifyChannels applies mutate to the lights of the named accessory that are
+// selected by channels, or to every light if channels is nil, and refreshes
+// its cached state.
+func (a *agent) ModifyChannels(ctx context.Context, name string, channels []int, mutate func(l *keylight.KeyLightLight)) error {
+	light, _ := a.client(name)
+
+	updateCtx, cancelFn := context.WithTimeout(ctx, agentLightTimeout)
+	defer cancelFn()
+
+	err := modifyLightChannels(updateCtx, light, channels, func(lights []*keylight.KeyLightLight) error {
+		for _, l := range lights {
+			mutate(l)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.refresh(ctx, name)
+	return nil
+}
+
+// client returns the light client and API representation of the named light.
+func (a *agent) client(name string) (*keylight.KeyLight, agentLightRecord) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entry := a.lights[name]
+	return entry.light, entry.record()
+}
+
+// agentLookupError is returned when a light requested through the API can not
+// be resolved to a single light.
+type agentLookupError struct {
+	Status     int
+	Message    string
+	Candidates []string
+}
+
+func (e *agentLookupError) Error() string {
+	return e.Message
+}
+
+// Handler returns the http.Handler that serves the agent API:
+//
+//	GET  /v1/lights                   list all known lights
+//	GET  /v1/lights/<light>           get the state of a light
+//	POST /v1/lights/<light>/switch    {"power": "on" | "off" | "toggle"}
+//	POST /v1/lights/<light>/set       {"brightness": "+10", "kelvin": "4500K"}
+//	POST /v1/scenes/<scene>/apply     apply a saved scene
+//
+// The API is unauthenticated, so requests must be addressed to a loopback host
+// and POST requests must have a JSON body. Web pages can neither send JSON
+// cross-origin without a preflight nor choose the Host header, so this stops
+// them from controlling lights through a no-cors request or DNS rebinding.
+func (a *agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/lights", a.handleLights)
+	mux.HandleFunc("/v1/lights/", a.handleLight)
+	mux.HandleFunc("/v1/scenes/", a.handleScene)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeAgentError(w, http.StatusForbidden, fmt.Errorf("host '%s' is not allowed, the agent only serves loopback addresses", r.Host))
+			return
+		}
+
+		if r.Method == http.MethodPost && !isJSONContentType(r.Header.Get("Content-Type")) {
+			writeAgentError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type must be application/json"))
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost returns whether the Host header of a request refers to the
+// local machine, e.g: localhost:9124 or [::1]:9124.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isJSONContentType returns whether the Content-Type header declares a JSON
+// body.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+func (a *agent) handleLights(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAgentError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	writeAgentJSON(w, http.StatusOK, a.Records())
+}
+
+func (a *agent) handleLight(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/lights/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeAgentError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	name, err := a.lookup(parts[0])
+	if err != nil {
+		writeAgentError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			writeAgentError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		_, record := a.client(name)
+		writeAgentJSON(w, http.StatusOK, record)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeAgentError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var mutate func(l *keylight.KeyLightLight)
+	switch parts[1] {
+	case "switch":
+		mutate, err = decodeAgentSwitch(r)
+	case "set":
+		mutate, err = decodeAgentSet(r)
+	default:
+		writeAgentError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if err != nil {
+		writeAgentError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.Modify(r.Context(), name, mutate); err != nil {
+		writeAgentError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	_, record := a.client(name)
+	writeAgentJSON(w, http.StatusOK, record)
+}
+
+func (a *agent) handleScene(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/scenes/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "apply" {
+		writeAgentError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeAgentError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		writeAgentError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	sc, err := scenes.Get(parts[0])
+	if err != nil {
+		writeAgentError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var names []string
+	for _, sl := range sc.Lights {
+		name, err := a.lookup(sl.Selector())
+		if err != nil {
+			writeAgentError(w, http.StatusNotFound, err)
+			return
+		}
+		names = append(names, name)
+	}
+
+	ctx, cancelFn := context.WithTimeout(r.Context(), agentLightTimeout)
+	defer cancelFn()
+
+	for idx, sl := range sc.Lights {
+		light, _ := a.client(names[idx])
+		if err := sl.Restore(ctx, light); err != nil {
+			writeAgentError(w, http.StatusBadGateway, fmt.Errorf("failed to update light (%s), err: %w", names[idx], err))
+			return
+		}
+	}
+
+	var records []agentLightRecord
+	for _, name := range names {
+		a.refresh(r.Context(), name)
+		_, record := a.client(name)
+		records = append(records, record)
+	}
+	writeAgentJSON(w, http.StatusOK, records)
+}
+
+// decodeAgentSwitch parses the body of a switch request into a mutation.
+func decodeAgentSwitch(r *http.Request) (func(l *keylight.KeyLightLight), error) {
+	var req agentSwitchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to parse request, err: %w", err)
+	}
+
+	switch req.Power {
+	case "on":
+		return func(l *keylight.KeyLightLight) { l.On = 1 }, nil
+	case "off":
+		return func(l *keylight.KeyLightLight) { l.On = 0 }, nil
+	case "toggle":
+		return func(l *keylight.KeyLightLight) { l.On = 1 - l.On }, nil
+	default:
+		return nil, errors.New("power must be 'on', 'off', or 'toggle'")
+	}
+}
+
+// decodeAgentSet parses the body of a set request into a mutation.
+func decodeAgentSet(r *http.Request) (func(l *keylight.KeyLightLight), error) {
+	var req agentSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to parse request, err: %w", err)
+	}
+
+	if !req.Brightness.IsSet && !req.Temperature.IsSet && !req.Kelvin.IsSet {
+		return nil, errors.New("one of brightness, temperature or kelvin must be provided")
+	}
+
+	if req.Temperature.IsSet && req.Kelvin.IsSet {
+		return nil, errors.New("cannot specify temperature and kelvin together")
+	}
+
+	if err := req.Brightness.Validate("brightness", minBrightness, maxBrightness); err != nil {
+		return nil, err
+	}
+
+	if err := req.Temperature.Validate("temperature", minTemperature, maxTemperature); err != nil {
+		return nil, err
+	}
+
+	return func(l *keylight.KeyLightLight) {
+		l.Brightness = req.Brightness.Apply(l.Brightness, minBrightness, maxBrightness)
+		l.Temperature = req.Temperature.Apply(l.Temperature, minTemperature, maxTemperature)
+		l.Temperature = req.Kelvin.Apply(l.Temperature)
+	}, nil
+}
+
+func writeAgentJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(value)
+}
+
+// writeAgentError writes err as an agentErrorResponse. Lookup errors carry
+// their own status code and the candidates for ambiguous requests.
+func writeAgentError(w http.ResponseWriter, status int, err error) {
+	resp := agentErrorResponse{Error: err.Error()}
+
+	var lookupErr *agentLookupError
+	if errors.As(err, &lookupErr) {
+		status = lookupErr.Status
+		resp.Candidates = lookupErr.Candidates
+	}
+
+	writeAgentJSON(w, status, resp)
+}
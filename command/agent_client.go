@@ -0,0 +1,168 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// agentAddrEnvVar overrides the address of the agent that is advertised in
+	// the config dir. Setting it to `off` disables the use of the agent.
+	agentAddrEnvVar = "KEYLIGHTCTL_AGENT_ADDR"
+
+	// agentUnixScheme prefixes agent addresses that are Unix sockets, e.g:
+	// `unix:///run/user/1000/keylightctl.sock`.
+	agentUnixScheme = "unix://"
+
+	// agentQueryTimeout is the maximum time we wait for the agent before
+	// falling back to discovery.
+	agentQueryTimeout = time.Second
+)
+
+// agentClient talks to the API of a running agent.
+type agentClient struct {
+	baseURL string
+	http    *http.Client
+
+	// advertised is the address that was read from the config dir, if the
+	// agent was not provided by the environment.
+	advertised string
+}
+
+// newAgentClient returns a client for the running agent, or nil if no agent is
+// advertised. explicit is true if the address came from the environment
+// rather than the config dir.
+func newAgentClient() (client *agentClient, explicit bool) {
+	addr, explicit := os.LookupEnv(agentAddrEnvVar)
+	if !explicit {
+		path, err := configFilePath(agentAddrFileName)
+		if err != nil {
+			return nil, false
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false
+		}
+		addr = string(data)
+	}
+
+	addr = strings.TrimSpace(addr)
+	if addr == "" || addr == "off" {
+		return nil, false
+	}
+
+	client = newAgentClientForAddr(addr)
+	if !explicit {
+		client.advertised = addr
+	}
+	return client, explicit
+}
+
+// newAgentClientForAddr returns a client for the agent at addr, which is
+// either a Unix socket, a URL, or a host:port pair.
+func newAgentClientForAddr(addr string) *agentClient {
+
+	if strings.HasPrefix(addr, agentUnixScheme) {
+		socket := strings.TrimPrefix(addr, agentUnixScheme)
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		// The agent only accepts requests that are addressed to a loopback
+		// host, even over its socket.
+		return &agentClient{
+			baseURL: "http://localhost",
+			http:    &http.Client{Transport: transport},
+		}
+	}
+
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &agentClient{
+		baseURL: strings.TrimSuffix(addr, "/"),
+		http:    &http.Client{},
+	}
+}
+
+// Lights returns every light that is known to the agent.
+func (c *agentClient) Lights(ctx context.Context) ([]agentLightRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v1/lights", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp agentErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return nil, errors.New(errResp.Error)
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var records []agentLightRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to parse agent response, err: %w", err)
+	}
+
+	return records, nil
+}
+
+// ClearStaleAdvertisement removes the advertised address if err shows that no
+// agent is listening on it any more, e.g: because the agent crashed, so that
+// later invocations go straight to discovery. An address that was replaced by
+// a newly started agent is left alone.
+func (c *agentClient) ClearStaleAdvertisement(err error) {
+	if c.advertised == "" || !isAgentGone(err) {
+		return
+	}
+
+	path, err := configFilePath(agentAddrFileName)
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != c.advertised {
+		return
+	}
+
+	os.Remove(path)
+}
+
+// isAgentGone returns whether err was caused by connecting to an address that
+// nothing listens on.
+func isAgentGone(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+}
+
+// advertiseAgent records addr in the config dir so that other invocations can
+// find the agent, and returns a function that removes it again.
+func advertiseAgent(addr string) (func(), error) {
+	path, err := configFilePath(agentAddrFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(path, []byte(addr+"\n")); err != nil {
+		return nil, fmt.Errorf("failed to advertise agent address, err: %w", err)
+	}
+
+	return func() { os.Remove(path) }, nil
+}
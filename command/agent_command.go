@@ -0,0 +1,220 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+// defaultAgentListenAddr is the address the agent API listens on when no
+// address or socket is provided.
+const defaultAgentListenAddr = "127.0.0.1:9124"
+
+type AgentCommand struct {
+	Meta
+}
+
+func (c *AgentCommand) Help() string {
+	helpText := `
+Usage: keylightctl agent [options]
+
+ Run a long-lived agent that continuously discovers keylights, caches their
+ state, and serves a local HTTP/JSON API. While an agent is running, other
+ commands ask it for lights rather than waiting for discovery.
+
+ The API serves the following endpoints:
+
+   GET  /v1/lights                  List all known lights and their state
+   GET  /v1/lights/<light>          Get the state of a single light
+   POST /v1/lights/<light>/switch   Switch a light, e.g: {"power": "toggle"}
+   POST /v1/lights/<light>/set      Adjust a light, e.g: {"brightness": "+10"}
+   POST /v1/scenes/<scene>/apply    Apply a saved scene
+
+ <light> may be a full key light name, a short ID or a display name. POST
+ requests must be sent with "Content-Type: application/json", and requests
+ must be addressed to a loopback host, e.g: localhost or 127.0.0.1.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Agent Specific Options:
+
+  -listen <addr>
+    The loopback address to serve the API on (default: 127.0.0.1:9124)
+
+  -socket <path>
+    Serve the API on a Unix socket at the provided path instead of a TCP
+    address.
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs (default: 1m)
+
+  -discovery-timeout <duration>
+    Sets the maximum time to listen for accessories in each discovery run
+    (default: 5s)
+
+  -poll-interval <duration>
+    Sets the time between refreshes of the cached light state (default: 10s)
+
+ The address of the agent is recorded in the config dir while it is running,
+ and removed by the next command that finds nothing listening on it, e.g: after
+ the agent crashed. Set KEYLIGHTCTL_AGENT_ADDR to use a different agent address, or to "off" to
+ stop commands from using the agent.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AgentCommand) Synopsis() string {
+	return "Run a background agent that caches lights and serves a local API"
+}
+
+func (c *AgentCommand) Name() string { return "agent" }
+
+func (c *AgentCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var listenAddr, socketPath string
+	var discoveryInterval, discoveryTimeout, pollInterval time.Duration
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&listenAddr, "listen", defaultAgentListenAddr, "")
+	flags.StringVar(&socketPath, "socket", "", "")
+	flags.DurationVar(&discoveryInterval, "discovery-interval", time.Minute, "")
+	flags.DurationVar(&discoveryTimeout, "discovery-timeout", 5*time.Second, "")
+	flags.DurationVar(&pollInterval, "poll-interval", 10*time.Second, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if discoveryInterval <= 0 || discoveryTimeout <= 0 || pollInterval <= 0 {
+		c.UI.Error("Intervals and timeouts must be greater than zero")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	listener, advertisedAddr, err := listenAgent(listenAddr, socketPath)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	removeAdvertisement, err := advertiseAgent(advertisedAddr)
+	if err != nil {
+		listener.Close()
+		c.UI.Error(err.Error())
+		return 1
+	}
+	defer removeAdvertisement()
+
+	a := &agent{
+		UI:                c.UI,
+		DiscoveryInterval: discoveryInterval,
+		DiscoveryTimeout:  discoveryTimeout,
+		PollInterval:      pollInterval,
+		OnEvent: func(e *lightEvent) {
+			c.UI.Info(e.String())
+		},
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		c.UI.Warn(fmt.Sprintf("Ignoring light inventory, err: %v", err))
+	} else {
+		a.Seed(inv)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Handler: a.Handler()}
+	serveErrCh := make(chan error, 1)
+	go func() {
+		serveErrCh <- server.Serve(listener)
+	}()
+
+	agentDoneCh := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(agentDoneCh)
+	}()
+
+	c.UI.Output(fmt.Sprintf("Agent listening on %s", advertisedAddr))
+
+	code := 0
+	select {
+	case <-ctx.Done():
+	case err := <-serveErrCh:
+		c.UI.Error(fmt.Sprintf("Failed to serve agent API, err: %v", err))
+		code = 1
+		stop()
+	}
+
+	shutdownCtx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFn()
+	server.Shutdown(shutdownCtx)
+	<-agentDoneCh
+
+	return code
+}
+
+// listenAgent opens the listener for the agent API and returns the address
+// that clients should use to reach it. TCP addresses must be loopback
+// addresses, as the API is unauthenticated.
+func listenAgent(listenAddr, socketPath string) (net.Listener, string, error) {
+	if socketPath != "" {
+		if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to remove stale socket (%s), err: %w", socketPath, err)
+		}
+
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to listen on socket (%s), err: %w", socketPath, err)
+		}
+
+		if err := os.Chmod(socketPath, 0600); err != nil {
+			listener.Close()
+			return nil, "", fmt.Errorf("failed to restrict socket permissions (%s), err: %w", socketPath, err)
+		}
+
+		return listener, agentUnixScheme + socketPath, nil
+	}
+
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse listen address (%s), err: %w", listenAddr, err)
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, "", fmt.Errorf("listen address (%s) must be a loopback address", listenAddr)
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to listen on %s, err: %w", listenAddr, err)
+	}
+
+	return listener, "http://" + listener.Addr().String(), nil
+}
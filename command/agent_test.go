@@ -0,0 +1,280 @@
+package command
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// newTestAgent returns an agent that tracks a single simulated light with the
+// short ID 111A.
+func newTestAgent(t *testing.T) (*agent, *simulator.Simulator) {
+	t.Helper()
+	setupTestConfig(t)
+
+	sim, addr := newTestLight(t, simulator.Config{})
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	a := &agent{UI: cli.NewMockUi()}
+	a.observe(context.Background(), &keylight.KeyLight{
+		Name:    `Elgato\ Key\ Light\ 111A`,
+		DNSAddr: host,
+		Port:    portNum,
+	})
+	return a, sim
+}
+
+func TestAgentHandler_Guard(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		host        string
+		contentType string
+		body        string
+		status      int
+		on          int
+	}{
+		{
+			name:        "json switch",
+			method:      http.MethodPost,
+			path:        "/v1/lights/111A/switch",
+			host:        "127.0.0.1:9124",
+			contentType: "application/json",
+			body:        `{"power":"on"}`,
+			status:      http.StatusOK,
+			on:          1,
+		},
+		{
+			name:        "json with charset",
+			method:      http.MethodPost,
+			path:        "/v1/lights/111A/switch",
+			host:        "localhost:9124",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"power":"on"}`,
+			status:      http.StatusOK,
+			on:          1,
+		},
+		{
+			name:   "ipv6 loopback",
+			method: http.MethodGet,
+			path:   "/v1/lights",
+			host:   "[::1]:9124",
+			status: http.StatusOK,
+		},
+		{
+			name:        "text/plain body",
+			method:      http.MethodPost,
+			path:        "/v1/lights/111A/switch",
+			host:        "127.0.0.1:9124",
+			contentType: "text/plain",
+			body:        `{"power":"on"}`,
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "missing content type",
+			method: http.MethodPost,
+			path:   "/v1/lights/111A/switch",
+			host:   "127.0.0.1:9124",
+			body:   `{"power":"on"}`,
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "form scene apply",
+			method:      http.MethodPost,
+			path:        "/v1/scenes/evening/apply",
+			host:        "127.0.0.1:9124",
+			contentType: "application/x-www-form-urlencoded",
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "rebound host",
+			method:      http.MethodPost,
+			path:        "/v1/lights/111A/switch",
+			host:        "attacker.example:9124",
+			contentType: "application/json",
+			body:        `{"power":"on"}`,
+			status:      http.StatusForbidden,
+		},
+		{
+			name:   "rebound host read",
+			method: http.MethodGet,
+			path:   "/v1/lights",
+			host:   "attacker.example",
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, sim := newTestAgent(t)
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Host = tc.host
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			a.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if on := sim.Options().Lights[0].On; on != tc.on {
+				t.Errorf("expected the light to be on=%d, got %d", tc.on, on)
+			}
+		})
+	}
+}
+
+func TestAgentClient_Socket(t *testing.T) {
+	a, _ := newTestAgent(t)
+
+	socket := t.TempDir() + "/agent.sock"
+	listener, addr, err := listenAgent("", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &http.Server{Handler: a.Handler()}
+	go server.Serve(listener)
+	defer server.Close()
+
+	t.Setenv(agentAddrEnvVar, addr)
+	client, _ := newAgentClient()
+	if client == nil {
+		t.Fatalf("expected a client for %s", addr)
+	}
+
+	records, err := client.Lights(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].Name != `Elgato\ Key\ Light\ 111A` {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+// unsetAgentAddr removes the agent address override, so that the advertised
+// agent is used.
+func unsetAgentAddr(t *testing.T) {
+	t.Helper()
+
+	t.Setenv(agentAddrEnvVar, "")
+	os.Unsetenv(agentAddrEnvVar)
+}
+
+func TestLightResolver_StaleAgentAdvertisement(t *testing.T) {
+	cases := map[string]func(t *testing.T) string{
+		"tcp": func(t *testing.T) string {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := listener.Addr().String()
+			listener.Close()
+			return addr
+		},
+		"socket": func(t *testing.T) string {
+			return agentUnixScheme + filepath.Join(t.TempDir(), "agent.sock")
+		},
+	}
+
+	for name, deadAddr := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestConfig(t)
+			unsetAgentAddr(t)
+
+			if _, err := advertiseAgent(deadAddr(t)); err != nil {
+				t.Fatalf("failed to advertise agent: %v", err)
+			}
+			path, err := configFilePath(agentAddrFileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, addr := newTestLight(t, simulator.Config{})
+			resolver := lightResolver{UI: cli.NewMockUi(), RequestedLights: lightListFlags{"111A"}}
+			if _, ok, _ := resolver.fromAgent(context.Background(), nil); ok {
+				t.Fatalf("expected the agent not to be used")
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected the stale advertisement to be removed, got %v", err)
+			}
+
+			// Lights that are provided by address are still resolved.
+			resolver.RequestedLights = lightListFlags{addr}
+			if found, err := resolver.Resolve(context.Background()); err != nil || len(found) != 1 {
+				t.Errorf("expected the light to be resolved, got %v, %v", found, err)
+			}
+		})
+	}
+}
+
+func TestLightResolver_LiveAgentAdvertisement(t *testing.T) {
+	a, _ := newTestAgent(t)
+	unsetAgentAddr(t)
+
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+
+	if _, err := advertiseAgent(srv.URL); err != nil {
+		t.Fatalf("failed to advertise agent: %v", err)
+	}
+
+	resolver := lightResolver{UI: cli.NewMockUi(), RequestedLights: lightListFlags{"111A"}}
+	found, err := resolver.Resolve(context.Background())
+	if err != nil || len(found) != 1 || found[0].Name != `Elgato\ Key\ Light\ 111A` {
+		t.Fatalf("expected the light to be resolved by the agent, got %v, %v", found, err)
+	}
+
+	path, err := configFilePath(agentAddrFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected the advertisement to be kept, got %v", err)
+	}
+}
+
+func TestAgentClient_ClearStaleAdvertisementReplaced(t *testing.T) {
+	setupTestConfig(t)
+	unsetAgentAddr(t)
+
+	if _, err := advertiseAgent("127.0.0.1:1"); err != nil {
+		t.Fatalf("failed to advertise agent: %v", err)
+	}
+	client, explicit := newAgentClient()
+	if client == nil || explicit {
+		t.Fatalf("expected a client for the advertised agent")
+	}
+	_, err := client.Lights(context.Background())
+	if err == nil {
+		t.Fatalf("expected the agent to be unreachable")
+	}
+
+	// Another agent started in the meantime.
+	if _, err := advertiseAgent("127.0.0.1:2"); err != nil {
+		t.Fatalf("failed to advertise agent: %v", err)
+	}
+	client.ClearStaleAdvertisement(err)
+
+	if replaced, _ := newAgentClient(); replaced == nil || replaced.advertised != "127.0.0.1:2" {
+		t.Errorf("expected the new advertisement to be kept")
+	}
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCameraDevices matches the video devices that are watched by default.
+const defaultCameraDevices = "/dev/video*"
+
+// errCameraUnsupported is returned on platforms where camera usage can not be
+// detected.
+var errCameraUnsupported = errors.New("watching the camera is only supported on Linux")
+
+// cameraUser is a process that has a video device open.
+type cameraUser struct {
+	PID     int
+	Command string
+	Device  string
+}
+
+func (u cameraUser) String() string {
+	return fmt.Sprintf("%s (pid %d) on %s", u.Command, u.PID, u.Device)
+}
+
+// describeCameraUsers returns a human readable list of the processes using
+// the camera.
+func describeCameraUsers(users []cameraUser) string {
+	var parts []string
+	for _, u := range users {
+		parts = append(parts, u.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// cameraWatcher polls for processes that have a video device open, and
+// reports when the camera starts or stops being used. A change is only
+// reported once it has lasted for Debounce, so that applications briefly
+// reopening the device do not flicker the lights.
+type cameraWatcher struct {
+	// Devices is a glob of the device paths that are watched, e.g:
+	// /dev/video*.
+	Devices string
+
+	Interval time.Duration
+	Debounce time.Duration
+
+	// Scan returns the processes that have one of the devices open. It
+	// defaults to cameraUsers.
+	Scan func(devices string) ([]cameraUser, error)
+
+	// OnChange is called with the new state whenever it changes, and the
+	// processes that are using the camera if it is in use.
+	OnChange func(ctx context.Context, inUse bool, users []cameraUser)
+}
+
+// Run polls the devices until ctx is cancelled. The camera is assumed to be
+// unused when watching starts, so a camera that is already in use is reported
+// after Debounce. An error is returned if the devices can not be scanned.
+func (w *cameraWatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	scan := w.Scan
+	if scan == nil {
+		scan = cameraUsers
+	}
+
+	inUse := false
+	var pendingSince time.Time
+	for {
+		users, err := scan(w.Devices)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case (len(users) > 0) == inUse:
+			pendingSince = time.Time{}
+		case pendingSince.IsZero() && w.Debounce > 0:
+			pendingSince = now
+		case now.Sub(pendingSince) >= w.Debounce:
+			pendingSince = time.Time{}
+			inUse = !inUse
+			w.OnChange(ctx, inUse, users)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is where procfs is mounted.
+const procRoot = "/proc"
+
+// cameraUsers returns the processes that have a device matching the given
+// glob open, by scanning the file descriptors of every process in /proc.
+// Processes that we are not permitted to inspect are ignored.
+func cameraUsers(devices string) ([]cameraUser, error) {
+	return scanCameraUsers(procRoot, devices)
+}
+
+// scanCameraUsers implements cameraUsers for the procfs mounted at root.
+func scanCameraUsers(root, devices string) ([]cameraUser, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var users []cameraUser
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		fdDir := filepath.Join(root, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			if ok, _ := filepath.Match(devices, target); !ok {
+				continue
+			}
+
+			command, _ := os.ReadFile(filepath.Join(root, entry.Name(), "comm"))
+			users = append(users, cameraUser{
+				PID:     pid,
+				Command: strings.TrimSpace(string(command)),
+				Device:  target,
+			})
+			break
+		}
+	}
+
+	return users, nil
+}
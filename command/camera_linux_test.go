@@ -0,0 +1,69 @@
+//go:build linux
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestScanCameraUsers(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "proc")
+	dev := filepath.Join(dir, "dev")
+
+	// addProcess adds a fake process with the given open files to root.
+	addProcess := func(pid, command string, targets ...string) {
+		fdDir := filepath.Join(root, pid, "fd")
+		if err := os.MkdirAll(fdDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, pid, "comm"), []byte(command+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for idx, target := range targets {
+			if err := os.Symlink(target, filepath.Join(fdDir, strconv.Itoa(idx))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	addProcess("100", "zoom", "/dev/null", filepath.Join(dev, "video0"), filepath.Join(dev, "video1"))
+	addProcess("200", "bash", "/dev/null", filepath.Join(dev, "tty1"))
+	addProcess("300", "obs", filepath.Join(dev, "video2"))
+	addProcess(strconv.Itoa(os.Getpid()), "keylightctl", filepath.Join(dev, "video0"))
+	if err := os.MkdirAll(filepath.Join(root, "self"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := scanCameraUsers(root, filepath.Join(dev, "video*"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []cameraUser{
+		{PID: 100, Command: "zoom", Device: filepath.Join(dev, "video0")},
+		{PID: 300, Command: "obs", Device: filepath.Join(dev, "video2")},
+	}
+	if len(users) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, users)
+	}
+	for idx, want := range expected {
+		if users[idx] != want {
+			t.Errorf("expected %+v, got %+v", want, users[idx])
+		}
+	}
+
+	users, err = scanCameraUsers(root, filepath.Join(dev, "video9"))
+	if err != nil || len(users) != 0 {
+		t.Errorf("expected no users of an unused device, got %+v, err: %v", users, err)
+	}
+}
+
+func TestScanCameraUsers_MissingRoot(t *testing.T) {
+	if _, err := scanCameraUsers(filepath.Join(t.TempDir(), "missing"), "/dev/video*"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
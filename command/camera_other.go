@@ -0,0 +1,7 @@
+//go:build !linux
+
+package command
+
+func cameraUsers(devices string) ([]cameraUser, error) {
+	return nil, errCameraUnsupported
+}
@@ -0,0 +1,144 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cameraScript is a fake device scan that reports the camera as in use for the
+// scans in the given state, and keeps reporting the last state once the
+// script runs out.
+type cameraScript struct {
+	mu     sync.Mutex
+	states []bool
+	scans  int
+}
+
+func (s *cameraScript) Scan(string) ([]cameraUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.scans
+	if idx >= len(s.states) {
+		idx = len(s.states) - 1
+	}
+	s.scans++
+
+	if !s.states[idx] {
+		return nil, nil
+	}
+	return []cameraUser{{PID: 42, Command: "zoom", Device: "/dev/video0"}}, nil
+}
+
+// cameraChange is a state change that was reported by the watcher.
+type cameraChange struct {
+	inUse bool
+	at    time.Duration
+}
+
+// runCameraWatcher runs a watcher over the script for duration and returns the
+// changes it reported.
+func runCameraWatcher(t *testing.T, script *cameraScript, debounce, duration time.Duration) []cameraChange {
+	t.Helper()
+
+	var mu sync.Mutex
+	var changes []cameraChange
+	start := time.Now()
+
+	w := &cameraWatcher{
+		Devices:  "/dev/video*",
+		Interval: 10 * time.Millisecond,
+		Debounce: debounce,
+		Scan:     script.Scan,
+		OnChange: func(_ context.Context, inUse bool, users []cameraUser) {
+			mu.Lock()
+			defer mu.Unlock()
+			if inUse && len(users) == 0 {
+				t.Errorf("expected the users of the camera to be reported")
+			}
+			changes = append(changes, cameraChange{inUse: inUse, at: time.Since(start)})
+		},
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), duration)
+	defer cancelFn()
+	if err := w.Run(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return changes
+}
+
+func TestCameraWatcher_Start(t *testing.T) {
+	script := &cameraScript{states: []bool{false, true}}
+	debounce := 50 * time.Millisecond
+
+	changes := runCameraWatcher(t, script, debounce, 300*time.Millisecond)
+	if len(changes) != 1 || !changes[0].inUse {
+		t.Fatalf("expected a single change to in use, got %+v", changes)
+	}
+	if changes[0].at < debounce {
+		t.Errorf("expected the change to be reported after %s, got %s", debounce, changes[0].at)
+	}
+}
+
+func TestCameraWatcher_Stop(t *testing.T) {
+	states := make([]bool, 15)
+	for i := range states {
+		states[i] = true
+	}
+	script := &cameraScript{states: append(states, false)}
+
+	changes := runCameraWatcher(t, script, 50*time.Millisecond, 500*time.Millisecond)
+	if len(changes) != 2 || !changes[0].inUse || changes[1].inUse {
+		t.Fatalf("expected the camera to start and stop being used, got %+v", changes)
+	}
+}
+
+func TestCameraWatcher_Flap(t *testing.T) {
+	// The camera is only in use for a couple of scans, which is shorter than
+	// the debounce, so no change is reported.
+	script := &cameraScript{states: []bool{false, true, true, false}}
+
+	changes := runCameraWatcher(t, script, 200*time.Millisecond, 400*time.Millisecond)
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestCameraWatcher_NoDebounce(t *testing.T) {
+	script := &cameraScript{states: []bool{true, false, true}}
+
+	changes := runCameraWatcher(t, script, 0, 100*time.Millisecond)
+	expected := []bool{true, false, true}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for idx, inUse := range expected {
+		if changes[idx].inUse != inUse {
+			t.Errorf("change %d: expected in use to be %v, got %v", idx, inUse, changes[idx].inUse)
+		}
+	}
+}
+
+func TestCameraWatcher_ScanError(t *testing.T) {
+	scanErr := errors.New("permission denied")
+	w := &cameraWatcher{
+		Interval: 10 * time.Millisecond,
+		Scan: func(string) ([]cameraUser, error) {
+			return nil, scanErr
+		},
+		OnChange: func(context.Context, bool, []cameraUser) {
+			t.Errorf("expected no changes")
+		},
+	}
+
+	if err := w.Run(context.Background()); !errors.Is(err, scanErr) {
+		t.Errorf("expected the scan error, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+type CameraWatchCommand struct {
+	Meta
+}
+
+func (c *CameraWatchCommand) Help() string {
+	helpText := `
+Usage: keylightctl camera-watch [options]
+
+ Switch keylights on when a camera starts being used, e.g. when joining a
+ call, and off again when it is released. Instead of switching lights, a scene
+ can be applied when the camera starts or stops being used.
+
+ Cameras are detected by scanning the open files of all processes for video
+ devices, so this command is only supported on Linux. Processes of other users
+ are only visible when running as root.
+
+ Test the command without a camera by watching a regular file, and holding it
+ open from another shell:
+
+     $ keylightctl camera-watch -device /tmp/fake-camera -light 111A
+     $ sleep 30 < /tmp/fake-camera
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Camera Watch Specific Options:
+
+  -device <glob>
+    The device paths to watch (default: /dev/video*)
+
+  -interval <duration>
+    Sets the time between scans for open devices (default: 1s)
+
+  -debounce <duration>
+    How long the camera must be in use, or unused, before the lights are
+    changed (default: 3s)
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories each time the lights are
+    changed (default: 5s)
+
+  -all
+    Switch all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Switch") + `
+
+  -group <group>
+    Switch all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -on-scene <scene>
+    Apply the provided scene when the camera starts being used, instead of
+    switching the lights on.
+
+  -off-scene <scene>
+    Apply the provided scene when the camera stops being used, instead of
+    switching the lights off.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *CameraWatchCommand) Synopsis() string {
+	return "Switch keylights when the camera is in use"
+}
+
+func (f *CameraWatchCommand) Name() string { return "camera-watch" }
+
+func (c *CameraWatchCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var devices, onScene, offScene string
+	var interval, debounce, timeout time.Duration
+	var selection lightSelection
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&devices, "device", defaultCameraDevices, "")
+	flags.DurationVar(&interval, "interval", time.Second, "")
+	flags.DurationVar(&debounce, "debounce", 3*time.Second, "")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.StringVar(&onScene, "on-scene", "", "")
+	flags.StringVar(&offScene, "off-scene", "", "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if onScene == "" || offScene == "" {
+		if err := selection.Validate(); err != nil {
+			c.UI.Error(err.Error())
+			c.UI.Error(commandErrorText(c))
+			return 1
+		}
+	}
+
+	if _, err := filepath.Match(devices, ""); err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid --device pattern '%s', err: %v", devices, err))
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval <= 0 || debounce < 0 {
+		c.UI.Error("--interval must be positive and --debounce must not be negative")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	// The actions are expressed as schedule rules, which already know how to
+	// switch a selection of lights or apply a scene.
+	onRule := c.rule("camera-on", "on", onScene, &selection)
+	offRule := c.rule("camera-off", "off", offScene, &selection)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher := &cameraWatcher{
+		Devices:  devices,
+		Interval: interval,
+		Debounce: debounce,
+		OnChange: func(ctx context.Context, inUse bool, users []cameraUser) {
+			rule := offRule
+			if inUse {
+				c.UI.Info(fmt.Sprintf("Camera is in use by %s", describeCameraUsers(users)))
+				rule = onRule
+			} else {
+				c.UI.Info("Camera is no longer in use")
+			}
+			c.apply(ctx, rule, timeout)
+		},
+	}
+
+	c.UI.Output(fmt.Sprintf("Watching %s", devices))
+	if err := watcher.Run(ctx); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to watch %s, err: %v", devices, err))
+		return 1
+	}
+
+	return 0
+}
+
+// rule returns the action to take when the camera changes state, applying
+// scene if it is set, or switching the selected lights otherwise.
+func (c *CameraWatchCommand) rule(name, power, scene string, selection *lightSelection) *scheduleRule {
+	if scene != "" {
+		return &scheduleRule{Name: name, Scene: scene}
+	}
+	return &scheduleRule{
+		Name:   name,
+		Lights: selection.Lights,
+		Groups: selection.Groups,
+		All:    selection.AllLights,
+		Switch: power,
+	}
+}
+
+func (c *CameraWatchCommand) apply(ctx context.Context, rule *scheduleRule, timeout time.Duration) {
+	results, err := rule.Apply(ctx, c.UI, timeout)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to apply '%s', err: %v", rule.Action(), err))
+		return
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			c.UI.Error(fmt.Sprintf("Failed to update light (%s), err: %v", lightLabel(r.Light), r.Err))
+		}
+	}
+	c.UI.Info(fmt.Sprintf("Applied '%s' to %d of %d light(s)", rule.Action(), len(results)-failed, len(results)))
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// lightChannelSeparator separates a light requirement from the index of a
+// single light within the accessory, e.g: `111A#1` selects the second light of
+// an accessory that exposes several of them. Indices start at 0, like the
+// light_index field of describe.
+const lightChannelSeparator = "#"
+
+// splitLightChannel splits a light requirement into the requirement for the
+// accessory and the index of the selected channel, which is -1 if the whole
+// accessory is selected. Requirements that merely contain the separator, e.g.
+// a display name such as `Desk #2`, are left alone.
+func splitLightChannel(req string) (string, int) {
+	idx := strings.LastIndex(req, lightChannelSeparator)
+	if idx <= 0 || strings.HasSuffix(req[:idx], " ") {
+		return req, -1
+	}
+
+	suffix := req[idx+1:]
+	if suffix == "" || strings.Trim(suffix, "0123456789") != "" {
+		return req, -1
+	}
+
+	channel, err := strconv.Atoi(suffix)
+	if err != nil {
+		return req, -1
+	}
+
+	return req[:idx], channel
+}
+
+// lightChannels records the channels that were selected for each resolved
+// accessory, keyed by lightLabel. Accessories without an entry, or with a nil
+// entry, are selected as a whole.
+type lightChannels map[string][]int
+
+// add selects the given channel of the accessory, or the whole accessory if
+// channel is -1. Selecting the whole accessory takes precedence over any
+// individual channels.
+func (c lightChannels) add(label string, channel int) {
+	channels, ok := c[label]
+	switch {
+	case channel < 0:
+		c[label] = nil
+	case ok && channels == nil:
+		// The whole accessory is already selected.
+	default:
+		for _, existing := range channels {
+			if existing == channel {
+				return
+			}
+		}
+		c[label] = append(channels, channel)
+	}
+}
+
+// Get returns the selected channels of the light, or nil if the whole
+// accessory is selected.
+func (c lightChannels) Get(light *keylight.KeyLight) []int {
+	return c[lightLabel(light)]
+}
+
+// selectChannels returns the lights of opts that are selected by channels, in
+// the order they were selected, or every light if channels is nil. An error is
+// returned if a selected channel does not exist.
+func selectChannels(opts *keylight.KeyLightOptions, channels []int) ([]*keylight.KeyLightLight, error) {
+	if channels == nil {
+		return opts.Lights, nil
+	}
+
+	result := make([]*keylight.KeyLightLight, 0, len(channels))
+	for _, channel := range channels {
+		if channel >= len(opts.Lights) {
+			return nil, fmt.Errorf("channel %s%d does not exist, the accessory has %d light(s)", lightChannelSeparator, channel, len(opts.Lights))
+		}
+		result = append(result, opts.Lights[channel])
+	}
+	return result, nil
+}
+
+// channelSelected returns whether the light at index idx of an accessory is
+// selected by channels.
+func channelSelected(channels []int, idx int) bool {
+	if channels == nil {
+		return true
+	}
+	for _, channel := range channels {
+		if channel == idx {
+			return true
+		}
+	}
+	return false
+}
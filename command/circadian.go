@@ -0,0 +1,369 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// circadianFileName is the name of the circadian curve within the config dir.
+const circadianFileName = "circadian.yaml"
+
+// defaultCircadianPoints is the curve that is followed when no curve has been
+// configured: warm in the early morning and evening, and cool at midday.
+var defaultCircadianPoints = []struct {
+	At     string
+	Kelvin int
+}{
+	{At: "06:00", Kelvin: 2900},
+	{At: "09:00", Kelvin: 5000},
+	{At: "13:00", Kelvin: 6500},
+	{At: "18:00", Kelvin: 4500},
+	{At: "21:00", Kelvin: 2900},
+}
+
+// circadianCurve is the daily curve of temperature, and optionally
+// brightness, that lights follow in circadian mode. Points are given at times
+// of day or solar events, and lights are interpolated between them, e.g:
+//
+//	location:
+//	  latitude: 52.52
+//	  longitude: 13.40
+//	points:
+//	  - at: sunrise
+//	    kelvin: 3000K
+//	    brightness: 40
+//	  - at: "13:00"
+//	    kelvin: 6500K
+//	    brightness: 80
+//	  - at: sunset+30m
+//	    kelvin: 2900K
+//	    brightness: 50
+type circadianCurve struct {
+	Location *scheduleLocation `yaml:"location"`
+	Points   []*circadianPoint `yaml:"points"`
+
+	// hasBrightness is true if the curve controls brightness as well as
+	// temperature.
+	hasBrightness bool
+}
+
+// circadianPoint is the state of the lights at a time of day.
+type circadianPoint struct {
+	// At is either a time of day, e.g: `13:00`, or a solar event with an
+	// optional offset, e.g: `sunset+30m`.
+	At         string           `yaml:"at"`
+	Kelvin     kelvinAdjustment `yaml:"kelvin"`
+	Brightness lightAdjustment  `yaml:"brightness"`
+
+	timeOfDay func(day time.Time) (time.Time, bool)
+}
+
+// circadianTarget is the state of the lights at a point in time. Brightness
+// is zero if the curve does not control brightness.
+type circadianTarget struct {
+	Temperature int
+	Brightness  int
+}
+
+func (t circadianTarget) String() string {
+	if t.Brightness == 0 {
+		return formatKelvin(t.Temperature)
+	}
+	return fmt.Sprintf("%s, %d%%", formatKelvin(t.Temperature), t.Brightness)
+}
+
+// loadCircadianCurve reads the curve from path, or from the config dir if path
+// is empty. The default curve is returned if path is empty and no curve has
+// been configured.
+func loadCircadianCurve(path string) (*circadianCurve, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		path, err = configFilePath(circadianFileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	curve := &circadianCurve{}
+	if err := readYAMLFile(path, curve); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to load circadian curve, err: %w", err)
+		}
+		if explicit {
+			return nil, fmt.Errorf("no circadian curve found at %s", path)
+		}
+
+		curve = &circadianCurve{}
+		for _, p := range defaultCircadianPoints {
+			point := &circadianPoint{At: p.At}
+			point.Kelvin.Set(fmt.Sprint(p.Kelvin))
+			curve.Points = append(curve.Points, point)
+		}
+	}
+
+	if err := curve.init(); err != nil {
+		return nil, fmt.Errorf("invalid circadian curve, err: %w", err)
+	}
+
+	return curve, nil
+}
+
+// init validates the points of the curve and parses their times.
+func (c *circadianCurve) init() error {
+	if len(c.Points) < 2 {
+		return errors.New("at least two points must be provided")
+	}
+
+	withBrightness := 0
+	for _, p := range c.Points {
+		if !p.Kelvin.IsSet || p.Kelvin.Relative {
+			return fmt.Errorf("point '%s' must have an absolute kelvin value", p.At)
+		}
+		if p.Brightness.IsSet {
+			if p.Brightness.Relative {
+				return fmt.Errorf("point '%s' must have an absolute brightness", p.At)
+			}
+			if err := p.Brightness.Validate("brightness", minBrightness, maxBrightness); err != nil {
+				return fmt.Errorf("point '%s', err: %w", p.At, err)
+			}
+			withBrightness++
+		}
+
+		timeOfDay, err := parseTimeOfDay(p.At, c.Location)
+		if err != nil {
+			return err
+		}
+		p.timeOfDay = timeOfDay
+	}
+
+	if withBrightness != 0 && withBrightness != len(c.Points) {
+		return errors.New("brightness must be provided for every point or for none")
+	}
+	c.hasBrightness = withBrightness != 0
+
+	return nil
+}
+
+// Target returns the state of the lights at t, interpolating between the
+// surrounding points. Temperatures are interpolated in mireds, which change
+// more evenly to the eye than Kelvin. ok is false if no points occur around t,
+// e.g. when the curve only uses solar events during polar day or night.
+func (c *circadianCurve) Target(t time.Time) (target circadianTarget, ok bool) {
+	type sample struct {
+		at          time.Time
+		temperature float64
+		brightness  float64
+	}
+
+	// Points from the surrounding days are included so that the curve wraps
+	// around midnight.
+	var samples []sample
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for _, offset := range []int{-1, 0, 1} {
+		d := day.AddDate(0, 0, offset)
+		for _, p := range c.Points {
+			at, ok := p.timeOfDay(d)
+			if !ok {
+				continue
+			}
+			samples = append(samples, sample{
+				at:          at,
+				temperature: float64(kelvinToMireds(p.Kelvin.Value)),
+				brightness:  float64(p.Brightness.Value),
+			})
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].at.Before(samples[j].at) })
+
+	for i := 0; i+1 < len(samples); i++ {
+		from, to := samples[i], samples[i+1]
+		if t.Before(from.at) || !t.Before(to.at) {
+			continue
+		}
+
+		progress := float64(t.Sub(from.at)) / float64(to.at.Sub(from.at))
+		target.Temperature = int(math.Round(from.temperature + (to.temperature-from.temperature)*progress))
+		if c.hasBrightness {
+			target.Brightness = int(math.Round(from.brightness + (to.brightness-from.brightness)*progress))
+		}
+		return target, true
+	}
+
+	return circadianTarget{}, false
+}
+
+// circadianLight is the state that the controller keeps for each light.
+type circadianLight struct {
+	// expected is the state the light was left in by the last adjustment. If
+	// the light no longer matches it, it was changed by someone else.
+	expected *keylight.KeyLightOptions
+
+	// pausedUntil is the time that adjustments resume after the light was
+	// changed manually.
+	pausedUntil time.Time
+
+	failing bool
+}
+
+// circadianController periodically moves the lights tracked by an agent
+// towards the target of a curve. Lights are only updated when they drift from
+// the target by more than a threshold, and a light that is changed by someone
+// else is left alone for Backoff.
+type circadianController struct {
+	UI    cli.Ui
+	Agent *agent
+	Curve *circadianCurve
+
+	// Threshold is the drift in Kelvin, and BrightnessThreshold the drift in
+	// brightness percent, beyond which a light is updated.
+	Threshold           int
+	BrightnessThreshold int
+
+	Backoff time.Duration
+
+	lights map[string]*circadianLight
+}
+
+// Tick adjusts every light towards the target of the curve at now.
+func (c *circadianController) Tick(ctx context.Context, now time.Time) {
+	target, ok := c.Curve.Target(now)
+	if !ok {
+		return
+	}
+
+	for _, key := range c.Agent.Keys() {
+		c.adjust(ctx, key, target, now)
+	}
+}
+
+func (c *circadianController) adjust(ctx context.Context, key string, target circadianTarget, now time.Time) {
+	if c.lights == nil {
+		c.lights = make(map[string]*circadianLight)
+	}
+	state, ok := c.lights[key]
+	if !ok {
+		state = &circadianLight{}
+		c.lights[key] = state
+	}
+
+	if !state.pausedUntil.IsZero() {
+		if now.Before(state.pausedUntil) {
+			return
+		}
+		state.pausedUntil = time.Time{}
+		c.UI.Info(fmt.Sprintf("Resuming adjustments of light (%s)", key))
+	}
+
+	light, _ := c.Agent.client(key)
+	ctx, cancelFn := context.WithTimeout(ctx, agentLightTimeout)
+	defer cancelFn()
+
+	opts, err := light.FetchLightOptions(ctx)
+	if err != nil {
+		if !state.failing {
+			c.UI.Warn(fmt.Sprintf("Failed to fetch light options for light (%s), err: %v", key, err))
+		}
+		state.failing = true
+		return
+	}
+	state.failing = false
+
+	if state.expected != nil && circadianChanged(state.expected, opts) {
+		state.expected = nil
+		state.pausedUntil = now.Add(c.Backoff)
+		c.UI.Info(fmt.Sprintf("Light (%s) was changed manually, pausing adjustments for %s", key, c.Backoff))
+		return
+	}
+
+	// Once any light has drifted too far, every light is moved to the
+	// target so that they stay consistent with each other. Lights that are
+	// switched off are left alone until they are switched on again.
+	drifted := false
+	for _, l := range opts.Lights {
+		if l.On == 0 {
+			continue
+		}
+		if absInt(miredsToKelvin(l.Temperature)-miredsToKelvin(target.Temperature)) > c.Threshold {
+			drifted = true
+		}
+		if target.Brightness != 0 && absInt(l.Brightness-target.Brightness) > c.BrightnessThreshold {
+			drifted = true
+		}
+	}
+
+	if !drifted {
+		state.expected = opts
+		return
+	}
+
+	newOpts := opts.Copy()
+	for _, l := range newOpts.Lights {
+		if l.On == 0 {
+			continue
+		}
+		l.Temperature = target.Temperature
+		if target.Brightness != 0 {
+			l.Brightness = target.Brightness
+		}
+	}
+
+	updated, err := light.UpdateLightOptions(ctx, newOpts)
+	if err != nil {
+		state.expected = nil
+		c.UI.Error(fmt.Sprintf("Failed to update light (%s), err: %v", key, err))
+		return
+	}
+	if len(updated.Lights) != len(newOpts.Lights) {
+		updated = newOpts
+	}
+	state.expected = updated
+
+	c.UI.Info(fmt.Sprintf("Adjusted light (%s) to %s", key, target))
+}
+
+// circadianChanged returns true if the brightness or temperature of any light
+// differs between the two states. Power is ignored, so that lights can be
+// switched on and off without pausing adjustments.
+func circadianChanged(expected, current *keylight.KeyLightOptions) bool {
+	if len(expected.Lights) != len(current.Lights) {
+		return true
+	}
+	for idx, l := range current.Lights {
+		e := expected.Lights[idx]
+		if l.Brightness != e.Brightness || l.Temperature != e.Temperature {
+			return true
+		}
+	}
+	return false
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// describeCircadianCurve returns a human readable summary of the points of the
+// curve, e.g: `06:00 2900K, 13:00 6500K`.
+func describeCircadianCurve(c *circadianCurve) string {
+	var points []string
+	for _, p := range c.Points {
+		s := fmt.Sprintf("%s %dK", p.At, p.Kelvin.Value)
+		if p.Brightness.IsSet {
+			s += fmt.Sprintf(" %d%%", p.Brightness.Value)
+		}
+		points = append(points, s)
+	}
+	return strings.Join(points, ", ")
+}
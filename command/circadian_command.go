@@ -0,0 +1,228 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type CircadianCommand struct {
+	Meta
+}
+
+func (c *CircadianCommand) Help() string {
+	helpText := `
+Usage: keylightctl circadian [options]
+
+ Continuously adjust the temperature, and optionally the brightness, of
+ keylights along a daily curve until interrupted. Lights are only updated when
+ they drift from the curve by more than -threshold, and the power of the
+ lights is never changed. Lights that are switched off are not adjusted until
+ they are switched on again.
+
+ When a light is changed by something else, e.g. with the set command or the
+ Control Center app, adjustments of that light are paused for -backoff.
+
+ The curve is read from circadian.yaml in the keylightctl config dir, or the
+ file provided with -file. Points are given at a time of day or a solar event
+ with an optional offset, as in schedule rules, and lights are interpolated
+ between them:
+
+     location:
+       latitude: 52.52
+       longitude: 13.40
+     points:
+       - at: sunrise
+         kelvin: 3000K
+         brightness: 40
+       - at: "13:00"
+         kelvin: 6500K
+         brightness: 80
+       - at: sunset+30m
+         kelvin: 2900K
+         brightness: 50
+
+ Brightness must be provided for every point, or for none to leave the
+ brightness alone. If no curve is configured, a default curve is used that
+ warms to 2900K by 21:00 and cools to 6500K at 13:00.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Circadian Specific Options:
+
+  -file <path>
+    The curve file to read (default: circadian.yaml in the config dir)
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories before adjustments start
+    (default: 5s)
+
+  -all
+    Adjust all keylights. Lights that are discovered while running are
+    added as they appear. This is the default if no lights or groups are
+    provided.
+
+  ` + lightOptionUsage("Adjust") + `
+
+  -group <group>
+    Adjust all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -interval <duration>
+    Sets the time between adjustments (default: 1m)
+
+  -threshold <kelvin>
+    The drift from the curve in Kelvin beyond which a light is updated
+    (default: 100)
+
+  -brightness-threshold <percent>
+    The drift from the curve in brightness percent beyond which a light is
+    updated (default: 5)
+
+  -backoff <duration>
+    How long to pause adjustments of a light after it was changed by
+    something else (default: 1h)
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs while adjusting all lights
+    (default: 1m)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *CircadianCommand) Synopsis() string {
+	return "Adjust keylight temperature along a daily curve"
+}
+
+func (f *CircadianCommand) Name() string { return "circadian" }
+
+func (c *CircadianCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var path string
+	var timeout, interval, backoff, discoveryInterval time.Duration
+	var threshold, brightnessThreshold int
+	var selection lightSelection
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&path, "file", "", "")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.DurationVar(&interval, "interval", time.Minute, "")
+	flags.IntVar(&threshold, "threshold", 100, "")
+	flags.IntVar(&brightnessThreshold, "brightness-threshold", 5, "")
+	flags.DurationVar(&backoff, "backoff", time.Hour, "")
+	flags.DurationVar(&discoveryInterval, "discovery-interval", time.Minute, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if len(selection.Lights) == 0 && len(selection.Groups) == 0 {
+		selection.AllLights = true
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval <= 0 || discoveryInterval <= 0 {
+		c.UI.Error("--interval and --discovery-interval must be positive")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if threshold < 0 || brightnessThreshold < 0 || backoff < 0 {
+		c.UI.Error("--threshold, --brightness-threshold and --backoff must not be negative")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	curve, err := loadCircadianCurve(path)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 && !selection.AllLights {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	// The agent is only used to keep track of which lights exist, the
+	// controller reads the state of each light itself before adjusting it.
+	a := &agent{
+		UI:               c.UI,
+		DiscoveryTimeout: timeout,
+	}
+	if selection.AllLights {
+		a.DiscoveryInterval = discoveryInterval
+	}
+
+	var wg sync.WaitGroup
+	for _, light := range found {
+		wg.Add(1)
+		go func(light *keylight.KeyLight) {
+			defer wg.Done()
+			a.observe(ctx, light)
+		}(light)
+	}
+	wg.Wait()
+
+	controller := &circadianController{
+		UI:                  c.UI,
+		Agent:               a,
+		Curve:               curve,
+		Threshold:           threshold,
+		BrightnessThreshold: brightnessThreshold,
+		Backoff:             backoff,
+	}
+
+	c.UI.Output(fmt.Sprintf("Following curve: %s", describeCircadianCurve(curve)))
+	if target, ok := curve.Target(time.Now()); ok {
+		c.UI.Output(fmt.Sprintf("Current target: %s", target))
+	}
+
+	go a.Run(ctx)
+
+	a.every(ctx, interval, func(ctx context.Context) {
+		controller.Tick(ctx, time.Now())
+	})
+
+	return 0
+}
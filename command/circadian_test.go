@@ -0,0 +1,272 @@
+package command
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// loadTestCurve loads a circadian curve from yaml.
+func loadTestCurve(t *testing.T, yaml string) *circadianCurve {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), circadianFileName)
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	curve, err := loadCircadianCurve(path)
+	if err != nil {
+		t.Fatalf("failed to load curve: %v", err)
+	}
+	return curve
+}
+
+func TestCircadianCurve_Target(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	curve := loadTestCurve(t, `
+points:
+  - {at: "07:00", kelvin: 3000K, brightness: 40}
+  - {at: "13:00", kelvin: 6500K, brightness: 80}
+  - {at: "19:00", kelvin: 5000K, brightness: 60}
+`)
+
+	// 3000K, 6500K and 5000K are 333, 154 and 200 mireds.
+	cases := []struct {
+		name        string
+		at          time.Time
+		temperature int
+		brightness  int
+	}{
+		{name: "at a point", at: time.Date(2024, time.October, 17, 7, 0, 0, 0, berlin), temperature: 333, brightness: 40},
+		{name: "between points", at: time.Date(2024, time.October, 17, 10, 0, 0, 0, berlin), temperature: 244, brightness: 60},
+		{name: "evening", at: time.Date(2024, time.October, 17, 22, 0, 0, 0, berlin), temperature: 233, brightness: 55},
+		{name: "across midnight", at: time.Date(2024, time.October, 18, 1, 0, 0, 0, berlin), temperature: 267, brightness: 50},
+		{name: "just before a point", at: time.Date(2024, time.October, 17, 18, 59, 59, 0, berlin), temperature: 200, brightness: 60},
+		{
+			// The night is an hour shorter when the clocks go forward, so
+			// 01:00 is 6 of 11 hours between the points.
+			name:        "daylight saving starts",
+			at:          time.Date(2024, time.March, 31, 1, 0, 0, 0, berlin),
+			temperature: 273,
+			brightness:  49,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target, ok := curve.Target(tc.at)
+			if !ok {
+				t.Fatalf("expected a target")
+			}
+			if target.Temperature != tc.temperature || target.Brightness != tc.brightness {
+				t.Errorf("expected %d mireds at %d%%, got %d mireds at %d%%", tc.temperature, tc.brightness, target.Temperature, target.Brightness)
+			}
+		})
+	}
+}
+
+func TestCircadianCurve_TargetWithoutBrightness(t *testing.T) {
+	curve := loadTestCurve(t, `
+points:
+  - {at: "06:00", kelvin: 2900K}
+  - {at: "18:00", kelvin: 6500K}
+`)
+
+	target, ok := curve.Target(time.Date(2024, time.October, 17, 18, 0, 0, 0, time.UTC))
+	if !ok || target.Temperature != kelvinToMireds(6500) || target.Brightness != 0 {
+		t.Errorf("expected only the temperature to be set, got %+v", target)
+	}
+	if s := target.String(); s != "6500K" {
+		t.Errorf("expected the target to be described in Kelvin, got %s", s)
+	}
+}
+
+func TestCircadianCurve_TargetPolar(t *testing.T) {
+	oslo := mustLoadLocation(t, "Europe/Oslo")
+	curve := loadTestCurve(t, `
+location: {latitude: 69.65, longitude: 18.96}
+points:
+  - {at: sunrise, kelvin: 3000K}
+  - {at: sunset, kelvin: 5000K}
+`)
+
+	if target, ok := curve.Target(time.Date(2024, time.June, 21, 12, 0, 0, 0, oslo)); ok {
+		t.Errorf("expected no target during polar day, got %+v", target)
+	}
+	if _, ok := curve.Target(time.Date(2024, time.October, 17, 12, 0, 0, 0, oslo)); !ok {
+		t.Errorf("expected a target when the sun rises and sets")
+	}
+}
+
+func TestLoadCircadianCurve_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"single point":       `points: [{at: "06:00", kelvin: 2900K}]`,
+		"relative kelvin":    `points: [{at: "06:00", kelvin: +100K}, {at: "18:00", kelvin: 6500K}]`,
+		"missing kelvin":     `points: [{at: "06:00"}, {at: "18:00", kelvin: 6500K}]`,
+		"partial brightness": `points: [{at: "06:00", kelvin: 2900K, brightness: 40}, {at: "18:00", kelvin: 6500K}]`,
+		"invalid brightness": `points: [{at: "06:00", kelvin: 2900K, brightness: 120}, {at: "18:00", kelvin: 6500K, brightness: 80}]`,
+		"invalid time":       `points: [{at: "6am", kelvin: 2900K}, {at: "18:00", kelvin: 6500K}]`,
+		"missing location":   `points: [{at: sunrise, kelvin: 2900K}, {at: "18:00", kelvin: 6500K}]`,
+	}
+
+	for name, yaml := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), circadianFileName)
+			if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadCircadianCurve(path); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+// newTestCircadianController returns a controller for a single simulated
+// accessory, along with the key of the accessory in the agent.
+func newTestCircadianController(t *testing.T, cfg simulator.Config) (*circadianController, *simulator.Simulator, string) {
+	t.Helper()
+	setupTestConfig(t)
+
+	sim, addr := newTestLight(t, cfg)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	key := `Elgato\ Key\ Light\ 111A`
+	a := &agent{UI: cli.NewMockUi()}
+	a.observe(context.Background(), &keylight.KeyLight{Name: key, DNSAddr: host, Port: portNum})
+
+	c := &circadianController{
+		UI:                  cli.NewMockUi(),
+		Agent:               a,
+		Threshold:           100,
+		BrightnessThreshold: 5,
+		Backoff:             time.Hour,
+	}
+	return c, sim, key
+}
+
+// changeTestLight changes a light as if it was changed by someone else.
+func changeTestLight(t *testing.T, c *circadianController, key string, mutate func(l *keylight.KeyLightLight)) {
+	t.Helper()
+
+	light, _ := c.Agent.client(key)
+	if err := modifyLightOptions(context.Background(), light, mutate); err != nil {
+		t.Fatalf("failed to change light: %v", err)
+	}
+}
+
+func switchOn(l *keylight.KeyLightLight) { l.On = 1 }
+
+func TestCircadianController_Threshold(t *testing.T) {
+	// The simulated light starts at 213 mireds (4695K) and 20% brightness.
+	cases := []struct {
+		name        string
+		target      circadianTarget
+		temperature int
+		brightness  int
+	}{
+		{name: "within the threshold", target: circadianTarget{Temperature: 210}, temperature: 213, brightness: 20},
+		{name: "beyond the threshold", target: circadianTarget{Temperature: 200}, temperature: 200, brightness: 20},
+		{name: "brightness within the threshold", target: circadianTarget{Temperature: 213, Brightness: 24}, temperature: 213, brightness: 20},
+		{name: "brightness beyond the threshold", target: circadianTarget{Temperature: 210, Brightness: 60}, temperature: 210, brightness: 60},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, sim, key := newTestCircadianController(t, simulator.Config{})
+			changeTestLight(t, c, key, switchOn)
+
+			c.adjust(context.Background(), key, tc.target, time.Now())
+
+			l := sim.Options().Lights[0]
+			if l.Temperature != tc.temperature || l.Brightness != tc.brightness {
+				t.Errorf("expected %d mireds at %d%%, got %d mireds at %d%%", tc.temperature, tc.brightness, l.Temperature, l.Brightness)
+			}
+			if l.On != 1 {
+				t.Errorf("expected the light to stay on")
+			}
+		})
+	}
+}
+
+func TestCircadianController_Off(t *testing.T) {
+	c, sim, key := newTestCircadianController(t, simulator.Config{Lights: 2})
+	light, _ := c.Agent.client(key)
+	if err := modifyLightChannels(context.Background(), light, []int{1}, func(lights []*keylight.KeyLightLight) error {
+		lights[0].On = 1
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to switch on the second light: %v", err)
+	}
+
+	c.adjust(context.Background(), key, circadianTarget{Temperature: 300, Brightness: 60}, time.Now())
+
+	opts := sim.Options()
+	if l := opts.Lights[0]; l.On != 0 || l.Temperature != 213 || l.Brightness != 20 {
+		t.Errorf("expected the light that is off to be left alone, got %+v", l)
+	}
+	if l := opts.Lights[1]; l.On != 1 || l.Temperature != 300 || l.Brightness != 60 {
+		t.Errorf("expected the light that is on to be adjusted, got %+v", l)
+	}
+}
+
+func TestCircadianController_ManualChange(t *testing.T) {
+	c, sim, key := newTestCircadianController(t, simulator.Config{})
+	changeTestLight(t, c, key, switchOn)
+	ui := c.UI.(*cli.MockUi)
+
+	start := time.Date(2024, time.October, 17, 12, 0, 0, 0, time.UTC)
+	target := circadianTarget{Temperature: 200}
+
+	c.adjust(context.Background(), key, target, start)
+	if temperature := sim.Options().Lights[0].Temperature; temperature != 200 {
+		t.Fatalf("expected the light to be adjusted, got %d mireds", temperature)
+	}
+
+	changeTestLight(t, c, key, func(l *keylight.KeyLightLight) { l.Temperature = 300 })
+
+	c.adjust(context.Background(), key, target, start.Add(time.Minute))
+	if !strings.Contains(ui.OutputWriter.String(), "was changed manually") {
+		t.Errorf("expected the manual change to be reported, got %s", ui.OutputWriter.String())
+	}
+
+	c.adjust(context.Background(), key, target, start.Add(time.Minute+c.Backoff-time.Second))
+	if temperature := sim.Options().Lights[0].Temperature; temperature != 300 {
+		t.Fatalf("expected adjustments to be paused, got %d mireds", temperature)
+	}
+
+	c.adjust(context.Background(), key, target, start.Add(time.Minute+c.Backoff))
+	if temperature := sim.Options().Lights[0].Temperature; temperature != 200 {
+		t.Errorf("expected adjustments to resume after the backoff, got %d mireds", temperature)
+	}
+}
+
+func TestCircadianController_PowerChange(t *testing.T) {
+	c, sim, key := newTestCircadianController(t, simulator.Config{})
+	changeTestLight(t, c, key, switchOn)
+
+	start := time.Date(2024, time.October, 17, 12, 0, 0, 0, time.UTC)
+	c.adjust(context.Background(), key, circadianTarget{Temperature: 200}, start)
+
+	changeTestLight(t, c, key, func(l *keylight.KeyLightLight) { l.On = 0 })
+	changeTestLight(t, c, key, switchOn)
+
+	c.adjust(context.Background(), key, circadianTarget{Temperature: 180}, start.Add(time.Minute))
+	if temperature := sim.Options().Lights[0].Temperature; temperature != 180 {
+		t.Errorf("expected switching the light off and on not to pause adjustments, got %d mireds", temperature)
+	}
+}
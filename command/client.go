@@ -0,0 +1,75 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// This file contains calls to the Elgato HTTP API that are not (yet) provided
+// by keylight-go.
+
+// lightURL returns the URL of the given API path on the light.
+func lightURL(light *keylight.KeyLight, path string) string {
+	return fmt.Sprintf("http://%s:%d/%s", light.DNSAddr, light.Port, path)
+}
+
+// lightRequest performs a request against the light, encoding body as JSON if
+// it is non-nil and decoding the response into target if it is non-nil.
+func lightRequest(ctx context.Context, light *keylight.KeyLight, method, path string, body, target interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		buf := new(bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(body); err != nil {
+			return err
+		}
+		reqBody = buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, lightURL(light, path), reqBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response from %s: %s", path, strconv.Quote(resp.Status))
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// updateLightSettings updates the device settings of the light and returns the
+// updated settings.
+func updateLightSettings(ctx context.Context, light *keylight.KeyLight, settings *keylight.KeyLightSettings) (*keylight.KeyLightSettings, error) {
+	s := &keylight.KeyLightSettings{}
+	err := lightRequest(ctx, light, http.MethodPut, "elgato/lights/settings", settings, s)
+	return s, err
+}
+
+// updateDisplayName changes the display name that is configured on the light.
+func updateDisplayName(ctx context.Context, light *keylight.KeyLight, displayName string) error {
+	body := map[string]string{"displayName": displayName}
+	return lightRequest(ctx, light, http.MethodPut, "elgato/accessory-info", body, nil)
+}
+
+// identifyLight asks the light to briefly flash, so that it can be found
+// physically.
+func identifyLight(ctx context.Context, light *keylight.KeyLight) error {
+	return lightRequest(ctx, light, http.MethodPost, "elgato/identify", nil, nil)
+}
@@ -14,6 +14,176 @@ func Commands(metaPtr *Meta) map[string]cli.CommandFactory {
 				Meta: *metaPtr,
 			}, nil
 		},
+		"inventory": func() (cli.Command, error) {
+			return &InventoryCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"inventory refresh": func() (cli.Command, error) {
+			return &InventoryRefreshCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"inventory list": func() (cli.Command, error) {
+			return &InventoryListCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"inventory forget": func() (cli.Command, error) {
+			return &InventoryForgetCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"group": func() (cli.Command, error) {
+			return &GroupCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"group add": func() (cli.Command, error) {
+			return &GroupAddCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"group remove": func() (cli.Command, error) {
+			return &GroupRemoveCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"group list": func() (cli.Command, error) {
+			return &GroupListCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene": func() (cli.Command, error) {
+			return &SceneCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene save": func() (cli.Command, error) {
+			return &SceneSaveCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene apply": func() (cli.Command, error) {
+			return &SceneApplyCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene list": func() (cli.Command, error) {
+			return &SceneListCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene show": func() (cli.Command, error) {
+			return &SceneShowCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"scene delete": func() (cli.Command, error) {
+			return &SceneDeleteCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"set": func() (cli.Command, error) {
+			return &SetCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"settings": func() (cli.Command, error) {
+			return &SettingsCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"settings get": func() (cli.Command, error) {
+			return &SettingsGetCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"settings set": func() (cli.Command, error) {
+			return &SettingsSetCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"rename": func() (cli.Command, error) {
+			return &RenameCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"fade": func() (cli.Command, error) {
+			return &FadeCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"agent": func() (cli.Command, error) {
+			return &AgentCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"watch": func() (cli.Command, error) {
+			return &WatchCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"tui": func() (cli.Command, error) {
+			return &TUICommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"mqtt": func() (cli.Command, error) {
+			return &MQTTCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"exporter": func() (cli.Command, error) {
+			return &ExporterCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"schedule": func() (cli.Command, error) {
+			return &ScheduleCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"schedule list": func() (cli.Command, error) {
+			return &ScheduleListCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"schedule run": func() (cli.Command, error) {
+			return &ScheduleRunCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"circadian": func() (cli.Command, error) {
+			return &CircadianCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"camera-watch": func() (cli.Command, error) {
+			return &CameraWatchCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"simulate": func() (cli.Command, error) {
+			return &SimulateCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"identify": func() (cli.Command, error) {
+			return &IdentifyCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"config": func() (cli.Command, error) {
+			return &ConfigCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
+		"config show": func() (cli.Command, error) {
+			return &ConfigShowCommand{
+				Meta: *metaPtr,
+			}, nil
+		},
 		"describe": func() (cli.Command, error) {
 			return &DescribeCommand{
 				Meta: *metaPtr,
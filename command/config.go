@@ -0,0 +1,461 @@
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configFileName is the name of the global configuration within the
+	// config dir.
+	configFileName = "config.yaml"
+
+	// configFileEnvVar overrides the location of the global configuration.
+	configFileEnvVar = "KEYLIGHTCTL_CONFIG"
+
+	// configProfileEnvVar selects a profile when -profile is not provided.
+	configProfileEnvVar = "KEYLIGHTCTL_PROFILE"
+
+	// configEnvVarPrefix is prepended to the upper cased name of a setting to
+	// override it from the environment, e.g: KEYLIGHTCTL_TIMEOUT.
+	configEnvVarPrefix = "KEYLIGHTCTL_"
+)
+
+// The names of the settings that may be provided in the config file, in the
+// order they are shown by config show.
+const (
+	configTimeout = "timeout"
+	configLights  = "lights"
+	configGroups  = "groups"
+	configAll     = "all"
+	configFormat  = "format"
+	configColor   = "color"
+)
+
+var configSettingNames = []string{configTimeout, configLights, configGroups, configAll, configFormat, configColor}
+
+// configFlagNames are the flags that each setting provides the default for.
+var configFlagNames = map[string]string{
+	configTimeout: "timeout",
+	configLights:  "light",
+	configGroups:  "group",
+	configAll:     "all",
+	configFormat:  "format",
+	configColor:   "no-color",
+}
+
+// configSelectionSettings are replaced together, so that e.g. a profile that
+// sets lights is not combined with all from the top level of the file.
+var configSelectionSettings = map[string]bool{configLights: true, configGroups: true, configAll: true}
+
+// configSettings are the defaults for the flags of commands. Settings that are
+// not provided are left to the command.
+type configSettings struct {
+	Timeout string   `yaml:"timeout,omitempty"`
+	Lights  []string `yaml:"lights,omitempty"`
+	Groups  []string `yaml:"groups,omitempty"`
+	All     *bool    `yaml:"all,omitempty"`
+	Format  string   `yaml:"format,omitempty"`
+	Color   *bool    `yaml:"color,omitempty"`
+}
+
+// configLayer is a set of settings with optional overrides for individual
+// commands, keyed by the full command name, e.g: `scene save`.
+type configLayer struct {
+	configSettings `yaml:",inline"`
+
+	Commands map[string]*configSettings `yaml:"commands,omitempty"`
+}
+
+// configFile is the global configuration of keylightctl, e.g:
+//
+//	timeout: 2s
+//	lights: ["@desk"]
+//	commands:
+//	  describe:
+//	    format: json
+//	profile: home
+//	profiles:
+//	  office:
+//	    groups: [office]
+//	    color: false
+type configFile struct {
+	configLayer `yaml:",inline"`
+
+	// Profile is the profile that is used when none is selected with
+	// -profile or KEYLIGHTCTL_PROFILE.
+	Profile  string                  `yaml:"profile,omitempty"`
+	Profiles map[string]*configLayer `yaml:"profiles,omitempty"`
+
+	path   string
+	exists bool
+}
+
+// configFilePaths returns the locations that the config file is read from, in
+// order of preference: the user config dir, followed by the system wide XDG
+// config dirs.
+func configFilePaths() ([]string, error) {
+	path, err := configFilePath(configFileName)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir != "" {
+			paths = append(paths, filepath.Join(dir, configDirName, configFileName))
+		}
+	}
+
+	return paths, nil
+}
+
+// loadConfigFile reads the config file from KEYLIGHTCTL_CONFIG, or the first
+// XDG location that exists. A missing file is only an error if it was
+// provided explicitly, and otherwise results in an empty configuration.
+func loadConfigFile() (*configFile, error) {
+	paths := []string{os.Getenv(configFileEnvVar)}
+	explicit := paths[0] != ""
+	if !explicit {
+		var err error
+		paths, err = configFilePaths()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range paths {
+		cfg := &configFile{path: path}
+		err := readYAMLFile(path, cfg)
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config, err: %w", err)
+		}
+
+		cfg.exists = true
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config %s, err: %w", path, err)
+		}
+		return cfg, nil
+	}
+
+	return &configFile{path: paths[0]}, nil
+}
+
+// validate checks every layer of the file so that mistakes are reported
+// regardless of the command or profile in use.
+func (c *configFile) validate() error {
+	if err := c.configLayer.validate(); err != nil {
+		return err
+	}
+	for name, profile := range c.Profiles {
+		if profile == nil {
+			continue
+		}
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("profile '%s': %w", name, err)
+		}
+	}
+	if c.Profile != "" && c.Profiles[c.Profile] == nil {
+		return fmt.Errorf("unknown default profile '%s'", c.Profile)
+	}
+	return nil
+}
+
+func (l *configLayer) validate() error {
+	if err := l.configSettings.validate(); err != nil {
+		return err
+	}
+	for name, settings := range l.Commands {
+		if settings == nil {
+			continue
+		}
+		if err := settings.validate(); err != nil {
+			return fmt.Errorf("command '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *configSettings) validate() error {
+	if s.Timeout != "" {
+		if _, err := time.ParseDuration(s.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout '%s', err: %w", s.Timeout, err)
+		}
+	}
+	if s.All != nil && *s.All && (len(s.Lights) != 0 || len(s.Groups) != 0) {
+		return errors.New("all cannot be combined with lights or groups")
+	}
+	return nil
+}
+
+// values returns the settings that are provided as flag values. Lights and
+// groups have a value per member.
+func (s *configSettings) values() map[string][]string {
+	values := make(map[string][]string)
+	if s == nil {
+		return values
+	}
+	if s.Timeout != "" {
+		values[configTimeout] = []string{s.Timeout}
+	}
+	if len(s.Lights) != 0 {
+		values[configLights] = s.Lights
+	}
+	if len(s.Groups) != 0 {
+		values[configGroups] = s.Groups
+	}
+	if s.All != nil {
+		values[configAll] = []string{strconv.FormatBool(*s.All)}
+	}
+	if s.Format != "" {
+		values[configFormat] = []string{s.Format}
+	}
+	if s.Color != nil {
+		values[configColor] = []string{strconv.FormatBool(*s.Color)}
+	}
+	return values
+}
+
+// configValue is the effective value of a single setting.
+type configValue struct {
+	Setting string
+	Value   string
+	Source  string
+
+	values []string
+}
+
+func newConfigValue(setting string, values []string, source string) *configValue {
+	return &configValue{
+		Setting: setting,
+		Value:   strings.Join(values, ", "),
+		Source:  source,
+		values:  values,
+	}
+}
+
+// effectiveConfig is the result of merging the defaults, the config file, the
+// selected profile and the environment for a single command.
+type effectiveConfig struct {
+	Path          string
+	Exists        bool
+	Profile       string
+	ProfileSource string
+
+	values map[string]*configValue
+}
+
+// configDefaults are the values that commands use when a setting is not
+// provided. Individual commands may differ, e.g. by defaulting to all lights.
+var configDefaults = map[string][]string{
+	configTimeout: {"5s"},
+	configAll:     {"false"},
+	configFormat:  {formatTable},
+	configColor:   {"true"},
+}
+
+// loadEffectiveConfig merges, from lowest to highest precedence: the defaults,
+// the config file, its section for the command, the selected profile, its
+// section for the command, and environment variables. The profile is taken
+// from the profile argument (i.e. the -profile flag), KEYLIGHTCTL_PROFILE or
+// the config file, in that order. An empty command only merges the settings
+// that apply to every command.
+func loadEffectiveConfig(command, profile string) (*effectiveConfig, error) {
+	file, err := loadConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &effectiveConfig{
+		Path:   file.path,
+		Exists: file.exists,
+		values: make(map[string]*configValue),
+	}
+	for _, name := range configSettingNames {
+		cfg.values[name] = newConfigValue(name, configDefaults[name], "default")
+	}
+
+	switch {
+	case profile != "":
+		cfg.Profile, cfg.ProfileSource = profile, "-profile flag"
+	case os.Getenv(configProfileEnvVar) != "":
+		cfg.Profile, cfg.ProfileSource = os.Getenv(configProfileEnvVar), "env "+configProfileEnvVar
+	case file.Profile != "":
+		cfg.Profile, cfg.ProfileSource = file.Profile, "config file"
+	}
+
+	cfg.merge(file.configSettings.values(), "config file")
+	if command != "" {
+		cfg.merge(file.Commands[command].values(), fmt.Sprintf("config file (commands.%s)", command))
+	}
+
+	if cfg.Profile != "" {
+		layer := file.Profiles[cfg.Profile]
+		if layer == nil {
+			return nil, fmt.Errorf("unknown profile '%s'", cfg.Profile)
+		}
+
+		source := fmt.Sprintf("profile %s", cfg.Profile)
+		cfg.merge(layer.configSettings.values(), source)
+		if command != "" {
+			cfg.merge(layer.Commands[command].values(), fmt.Sprintf("%s (commands.%s)", source, command))
+		}
+	}
+
+	env, err := configEnvValues()
+	if err != nil {
+		return nil, err
+	}
+	cfg.mergeFrom(env, func(name string) string {
+		return "env " + configEnvVarPrefix + strings.ToUpper(name)
+	})
+
+	return cfg, nil
+}
+
+// merge overrides the current values with the provided ones. When any of the
+// selection settings is provided, the others are reset so that the selection
+// is taken from a single source.
+func (c *effectiveConfig) merge(values map[string][]string, source string) {
+	c.mergeFrom(values, func(string) string { return source })
+}
+
+// mergeFrom is like merge, but for a layer whose settings each have their own
+// source, e.g: environment variables. The selection is reset once for the
+// whole layer, so that its selection settings are combined.
+func (c *effectiveConfig) mergeFrom(values map[string][]string, source func(name string) string) {
+	for _, name := range configSettingNames {
+		if _, ok := values[name]; !ok || !configSelectionSettings[name] {
+			continue
+		}
+		for selection := range configSelectionSettings {
+			c.values[selection] = newConfigValue(selection, configDefaults[selection], source(name))
+		}
+		break
+	}
+
+	for name, value := range values {
+		c.values[name] = newConfigValue(name, value, source(name))
+	}
+}
+
+// configEnvValues returns the settings that are overridden by environment
+// variables, e.g: KEYLIGHTCTL_LIGHTS=111A,@desk or KEYLIGHTCTL_COLOR=false.
+// Lights and groups are separated by commas.
+func configEnvValues() (map[string][]string, error) {
+	values := make(map[string][]string)
+	for _, name := range configSettingNames {
+		key := configEnvVarPrefix + strings.ToUpper(name)
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+
+		switch name {
+		case configTimeout:
+			if _, err := time.ParseDuration(value); err != nil {
+				return nil, fmt.Errorf("invalid %s '%s', err: %w", key, value, err)
+			}
+		case configAll, configColor:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s', err: %w", key, value, err)
+			}
+			value = strconv.FormatBool(b)
+		case configLights, configGroups:
+			values[name] = strings.Split(value, ",")
+			continue
+		}
+		values[name] = []string{value}
+	}
+	return values, nil
+}
+
+// Values returns every setting in a stable order.
+func (c *effectiveConfig) Values() []*configValue {
+	var result []*configValue
+	for _, name := range configSettingNames {
+		result = append(result, c.values[name])
+	}
+	return result
+}
+
+// Color returns whether colored output is enabled.
+func (c *effectiveConfig) Color() bool {
+	color, _ := strconv.ParseBool(c.values[configColor].Value)
+	return color
+}
+
+// Apply sets every flag of f that has a configured value and was not provided
+// on the command line. The selection settings are only applied to the flags of
+// a lightSelection, and only if none of -light, -group or -all were provided.
+func (c *effectiveConfig) Apply(f *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	f.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+	explicitSelection := explicit["light"] || explicit["group"] || explicit["all"]
+
+	for _, name := range configSettingNames {
+		value := c.values[name]
+		flagName := configFlagNames[name]
+		fl := f.Lookup(flagName)
+		if value.Source == "default" || explicit[flagName] || fl == nil {
+			continue
+		}
+		if configSelectionSettings[name] && (explicitSelection || !isSelectionFlag(fl)) {
+			continue
+		}
+
+		values := value.values
+		if name == configColor {
+			color, _ := strconv.ParseBool(value.Value)
+			values = []string{strconv.FormatBool(!color)}
+		}
+
+		for _, v := range values {
+			if err := f.Set(flagName, v); err != nil {
+				return fmt.Errorf("invalid %s '%s' from %s, err: %w", name, value.Value, value.Source, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ColorEnabled returns whether colored output is enabled by the configuration,
+// taking a -profile flag in args into account. -no-color is handled by the
+// caller, and an invalid configuration is reported by the command itself.
+func ColorEnabled(args []string) bool {
+	var profile string
+	for idx, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if strings.HasPrefix(name, "profile=") {
+			profile = strings.TrimPrefix(name, "profile=")
+		} else if name == "profile" && idx+1 < len(args) {
+			profile = args[idx+1]
+		}
+	}
+
+	cfg, err := loadEffectiveConfig("", profile)
+	if err != nil {
+		return true
+	}
+	return cfg.Color()
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type ConfigCommand struct {
+	Meta
+}
+
+func (c *ConfigCommand) Help() string {
+	helpText := `
+Usage: keylightctl config <subcommand> [options] [args]
+
+ This command groups subcommands for inspecting the keylightctl configuration.
+
+ Defaults for the flags of every command are read from config.yaml in the
+ keylightctl config dir, the system wide XDG config dirs, e.g:
+ /etc/xdg/keylightctl/config.yaml, or the file set in KEYLIGHTCTL_CONFIG:
+
+     timeout: 2s
+     lights: ["@desk"]
+     commands:
+       describe:
+         format: json
+     profiles:
+       office:
+         groups: [office]
+         color: false
+
+ The supported settings are timeout, lights, groups, all, format and color.
+ The lights, groups and all settings never apply to rename or inventory
+ forget, which only operate on the lights that are named on the command line.
+ Settings under commands only apply to the named command, e.g: scene save,
+ and profiles are selected with -profile, KEYLIGHTCTL_PROFILE, or the profile
+ setting of the file.
+
+ Every setting can be overridden with an environment variable, e.g:
+ KEYLIGHTCTL_TIMEOUT=10s or KEYLIGHTCTL_LIGHTS=111A,861A, and flags that are
+ provided on the command line always take precedence.
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *ConfigCommand) Synopsis() string {
+	return "Inspect the keylightctl configuration"
+}
+
+func (f *ConfigCommand) Name() string { return "config" }
+
+func (c *ConfigCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
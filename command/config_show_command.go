@@ -0,0 +1,122 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type ConfigShowCommand struct {
+	Meta
+}
+
+func (c *ConfigShowCommand) Help() string {
+	helpText := `
+Usage: keylightctl config show [options] [command]
+
+ Show the effective configuration after merging the defaults, the config file,
+ the selected profile and environment variables, and where each value came
+ from. When a command is provided, e.g: describe or "scene save", the settings
+ for that command are included.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Config Show Options:
+
+  ` + formatOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *ConfigShowCommand) Synopsis() string {
+	return "Show the effective configuration"
+}
+
+func (f *ConfigShowCommand) Name() string { return "config show" }
+
+func (c *ConfigShowCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var output recordWriter
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	output.AddFlags(flags)
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l > 1 {
+		c.UI.Error("This command takes at most (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := output.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	var command string
+	if len(args) == 1 {
+		command = args[0]
+		if _, ok := Commands(&c.Meta)[command]; !ok {
+			c.UI.Error(fmt.Sprintf("Unknown command '%s'", command))
+			return 1
+		}
+	}
+
+	cfg, err := loadEffectiveConfig(command, c.Meta.profile)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if !output.IsTable() {
+		var records []configRecord
+		for _, value := range cfg.Values() {
+			records = append(records, newConfigRecord(value))
+		}
+
+		if err := output.Write(os.Stdout, records); err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to write output, err: %v", err))
+			return 1
+		}
+		return 0
+	}
+
+	if cfg.Exists {
+		c.UI.Output(fmt.Sprintf("Config file: %s", cfg.Path))
+	} else {
+		c.UI.Output(fmt.Sprintf("Config file: %s (not found)", cfg.Path))
+	}
+	if cfg.Profile != "" {
+		c.UI.Output(fmt.Sprintf("Profile: %s (from %s)", cfg.Profile, cfg.ProfileSource))
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Setting", "Value", "Source"})
+
+	for _, value := range cfg.Values() {
+		t.AppendRows([]table.Row{
+			{value.Setting, value.Value, value.Source},
+		})
+	}
+	t.Render()
+
+	return 0
+}
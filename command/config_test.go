@@ -0,0 +1,91 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig replaces the global config file with contents.
+func writeConfig(t *testing.T, contents string) {
+	t.Helper()
+
+	path, err := configFilePath(configFileName)
+	if err != nil {
+		t.Fatalf("failed to find the config file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectConfigValue fails the test if the effective value of a setting does
+// not match.
+func expectConfigValue(t *testing.T, cfg *effectiveConfig, setting, value, source string) {
+	t.Helper()
+
+	got := cfg.values[setting]
+	if got.Value != value || got.Source != source {
+		t.Errorf("%s: expected %q from %s, got %q from %s", setting, value, source, got.Value, got.Source)
+	}
+}
+
+func TestLoadEffectiveConfig_Layers(t *testing.T) {
+	setupTestConfig(t)
+	writeConfig(t, `
+timeout: 2s
+lights: [111A]
+commands:
+  switch:
+    format: json
+profile: office
+profiles:
+  office:
+    groups: [desk]
+    commands:
+      switch:
+        timeout: 3s
+`)
+
+	cfg, err := loadEffectiveConfig("switch", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectConfigValue(t, cfg, configTimeout, "3s", "profile office (commands.switch)")
+	expectConfigValue(t, cfg, configFormat, "json", "config file (commands.switch)")
+	expectConfigValue(t, cfg, configGroups, "desk", "profile office")
+	expectConfigValue(t, cfg, configLights, "", "profile office")
+	expectConfigValue(t, cfg, configColor, "true", "default")
+}
+
+func TestLoadEffectiveConfig_EnvSelection(t *testing.T) {
+	setupTestConfig(t)
+	writeConfig(t, "all: true\n")
+	t.Setenv(configEnvVarPrefix+"LIGHTS", "111A,222B")
+	t.Setenv(configEnvVarPrefix+"GROUPS", "desk")
+
+	// The environment is a map, so check several times that neither of the
+	// selection settings resets the other.
+	for i := 0; i < 20; i++ {
+		cfg, err := loadEffectiveConfig("", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expectConfigValue(t, cfg, configLights, "111A, 222B", "env KEYLIGHTCTL_LIGHTS")
+		expectConfigValue(t, cfg, configGroups, "desk", "env KEYLIGHTCTL_GROUPS")
+		expectConfigValue(t, cfg, configAll, "false", "env KEYLIGHTCTL_LIGHTS")
+	}
+}
+
+func TestLoadEffectiveConfig_UnknownProfile(t *testing.T) {
+	setupTestConfig(t)
+
+	if _, err := loadEffectiveConfig("", "missing"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,7 +21,9 @@ func (c *DescribeCommand) Help() string {
 	helpText := `
 Usage: keylightctl describe [options]
 
- Describe the current state of the detected keylights
+ Describe the current state of the detected keylights. Accessories that expose
+ several lights are shown with a row per light, and a single light can be
+ described with -light <light>#<index>, e.g: 111A#1.
 
 General Options:
 
@@ -34,10 +37,19 @@ Describe Specific Options:
   -all
     Describe all keylights that are discovered within the timeout window
 
-  -light <light-id>
-    Modify the provided light ID. Can either be a full key light name, e.g:
-    Elgato\ Key\ Light\ 111A, or a short ID, e.g: 111A. -light can be provided
-    multiple times and all provided lights will be described.
+  ` + lightOptionUsage("Describe") + `
+
+  -group <group>
+    Describe all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -detailed
+    Also describe the accessory info (product, firmware, serial number and
+    display name) and the device settings of each light.
+
+  ` + fanOutOptionsUsage() + `
+
+  ` + formatOptionsUsage() + `
 `
 	return strings.TrimSpace(helpText)
 }
@@ -57,16 +69,21 @@ func (c *DescribeCommand) Run(args []string) int {
 	}
 
 	var timeout time.Duration
-	var lights lightListFlags
-	var allLights bool
+	var selection lightSelection
+	var fanOut lightFanOut
+	var output recordWriter
+	var detailed bool
 
 	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
 	flags.Usage = func() { c.UI.Output(c.Help()) }
 	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
-	flags.Var(&lights, "light", "")
-	flags.BoolVar(&allLights, "all", false, "")
+	selection.AddFlags(flags)
+	selection.AllowChannels = true
+	fanOut.AddFlags(flags)
+	output.AddFlags(flags)
+	flags.BoolVar(&detailed, "detailed", false, "")
 
-	if err := flags.Parse(args); err != nil {
+	if err := c.Meta.parseFlags(flags, args); err != nil {
 		return 1
 	}
 
@@ -77,36 +94,30 @@ func (c *DescribeCommand) Run(args []string) int {
 		return 1
 	}
 
-	if allLights && len(lights) != 0 {
-		c.UI.Error("Cannot specify --all and --light")
+	if err := output.Validate(); err != nil {
+		c.UI.Error(err.Error())
 		c.UI.Error(commandErrorText(c))
 		return 1
 	}
 
-	if !allLights && len(lights) == 0 {
-		c.UI.Error("Must specify one of --all and --light")
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
 		c.UI.Error(commandErrorText(c))
 		return 1
 	}
 
-	discovery, err := keylight.NewDiscovery()
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("Failed to setup mDNS discovery, err: %v", err))
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
 		return 1
 	}
 
-	discoverer := lightDiscoverer{
-		Discovery:      discovery,
-		AllLights:      allLights,
-		RequiredLights: lights,
-	}
-
 	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
 	defer cancelFn()
 
-	found, err := discoverer.Run(discoveryCtx)
+	found, err := selection.Resolve(discoveryCtx, c.UI)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Failed to discover lights, err: %v", err))
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
 		return 1
 	}
 
@@ -118,26 +129,161 @@ func (c *DescribeCommand) Run(args []string) int {
 	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
 	defer updateCancelFn()
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"#", "Name", "Power State", "Brightness", "Temperature", "Address"})
+	sort.Slice(found, func(i, j int) bool {
+		return lightLabel(found[i]) < lightLabel(found[j])
+	})
 
-	for idx, light := range found {
-		opts, err := light.FetchLightOptions(updateCtx)
+	if detailed {
+		return c.describeDetailed(updateCtx, found, selection.Channels(), &fanOut, &output)
+	}
+
+	lightRecords := make([][]lightStateRecord, len(found))
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		opts, err := light.FetchLightOptions(ctx)
 		if err != nil {
-			c.UI.Error(fmt.Sprintf("Failed to fetch light options (%s), err: %v", light.Name, err))
+			return fmt.Errorf("failed to fetch light options, err: %w", err)
+		}
+
+		displayName := fetchDisplayName(ctx, light)
+		channels := selection.Channels().Get(light)
+		if _, err := selectChannels(opts, channels); err != nil {
+			return err
+		}
+		lightRecords[idx] = newLightStateRecords(light, displayName, opts, channels)
+		return nil
+	})
+
+	var records []lightStateRecord
+	for _, r := range lightRecords {
+		records = append(records, r...)
+	}
+
+	if !output.IsTable() {
+		if err := output.Write(os.Stdout, records); err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to write output, err: %v", err))
 			return 1
 		}
-		powerState := "off"
-		if opts.Lights[0].On == 1 {
-			powerState = "on"
+		return reportFetchErrors(c.UI, results)
+	}
+
+	renderStateTable(records)
+
+	return reportFetchErrors(c.UI, results)
+}
+
+// describeDetailed fetches the full details of every light concurrently and
+// renders them along with the accessory info and device settings.
+func (c *DescribeCommand) describeDetailed(ctx context.Context, found []*keylight.KeyLight, channels lightChannels, fanOut *lightFanOut, output *recordWriter) int {
+	allDetails := make([]*lightDetails, len(found))
+	results := fanOut.Run(ctx, found, func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		details, err := fetchLightDetails(ctx, light)
+		if err != nil {
+			return err
+		}
+		if _, err := selectChannels(details.Options, channels.Get(light)); err != nil {
+			return err
+		}
+		allDetails[idx] = details
+		return nil
+	})
+
+	// Only describe the lights that were fetched successfully, the failures
+	// are reported once the output has been rendered.
+	var described []*keylight.KeyLight
+	var details []*lightDetails
+	var records []lightDetailRecord
+	for idx, light := range found {
+		if allDetails[idx] == nil {
+			continue
+		}
+		described = append(described, light)
+		details = append(details, allDetails[idx])
+		records = append(records, newLightDetailRecords(light, allDetails[idx], channels.Get(light))...)
+	}
+
+	if !output.IsTable() {
+		if err := output.Write(os.Stdout, records); err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to write output, err: %v", err))
+			return 1
 		}
+		return reportFetchErrors(c.UI, results)
+	}
+
+	var states []lightStateRecord
+	for _, r := range records {
+		states = append(states, r.lightStateRecord)
+	}
+	renderStateTable(states)
+
+	info := table.NewWriter()
+	info.SetOutputMirror(os.Stdout)
+	info.SetTitle("Accessory Info")
+	info.AppendHeader(table.Row{"Name", "Product", "Serial Number", "Firmware", "Features"})
+
+	var settings []lightSettingsRecord
+	for idx, light := range described {
+		d := details[idx]
+		info.AppendRow(table.Row{
+			lightLabel(light),
+			d.Info.ProductName,
+			d.Info.SerialNumber,
+			fmt.Sprintf("%s (%d)", d.Info.FirmwareVersion, d.Info.FirmwareBuildNumber),
+			strings.Join(d.Info.Features, ", "),
+		})
+		settings = append(settings, newLightSettingsRecord(light, d.Settings))
+	}
+	info.Render()
+	renderSettingsTable(settings, "Settings")
+
+	return reportFetchErrors(c.UI, results)
+}
 
+// renderStateTable renders the state of each light as a table on stdout. The
+// lights of accessories that expose several lights are shown as separate rows,
+// named with their index, e.g: `Elgato\ Key\ Light\ 111A#1`.
+func renderStateTable(records []lightStateRecord) {
+	counts := make(map[string]int)
+	for _, r := range records {
+		counts[fmt.Sprintf("%s:%d", r.Address, r.Port)]++
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "Name", "Display Name", "Power State", "Brightness", "Temperature", "Kelvin", "Address"})
+
+	for idx, r := range records {
+		address := fmt.Sprintf("%s:%d", r.Address, r.Port)
+		name := r.Name
+		if r.LightIndex > 0 || counts[address] > 1 {
+			name = fmt.Sprintf("%s%s%d", name, lightChannelSeparator, r.LightIndex)
+		}
 		t.AppendRows([]table.Row{
-			{idx, light.Name, powerState, opts.Lights[0].Brightness, opts.Lights[0].Temperature, fmt.Sprintf("%s:%d", light.DNSAddr, light.Port)},
+			{idx, name, r.DisplayName, r.Power, r.Brightness, r.Temperature, formatKelvin(r.Temperature), address},
 		})
 	}
 	t.Render()
+}
 
-	return 0
+// renderSettingsTable renders the device settings of each light as a table on
+// stdout, with an optional title.
+func renderSettingsTable(records []lightSettingsRecord, title string) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	if title != "" {
+		t.SetTitle(title)
+	}
+	t.AppendHeader(table.Row{"Name", "Power On Behavior", "Power On Brightness", "Power On Temperature", "Switch On", "Switch Off", "Color Change"})
+
+	for _, r := range records {
+		t.AppendRow(table.Row{
+			r.Name,
+			r.PowerOnBehavior,
+			r.PowerOnBrightness,
+			fmt.Sprintf("%d (%s)", r.PowerOnTemperature, formatKelvin(r.PowerOnTemperature)),
+			time.Duration(r.SwitchOnDurationMs) * time.Millisecond,
+			time.Duration(r.SwitchOffDurationMs) * time.Millisecond,
+			time.Duration(r.ColorChangeDurationMs) * time.Millisecond,
+		})
+	}
+	t.Render()
 }
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestDescribeCommand_JSON(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{Lights: 2, DisplayName: "Desk", SerialNumber: "BW33J1A02740"})
+
+	ui := cli.NewMockUi()
+	cmd := &DescribeCommand{Meta: Meta{UI: ui}}
+
+	out := captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr, "-format", "json")
+	})
+
+	var records []lightStateRecord
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("failed to decode output %q: %v", out, err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	for idx, record := range records {
+		if record.LightIndex != idx {
+			t.Errorf("record %d: expected light index %d, got %d", idx, idx, record.LightIndex)
+		}
+		if record.DisplayName != "Desk" || record.Power != "off" || record.Brightness != 20 || record.Temperature != 213 {
+			t.Errorf("record %d: unexpected record %+v", idx, record)
+		}
+		if record.Name != `Elgato\ Key\ Light\ BW33J1A02740` {
+			t.Errorf("record %d: unexpected name %q", idx, record.Name)
+		}
+	}
+}
+
+func TestDescribeCommand_Channel(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	cmd := &DescribeCommand{Meta: Meta{UI: ui}}
+
+	out := captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr+"#1", "-format", "json")
+	})
+
+	var records []lightStateRecord
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("failed to decode output %q: %v", out, err)
+	}
+	if len(records) != 1 || records[0].LightIndex != 1 {
+		t.Errorf("expected a single record for light 1, got %+v", records)
+	}
+}
+
+func TestDescribeCommand_Table(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{DisplayName: "Desk"})
+
+	ui := cli.NewMockUi()
+	cmd := &DescribeCommand{Meta: Meta{UI: ui}}
+
+	out := captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr)
+	})
+
+	for _, expected := range []string{"Desk", "off", addr} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestDescribeCommand_Unreachable(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{FailureRate: 1})
+
+	ui := cli.NewMockUi()
+	cmd := &DescribeCommand{Meta: Meta{UI: ui}}
+
+	captureStdout(t, func() {
+		runCommand(t, cmd, ui, 1, "-light", addr, "-format", "json")
+	})
+}
+
+func TestDescribeCommand_CSVAndTemplate(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{Lights: 2, DisplayName: "Desk"})
+
+	ui := cli.NewMockUi()
+	cmd := &DescribeCommand{Meta: Meta{UI: ui}}
+
+	out := captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr, "-format", "csv")
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "name,short_id,display_name,address,port,light_index,") {
+		t.Fatalf("expected a header and a row per light, got:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr, "-format", "template", "-template", "{{ .DisplayName }}#{{ .LightIndex }} {{ .Power }}")
+	})
+	if out != "Desk#0 off\nDesk#1 off\n" {
+		t.Errorf("expected the template to be rendered for each light, got %q", out)
+	}
+
+	runCommand(t, cmd, ui, 1, "-light", addr, "-format", "xml")
+}
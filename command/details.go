@@ -0,0 +1,52 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// lightDetails is the complete state of an accessory: the state of its
+// lights, its accessory info and its device settings.
+type lightDetails struct {
+	Options  *keylight.KeyLightOptions
+	Info     *keylight.AccessoryInfo
+	Settings *keylight.KeyLightSettings
+}
+
+// fetchLightDetails concurrently fetches the light options, accessory info
+// and device settings of the given light.
+func fetchLightDetails(ctx context.Context, light *keylight.KeyLight) (*lightDetails, error) {
+	var wg sync.WaitGroup
+	var optsErr, infoErr, settingsErr error
+	details := &lightDetails{}
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		details.Options, optsErr = light.FetchLightOptions(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		details.Info, infoErr = light.FetchAccessoryInfo(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		details.Settings, settingsErr = light.FetchSettings(ctx)
+	}()
+	wg.Wait()
+
+	if optsErr != nil {
+		return nil, fmt.Errorf("failed to fetch light options, err: %w", optsErr)
+	}
+	if infoErr != nil {
+		return nil, fmt.Errorf("failed to fetch accessory info, err: %w", infoErr)
+	}
+	if settingsErr != nil {
+		return nil, fmt.Errorf("failed to fetch settings, err: %w", settingsErr)
+	}
+
+	return details, nil
+}
@@ -3,7 +3,10 @@ package command
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/endocrimes/keylight-go"
@@ -29,6 +32,7 @@ Discover Specific Options:
   -timeout <duration>
     Sets the maximum time to listen for accessories (default: 5s)
 
+  ` + formatOptionsUsage() + `
 `
 	return strings.TrimSpace(helpText)
 }
@@ -48,12 +52,14 @@ func (c *DiscoverCommand) Run(args []string) int {
 	}
 
 	var timeout string
+	var output recordWriter
 
 	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
 	flags.Usage = func() { c.UI.Output(c.Help()) }
 	flags.StringVar(&timeout, "timeout", "5s", "")
+	output.AddFlags(flags)
 
-	if err := flags.Parse(args); err != nil {
+	if err := c.Meta.parseFlags(flags, args); err != nil {
 		return 1
 	}
 
@@ -64,6 +70,12 @@ func (c *DiscoverCommand) Run(args []string) int {
 		return 1
 	}
 
+	if err := output.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
 	timeoutDuration, err := time.ParseDuration(timeout)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Failed to parse timeout, err: %v", err))
@@ -85,12 +97,40 @@ func (c *DiscoverCommand) Run(args []string) int {
 
 	results := discovery.ResultsCh()
 
-	c.UI.Info("Starting discovery")
+	if output.IsTable() {
+		c.UI.Info("Starting discovery")
+	}
+
+	// Display names are fetched concurrently while we keep draining the
+	// discovery results, so that slow lights neither delay the others nor
+	// block discovery on a full results channel.
+	fetchedCh := make(chan discoveredLightRecord)
+	go func() {
+		var wg sync.WaitGroup
+		for a := range results {
+			wg.Add(1)
+			go func(a *keylight.KeyLight) {
+				defer wg.Done()
+				fetchedCh <- newDiscoveredLightRecord(a, fetchDisplayName(context.Background(), a))
+			}(a)
+		}
+		wg.Wait()
+		close(fetchedCh)
+	}()
 
 	count := 0
-	for a := range results {
+	var records []discoveredLightRecord
+	for record := range fetchedCh {
 		count++
-		c.UI.Output(fmt.Sprintf("- %s", a.Name))
+		if output.IsTable() {
+			if record.DisplayName != "" {
+				c.UI.Output(fmt.Sprintf("- %s (%s)", record.Name, record.DisplayName))
+			} else {
+				c.UI.Output(fmt.Sprintf("- %s", record.Name))
+			}
+			continue
+		}
+		records = append(records, record)
 	}
 
 	if count == 0 {
@@ -98,6 +138,18 @@ func (c *DiscoverCommand) Run(args []string) int {
 		return 1
 	}
 
+	if !output.IsTable() {
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Name < records[j].Name
+		})
+
+		if err := output.Write(os.Stdout, records); err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to write output, err: %v", err))
+			return 1
+		}
+		return 0
+	}
+
 	c.UI.Info(fmt.Sprintf("Found %d light(s) during discovery", count))
 
 	return 0
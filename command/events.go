@@ -0,0 +1,113 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// The types of lightEvent.
+const (
+	lightEventAppeared    = "appeared"
+	lightEventDisappeared = "disappeared"
+	lightEventChanged     = "changed"
+)
+
+// lightEvent describes a change in the availability or state of a light.
+// Changed events are emitted for each field that changed, with From and To
+// holding the previous and current value.
+type lightEvent struct {
+	discoveredLightRecord
+
+	Time       time.Time         `json:"time"`
+	Type       string            `json:"type"`
+	LightIndex int               `json:"light_index"`
+	Field      string            `json:"field,omitempty"`
+	From       interface{}       `json:"from,omitempty"`
+	To         interface{}       `json:"to,omitempty"`
+	State      []agentLightState `json:"state,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// String returns the single line text representation of the event.
+func (e *lightEvent) String() string {
+	label := e.Name
+	if label == "" {
+		label = fmt.Sprintf("%s:%d", e.Address, e.Port)
+	}
+	if e.DisplayName != "" {
+		label = fmt.Sprintf("%s (%s)", label, e.DisplayName)
+	}
+
+	prefix := fmt.Sprintf("%s %-11s %s", e.Time.Format(time.RFC3339), e.Type, label)
+	switch e.Type {
+	case lightEventAppeared:
+		var states []string
+		for idx, s := range e.State {
+			states = append(states, fmt.Sprintf("#%d %s %d%% %s", idx, s.Power, s.Brightness, formatKelvin(s.Temperature)))
+		}
+		return fmt.Sprintf("%s at %s:%d: %s", prefix, e.Address, e.Port, strings.Join(states, ", "))
+	case lightEventDisappeared:
+		return fmt.Sprintf("%s: %s", prefix, e.Error)
+	default:
+		return fmt.Sprintf("%s #%d %s: %v -> %v", prefix, e.LightIndex, e.Field, e.From, e.To)
+	}
+}
+
+// newLightEvent returns an event of the given type for the given light.
+func newLightEvent(eventType string, light *keylight.KeyLight, displayName string) *lightEvent {
+	return &lightEvent{
+		discoveredLightRecord: newDiscoveredLightRecord(light, displayName),
+		Time:                  time.Now().UTC(),
+		Type:                  eventType,
+	}
+}
+
+// diffLightOptions returns a changed event for every field that differs
+// between the previous and current options of a light.
+func diffLightOptions(light *keylight.KeyLight, displayName string, previous, current *keylight.KeyLightOptions) []*lightEvent {
+	var result []*lightEvent
+	changed := func(idx int, field string, from, to interface{}) {
+		event := newLightEvent(lightEventChanged, light, displayName)
+		event.LightIndex = idx
+		event.Field = field
+		event.From = from
+		event.To = to
+		result = append(result, event)
+	}
+
+	for idx, cur := range current.Lights {
+		if idx >= len(previous.Lights) {
+			break
+		}
+		prev := previous.Lights[idx]
+
+		if prev.On != cur.On {
+			changed(idx, "power", powerStateString(prev.On), powerStateString(cur.On))
+		}
+		if prev.Brightness != cur.Brightness {
+			changed(idx, "brightness", prev.Brightness, cur.Brightness)
+		}
+		if prev.Temperature != cur.Temperature {
+			changed(idx, "temperature", prev.Temperature, cur.Temperature)
+		}
+	}
+
+	return result
+}
+
+// lightStates returns the API representation of the given light options.
+func lightStates(opts *keylight.KeyLightOptions) []agentLightState {
+	result := []agentLightState{}
+	for _, l := range opts.Lights {
+		result = append(result, agentLightState{
+			Power:       powerStateString(l.On),
+			Brightness:  l.Brightness,
+			Temperature: l.Temperature,
+			Kelvin:      miredsToKelvin(l.Temperature),
+		})
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// metricNamespace prefixes the name of every exported metric.
+const metricNamespace = "keylight"
+
+// metricLabel is a single label of a sample.
+type metricLabel struct {
+	Name  string
+	Value string
+}
+
+// metricSample is a single value of a metric.
+type metricSample struct {
+	Labels []metricLabel
+	Value  float64
+}
+
+// metricFamily is a gauge and all of its samples.
+type metricFamily struct {
+	Name    string
+	Help    string
+	Samples []metricSample
+}
+
+// metricSet collects the samples of a scrape, keyed by metric name.
+type metricSet struct {
+	families map[string]*metricFamily
+	order    []string
+}
+
+// Describe registers a gauge so that its help text is written even if there
+// are no samples.
+func (s *metricSet) Describe(name, help string) {
+	if s.families == nil {
+		s.families = make(map[string]*metricFamily)
+	}
+	name = metricNamespace + "_" + name
+	if _, ok := s.families[name]; ok {
+		return
+	}
+	s.families[name] = &metricFamily{Name: name, Help: help}
+	s.order = append(s.order, name)
+}
+
+// Add records a sample for a gauge that was registered with Describe.
+func (s *metricSet) Add(name string, value float64, labels ...metricLabel) {
+	family := s.families[metricNamespace+"_"+name]
+	family.Samples = append(family.Samples, metricSample{Labels: labels, Value: value})
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (s *metricSet) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, name := range s.order {
+		family := s.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", family.Name, family.Help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", family.Name)
+		for _, sample := range family.Samples {
+			b.WriteString(family.Name)
+			if len(sample.Labels) > 0 {
+				var labels []string
+				for _, l := range sample.Labels {
+					labels = append(labels, fmt.Sprintf("%s=\"%s\"", l.Name, escapeLabelValue(l.Value)))
+				}
+				b.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(sample.Value, 'g', -1, 64) + "\n")
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// escapeLabelValue escapes a label value as required by the text exposition
+// format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// boolMetric converts a bool into a gauge value.
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// lightScrape is the result of scraping a single light. Err is the error
+// fetching the state of the light, which decides whether the light is up,
+// while a failure to fetch the accessory info is only recorded in InfoErr.
+type lightScrape struct {
+	Key      string
+	Options  *keylight.KeyLightOptions
+	Info     *keylight.AccessoryInfo
+	Err      error
+	InfoErr  error
+	Duration time.Duration
+}
+
+// lightExporter serves metrics for the lights tracked by an agent. Every
+// scrape queries each light concurrently, with ScrapeTimeout bounding the time
+// spent on each light so that unreachable lights do not stall the scrape.
+type lightExporter struct {
+	Agent         *agent
+	ScrapeTimeout time.Duration
+}
+
+func (e *lightExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	set := e.Collect(r.Context())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	set.WriteTo(w)
+}
+
+// Collect scrapes every known light and returns the resulting metrics.
+func (e *lightExporter) Collect(ctx context.Context) *metricSet {
+	keys := e.Agent.Keys()
+	scrapes := make([]*lightScrape, len(keys))
+
+	var wg sync.WaitGroup
+	for idx, key := range keys {
+		wg.Add(1)
+		go func(idx int, key string) {
+			defer wg.Done()
+			scrapes[idx] = e.scrape(ctx, key)
+		}(idx, key)
+	}
+	wg.Wait()
+
+	set := &metricSet{}
+	set.Describe("up", "Whether the state of the light could be read during the scrape.")
+	set.Describe("scrape_duration_seconds", "The time taken to scrape the light.")
+	set.Describe("info", "Information about the light, from its accessory info. Omitted if the accessory info could not be read.")
+	set.Describe("on", "Whether the light is switched on.")
+	set.Describe("brightness_percent", "The brightness of the light, in percent.")
+	set.Describe("temperature_mireds", "The color temperature of the light, in mireds.")
+	set.Describe("temperature_kelvin", "The color temperature of the light, in Kelvin.")
+
+	for _, s := range scrapes {
+		light := metricLabel{Name: "light", Value: s.Key}
+		set.Add("up", boolMetric(s.Err == nil), light)
+		set.Add("scrape_duration_seconds", s.Duration.Seconds(), light)
+
+		if s.Info != nil {
+			set.Add("info", 1, light,
+				metricLabel{Name: "display_name", Value: s.Info.DisplayName},
+				metricLabel{Name: "product_name", Value: s.Info.ProductName},
+				metricLabel{Name: "serial_number", Value: s.Info.SerialNumber},
+				metricLabel{Name: "firmware_version", Value: s.Info.FirmwareVersion},
+				metricLabel{Name: "firmware_build_number", Value: strconv.Itoa(s.Info.FirmwareBuildNumber)},
+				metricLabel{Name: "hardware_board_type", Value: strconv.Itoa(s.Info.HardwareBoardType)},
+			)
+		}
+
+		if s.Options == nil {
+			continue
+		}
+		for idx, l := range s.Options.Lights {
+			index := metricLabel{Name: "light_index", Value: strconv.Itoa(idx)}
+			set.Add("on", boolMetric(l.On == 1), light, index)
+			set.Add("brightness_percent", float64(l.Brightness), light, index)
+			set.Add("temperature_mireds", float64(l.Temperature), light, index)
+			set.Add("temperature_kelvin", float64(miredsToKelvin(l.Temperature)), light, index)
+		}
+	}
+
+	return set
+}
+
+// scrape concurrently fetches the state and accessory info of a single light.
+func (e *lightExporter) scrape(ctx context.Context, key string) *lightScrape {
+	light, _ := e.Agent.client(key)
+	result := &lightScrape{Key: key}
+
+	ctx, cancelFn := context.WithTimeout(ctx, e.ScrapeTimeout)
+	defer cancelFn()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		opts, err := light.FetchLightOptions(ctx)
+		if err != nil {
+			result.Err = err
+			return
+		}
+		result.Options = opts
+	}()
+	go func() {
+		defer wg.Done()
+		info, err := light.FetchAccessoryInfo(ctx)
+		if err != nil {
+			result.InfoErr = err
+			return
+		}
+		result.Info = info
+	}()
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	return result
+}
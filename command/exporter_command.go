@@ -0,0 +1,212 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// defaultExporterListenAddr is the address the exporter listens on by
+// default. Unlike the agent, the exporter is read-only and is expected to be
+// scraped from other hosts.
+const defaultExporterListenAddr = ":9781"
+
+type ExporterCommand struct {
+	Meta
+}
+
+func (c *ExporterCommand) Help() string {
+	helpText := `
+Usage: keylightctl exporter [options]
+
+ Serve Prometheus metrics for keylights at /metrics. Every scrape queries the
+ lights concurrently, and each light is given at most -scrape-timeout to
+ respond so that an unreachable light does not stall the scrape.
+
+ The following gauges are exported, labelled by light (and light_index for
+ accessories with several lights):
+
+   keylight_up                        Whether the state of the light could be read
+   keylight_scrape_duration_seconds   The time taken to scrape the light
+   keylight_info                      Accessory info, as labels, omitted if it
+                                      could not be read
+   keylight_on                        Whether the light is switched on
+   keylight_brightness_percent        The brightness of the light
+   keylight_temperature_mireds        The temperature of the light in mireds
+   keylight_temperature_kelvin        The temperature of the light in Kelvin
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Exporter Specific Options:
+
+  -listen <addr>
+    The address to serve metrics on (default: :9781)
+
+  -scrape-timeout <duration>
+    Sets the maximum time to wait for each light during a scrape
+    (default: 2s)
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories before serving starts
+    (default: 5s)
+
+  -all
+    Export all keylights. Lights that are discovered while serving are
+    added as they appear. This is the default if no lights or groups are
+    provided.
+
+  ` + lightOptionUsage("Export") + `
+
+  -group <group>
+    Export all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs while exporting all lights
+    (default: 1m)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ExporterCommand) Synopsis() string {
+	return "Serve Prometheus metrics for keylights"
+}
+
+func (c *ExporterCommand) Name() string { return "exporter" }
+
+func (c *ExporterCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var listenAddr string
+	var timeout, scrapeTimeout, discoveryInterval time.Duration
+	var selection lightSelection
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&listenAddr, "listen", defaultExporterListenAddr, "")
+	flags.DurationVar(&scrapeTimeout, "scrape-timeout", 2*time.Second, "")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.DurationVar(&discoveryInterval, "discovery-interval", time.Minute, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if len(selection.Lights) == 0 && len(selection.Groups) == 0 {
+		selection.AllLights = true
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if scrapeTimeout <= 0 || discoveryInterval <= 0 {
+		c.UI.Error("--scrape-timeout and --discovery-interval must be positive")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to listen on %s, err: %v", listenAddr, err))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		listener.Close()
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 && !selection.AllLights {
+		listener.Close()
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	// The exporter scrapes lights on demand, so the agent is only used to
+	// keep track of which lights exist.
+	a := &agent{
+		UI:               c.UI,
+		DiscoveryTimeout: timeout,
+	}
+	if selection.AllLights {
+		a.DiscoveryInterval = discoveryInterval
+	}
+
+	var wg sync.WaitGroup
+	for _, light := range found {
+		wg.Add(1)
+		go func(light *keylight.KeyLight) {
+			defer wg.Done()
+			a.observe(ctx, light)
+		}(light)
+	}
+	wg.Wait()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &lightExporter{Agent: a, ScrapeTimeout: scrapeTimeout})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><body><h1>keylightctl exporter</h1><a href="/metrics">Metrics</a></body></html>`)
+	})
+
+	server := &http.Server{Handler: mux}
+	serveErrCh := make(chan error, 1)
+	go func() {
+		serveErrCh <- server.Serve(listener)
+	}()
+
+	go a.Run(ctx)
+
+	c.UI.Output(fmt.Sprintf("Serving metrics on %s/metrics", listener.Addr()))
+
+	code := 0
+	select {
+	case <-ctx.Done():
+	case err := <-serveErrCh:
+		c.UI.Error(fmt.Sprintf("Failed to serve metrics, err: %v", err))
+		code = 1
+	}
+
+	shutdownCtx, shutdownCancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancelFn()
+	server.Shutdown(shutdownCtx)
+
+	return code
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// newTestExporter returns an exporter for a single simulated light, with
+// requests for the given paths failing.
+func newTestExporter(t *testing.T, failing ...string) *lightExporter {
+	t.Helper()
+	setupTestConfig(t)
+
+	sim := simulator.New(simulator.Config{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range failing {
+			if r.URL.Path == path {
+				http.Error(w, "failed", http.StatusInternalServerError)
+				return
+			}
+		}
+		sim.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", srv.URL, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	a := &agent{UI: cli.NewMockUi()}
+	a.observe(context.Background(), &keylight.KeyLight{
+		Name:    `Elgato\ Key\ Light\ 111A`,
+		DNSAddr: host,
+		Port:    portNum,
+	})
+	return &lightExporter{Agent: a, ScrapeTimeout: time.Second}
+}
+
+// collectMetrics returns the exposition text of a single scrape.
+func collectMetrics(t *testing.T, e *lightExporter) string {
+	t.Helper()
+
+	var b strings.Builder
+	if _, err := e.Collect(context.Background()).WriteTo(&b); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	return b.String()
+}
+
+func TestLightExporter_Collect(t *testing.T) {
+	metrics := collectMetrics(t, newTestExporter(t))
+
+	for _, expected := range []string{
+		`keylight_up{light="Elgato\\ Key\\ Light\\ 111A"} 1`,
+		`keylight_info{light="Elgato\\ Key\\ Light\\ 111A",display_name=""`,
+		`keylight_on{light="Elgato\\ Key\\ Light\\ 111A",light_index="0"} 0`,
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expected metrics to contain %s, got:\n%s", expected, metrics)
+		}
+	}
+}
+
+func TestLightExporter_InfoFailure(t *testing.T) {
+	metrics := collectMetrics(t, newTestExporter(t, "/elgato/accessory-info"))
+
+	if !strings.Contains(metrics, `keylight_up{light="Elgato\\ Key\\ Light\\ 111A"} 1`) {
+		t.Errorf("expected the light to be up, got:\n%s", metrics)
+	}
+	if !strings.Contains(metrics, `keylight_on{light="Elgato\\ Key\\ Light\\ 111A",light_index="0"}`) {
+		t.Errorf("expected the state of the light to be exported, got:\n%s", metrics)
+	}
+	if strings.Contains(metrics, "keylight_info{") {
+		t.Errorf("expected the accessory info to be omitted, got:\n%s", metrics)
+	}
+}
+
+func TestLightExporter_StateFailure(t *testing.T) {
+	metrics := collectMetrics(t, newTestExporter(t, "/elgato/lights"))
+
+	if !strings.Contains(metrics, `keylight_up{light="Elgato\\ Key\\ Light\\ 111A"} 0`) {
+		t.Errorf("expected the light to be down, got:\n%s", metrics)
+	}
+	if strings.Contains(metrics, "keylight_on{") {
+		t.Errorf("expected no state to be exported, got:\n%s", metrics)
+	}
+}
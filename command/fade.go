@@ -0,0 +1,145 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// fadeStepTimeout is the maximum time a single step of a fade may take. Steps
+// are not tied to the context of the fade so that an interrupted fade always
+// leaves the lights at a step that was fully applied.
+const fadeStepTimeout = 5 * time.Second
+
+// easingFunc maps the linear progress of a fade in [0, 1] to the eased
+// progress in [0, 1].
+type easingFunc func(t float64) float64
+
+// easingFuncs are the easing curves that can be selected with -easing.
+var easingFuncs = map[string]easingFunc{
+	"linear": func(t float64) float64 {
+		return t
+	},
+	"ease-in": func(t float64) float64 {
+		return t * t
+	},
+	"ease-out": func(t float64) float64 {
+		return t * (2 - t)
+	},
+	"ease-in-out": func(t float64) float64 {
+		return (1 - math.Cos(math.Pi*t)) / 2
+	},
+}
+
+// easingNames returns the names of the supported easing curves.
+func easingNames() []string {
+	var names []string
+	for name := range easingFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lightFade gradually moves the brightness and temperature of a light from
+// its current state to a target state.
+type lightFade struct {
+	Duration time.Duration
+	Interval time.Duration
+	Easing   easingFunc
+
+	Brightness  lightAdjustment
+	Temperature lightAdjustment
+	Kelvin      kelvinAdjustment
+}
+
+// Steps returns the number of updates that are sent to each light.
+func (f *lightFade) Steps() int {
+	steps := int(f.Duration / f.Interval)
+	if steps < 1 {
+		return 1
+	}
+	return steps
+}
+
+// target returns the final state of a light that starts at start.
+func (f *lightFade) target(start *keylight.KeyLightLight) *keylight.KeyLightLight {
+	end := start.Copy()
+	end.Brightness = f.Brightness.Apply(start.Brightness, minBrightness, maxBrightness)
+	end.Temperature = f.Temperature.Apply(start.Temperature, minTemperature, maxTemperature)
+	end.Temperature = f.Kelvin.Apply(end.Temperature)
+	return end
+}
+
+// Run fades the lights of the accessory that are selected by channels, or all
+// of them if channels is nil, until the target is reached or ctx is cancelled,
+// in which case the lights are left at the last step that was applied.
+func (f *lightFade) Run(ctx context.Context, light *keylight.KeyLight, channels []int) error {
+	fetchCtx, cancelFn := context.WithTimeout(ctx, fadeStepTimeout)
+	start, err := light.FetchLightOptions(fetchCtx)
+	cancelFn()
+	if err != nil {
+		return fmt.Errorf("failed to fetch light options, err: %w", err)
+	}
+
+	if _, err := selectChannels(start, channels); err != nil {
+		return err
+	}
+
+	ends := make([]*keylight.KeyLightLight, len(start.Lights))
+	for idx, l := range start.Lights {
+		ends[idx] = l
+		if channelSelected(channels, idx) {
+			ends[idx] = f.target(l)
+		}
+	}
+
+	steps := f.Steps()
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for step := 1; step <= steps; step++ {
+		progress := f.Easing(float64(step) / float64(steps))
+
+		opts := start.Copy()
+		for idx, l := range opts.Lights {
+			l.Brightness = interpolate(start.Lights[idx].Brightness, ends[idx].Brightness, progress)
+			l.Temperature = interpolate(start.Lights[idx].Temperature, ends[idx].Temperature, progress)
+		}
+
+		stepCtx, cancelFn := context.WithTimeout(context.Background(), fadeStepTimeout)
+		_, err := light.UpdateLightOptions(stepCtx, opts)
+		cancelFn()
+		if err != nil {
+			return fmt.Errorf("failed to update light at step %d/%d, err: %w", step, steps, err)
+		}
+
+		if step == steps {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted at step %d/%d", step, steps)
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// interpolate returns the value that is progress of the way from start to
+// end.
+func interpolate(start, end int, progress float64) int {
+	return start + int(math.Round(float64(end-start)*progress))
+}
+
+// easingUsage returns the supported easing curves for use in help text.
+func easingUsage() string {
+	return strings.Join(easingNames(), ", ")
+}
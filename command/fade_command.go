@@ -0,0 +1,179 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type FadeCommand struct {
+	Meta
+}
+
+func (c *FadeCommand) Help() string {
+	helpText := `
+Usage: keylightctl fade [options]
+
+ Smoothly transition the brightness and/or temperature of keylights from their
+ current state to a target state. Interrupting the fade (e.g. with Ctrl-C)
+ leaves the lights at the last step that was applied. For accessories that
+ expose several lights, a single light can be faded with
+ -light <light>#<index>, e.g: 111A#1.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Fade Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Fade all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Fade") + `
+
+  -group <group>
+    Fade all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -to-brightness <brightness>
+    The brightness percentage (3-100) to fade to. Prefix the value with + or -
+    to fade relative to the current brightness, e.g: +20.
+
+  -to-temperature <temperature>
+    The temperature (143-344) to fade to. Prefix the value with + or - to fade
+    relative to the current temperature.
+
+  -to-kelvin <kelvin>
+    The temperature in Kelvin to fade to, e.g: 5600K. Cannot be combined with
+    -to-temperature.
+
+  -duration <duration>
+    How long the fade should take (default: 1s)
+
+  -interval <duration>
+    How long to wait between each step of the fade (default: 100ms)
+
+  -easing <curve>
+    The easing curve of the fade, one of: ` + easingUsage() + `
+    (default: linear)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *FadeCommand) Synopsis() string {
+	return "Smoothly fade keylight brightness and temperature"
+}
+
+func (f *FadeCommand) Name() string { return "fade" }
+
+func (c *FadeCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var selection lightSelection
+	var fade lightFade
+	var easing string
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	selection.AllowChannels = true
+	flags.Var(&fade.Brightness, "to-brightness", "")
+	flags.Var(&fade.Temperature, "to-temperature", "")
+	flags.Var(&fade.Kelvin, "to-kelvin", "")
+	flags.DurationVar(&fade.Duration, "duration", time.Second, "")
+	flags.DurationVar(&fade.Interval, "interval", 100*time.Millisecond, "")
+	flags.StringVar(&easing, "easing", "linear", "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if !fade.Brightness.IsSet && !fade.Temperature.IsSet && !fade.Kelvin.IsSet {
+		c.UI.Error("At least one of --to-brightness, --to-temperature and --to-kelvin must be provided")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if fade.Temperature.IsSet && fade.Kelvin.IsSet {
+		c.UI.Error("Cannot specify --to-temperature and --to-kelvin together")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fade.Brightness.Validate("Brightness", minBrightness, maxBrightness); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := fade.Temperature.Validate("Temperature", minTemperature, maxTemperature); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if fade.Duration < 0 || fade.Interval <= 0 {
+		c.UI.Error("--duration must not be negative and --interval must be positive")
+		return 1
+	}
+
+	easingFn, ok := easingFuncs[easing]
+	if !ok {
+		c.UI.Error(fmt.Sprintf("Unknown easing curve '%s', must be one of: %s", easing, easingUsage()))
+		return 1
+	}
+	fade.Easing = easingFn
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	fadeCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Every light fades at the same time, so there is no bound on the
+	// concurrency.
+	fanOut := lightFanOut{Concurrency: len(found)}
+	results := fanOut.Run(fadeCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		return fade.Run(ctx, light, selection.Channels().Get(light))
+	})
+
+	return reportResults(c.UI, results)
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestFadeCommand(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	cmd := &FadeCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "-light", addr+"#0", "-to-brightness", "80", "-to-temperature", "300", "-duration", "50ms", "-interval", "10ms", "-easing", "ease-out")
+
+	opts := sim.Options()
+	if l := opts.Lights[0]; l.Brightness != 80 || l.Temperature != 300 {
+		t.Errorf("expected the first light to reach the target, got %+v", l)
+	}
+	if l := opts.Lights[1]; l.Brightness != 20 || l.Temperature != 213 {
+		t.Errorf("expected the second light to be left alone, got %+v", l)
+	}
+}
+
+func TestFadeCommand_Invalid(t *testing.T) {
+	cases := map[string][]string{
+		"no target":              {},
+		"temperature and kelvin": {"-to-temperature", "300", "-to-kelvin", "3000K"},
+		"unknown easing":         {"-to-brightness", "80", "-easing", "bounce"},
+		"zero interval":          {"-to-brightness", "80", "-interval", "0s"},
+		"invalid brightness":     {"-to-brightness", "120"},
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestConfig(t)
+			sim, addr := newTestLight(t, simulator.Config{})
+
+			ui := cli.NewMockUi()
+			cmd := &FadeCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 1, append([]string{"-light", addr}, args...)...)
+
+			if l := sim.Options().Lights[0]; l.Brightness != 20 || l.Temperature != 213 {
+				t.Errorf("expected the light to be left alone, got %+v", l)
+			}
+		})
+	}
+}
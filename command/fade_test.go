@@ -0,0 +1,162 @@
+package command
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylightctl/simulator"
+)
+
+func TestEasingFuncs(t *testing.T) {
+	midpoints := map[string]float64{
+		"linear":      0.5,
+		"ease-in":     0.25,
+		"ease-out":    0.75,
+		"ease-in-out": 0.5,
+	}
+
+	for _, name := range easingNames() {
+		t.Run(name, func(t *testing.T) {
+			fn := easingFuncs[name]
+			if got := fn(0); got != 0 {
+				t.Errorf("expected the fade to start at 0, got %f", got)
+			}
+			if got := fn(1); math.Abs(got-1) > 1e-9 {
+				t.Errorf("expected the fade to end at 1, got %f", got)
+			}
+
+			want, ok := midpoints[name]
+			if !ok {
+				t.Fatalf("no expected midpoint for %s", name)
+			}
+			if got := fn(0.5); math.Abs(got-want) > 1e-9 {
+				t.Errorf("expected %f half way through, got %f", want, got)
+			}
+
+			previous := 0.0
+			for step := 1; step <= 100; step++ {
+				got := fn(float64(step) / 100)
+				if got < previous {
+					t.Fatalf("expected the curve to never go backwards, got %f after %f", got, previous)
+				}
+				previous = got
+			}
+		})
+	}
+}
+
+func TestLightFade_Steps(t *testing.T) {
+	cases := []struct {
+		duration time.Duration
+		interval time.Duration
+		expected int
+	}{
+		{duration: time.Second, interval: 100 * time.Millisecond, expected: 10},
+		{duration: time.Second, interval: 300 * time.Millisecond, expected: 3},
+		{duration: 0, interval: 100 * time.Millisecond, expected: 1},
+		{duration: 50 * time.Millisecond, interval: 100 * time.Millisecond, expected: 1},
+	}
+
+	for _, tc := range cases {
+		fade := lightFade{Duration: tc.duration, Interval: tc.interval}
+		if steps := fade.Steps(); steps != tc.expected {
+			t.Errorf("%s every %s: expected %d steps, got %d", tc.duration, tc.interval, tc.expected, steps)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	cases := []struct {
+		start, end int
+		progress   float64
+		expected   int
+	}{
+		{start: 20, end: 80, progress: 0, expected: 20},
+		{start: 20, end: 80, progress: 0.5, expected: 50},
+		{start: 20, end: 80, progress: 1, expected: 80},
+		{start: 80, end: 20, progress: 0.25, expected: 65},
+		{start: 3, end: 4, progress: 0.5, expected: 4},
+	}
+
+	for _, tc := range cases {
+		if got := interpolate(tc.start, tc.end, tc.progress); got != tc.expected {
+			t.Errorf("%d to %d at %.2f: expected %d, got %d", tc.start, tc.end, tc.progress, tc.expected, got)
+		}
+	}
+}
+
+func TestLightFade_Run(t *testing.T) {
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+	light := lightFromAddress(t, addr)
+
+	fade := lightFade{
+		Duration: 30 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+		Easing:   easingFuncs["ease-in-out"],
+	}
+	if err := fade.Brightness.Set("+40"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fade.Kelvin.Set("2900K"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fade.Run(context.Background(), light, []int{1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	opts := sim.Options()
+	if l := opts.Lights[0]; l.Brightness != 20 || l.Temperature != 213 {
+		t.Errorf("expected the unselected light to be left alone, got %+v", l)
+	}
+	if l := opts.Lights[1]; l.Brightness != 60 || l.Temperature != kelvinToMireds(2900) {
+		t.Errorf("expected the selected light to reach the target, got %+v", l)
+	}
+}
+
+func TestLightFade_Interrupted(t *testing.T) {
+	sim, addr := newTestLight(t, simulator.Config{})
+	light := lightFromAddress(t, addr)
+
+	fade := lightFade{
+		Duration: 10 * time.Second,
+		Interval: time.Second,
+		Easing:   easingFuncs["linear"],
+	}
+	if err := fade.Brightness.Set("70"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first step is applied immediately, and the fade is interrupted
+	// while it waits for the second.
+	ctx, cancelFn := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelFn()
+
+	err := fade.Run(ctx, light, nil)
+	if err == nil || !strings.Contains(err.Error(), "interrupted at step 1/10") {
+		t.Fatalf("expected the fade to be interrupted after the first step, got %v", err)
+	}
+	if brightness := sim.Options().Lights[0].Brightness; brightness != 25 {
+		t.Errorf("expected the light to be left at the first step, got %d%%", brightness)
+	}
+}
+
+func TestLightFade_UnknownChannel(t *testing.T) {
+	sim, addr := newTestLight(t, simulator.Config{})
+	light := lightFromAddress(t, addr)
+
+	fade := lightFade{Duration: time.Second, Interval: 100 * time.Millisecond, Easing: easingFuncs["linear"]}
+	if err := fade.Brightness.Set("70"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fade.Run(context.Background(), light, []int{1}); err == nil {
+		t.Errorf("expected an error for a channel the accessory does not have")
+	}
+	if brightness := sim.Options().Lights[0].Brightness; brightness != 20 {
+		t.Errorf("expected the light to be left alone, got %d%%", brightness)
+	}
+}
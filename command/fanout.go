@@ -0,0 +1,219 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+// defaultConcurrency is the default number of lights that are contacted at
+// the same time by commands that operate on several lights.
+const defaultConcurrency = 8
+
+// errSkipped is the result of lights that were not attempted because an
+// earlier light failed while running with -fail-fast.
+var errSkipped = errors.New("skipped after an earlier failure")
+
+// fanOutOptionsUsage returns the help string for the -concurrency and
+// -fail-fast options.
+func fanOutOptionsUsage() string {
+	helpText := `
+  -concurrency <count>
+    Sets the maximum number of lights that are contacted at the same time
+    (default: 8)
+
+  -fail-fast
+    Stop after the first light fails rather than attempting every light.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// lightOperation is run against every light by lightFanOut. idx is the index
+// of the light in the slice that was passed to Run.
+type lightOperation func(ctx context.Context, idx int, light *keylight.KeyLight) error
+
+// lightResult is the outcome of running a lightOperation against a light.
+type lightResult struct {
+	Light *keylight.KeyLight
+	Err   error
+}
+
+// lightFanOut runs an operation against many lights in parallel with bounded
+// concurrency, collecting the result for each light.
+type lightFanOut struct {
+	Concurrency int
+	FailFast    bool
+}
+
+// AddFlags registers the -concurrency and -fail-fast flags on the given
+// FlagSet.
+func (f *lightFanOut) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&f.Concurrency, "concurrency", defaultConcurrency, "")
+	fs.BoolVar(&f.FailFast, "fail-fast", false, "")
+}
+
+func (f *lightFanOut) Validate() error {
+	if f.Concurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+	return nil
+}
+
+// Run runs op against every light and returns a result per light, in the same
+// order as lights. If FailFast is set, the first failure cancels operations
+// that are in flight and skips those that have not yet started.
+func (f *lightFanOut) Run(ctx context.Context, lights []*keylight.KeyLight, op lightOperation) []*lightResult {
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+
+	concurrency := f.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+
+	results := make([]*lightResult, len(lights))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+
+	for idx, light := range lights {
+		results[idx] = &lightResult{Light: light}
+
+		sem <- struct{}{}
+
+		mu.Lock()
+		skip := failed
+		mu.Unlock()
+		if skip {
+			<-sem
+			results[idx].Err = errSkipped
+			continue
+		}
+
+		wg.Add(1)
+		go func(idx int, light *keylight.KeyLight) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := op(ctx, idx, light)
+			results[idx].Err = err
+
+			if err != nil && f.FailFast {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+				cancelFn()
+			}
+		}(idx, light)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// reportResults renders a summary of the results and returns the exit code of
+// the command. A single successful light is not reported to keep the output of
+// the common case quiet.
+func reportResults(ui cli.Ui, results []*lightResult) int {
+	failed, skipped := 0, 0
+	for _, r := range results {
+		if r.Err == errSkipped {
+			skipped++
+		} else if r.Err != nil {
+			failed++
+		}
+	}
+
+	if len(results) > 1 || failed > 0 || skipped > 0 {
+		t := table.NewWriter()
+		t.AppendHeader(table.Row{"Name", "Result", "Error"})
+
+		for _, r := range results {
+			status, errText := "ok", ""
+			if r.Err == errSkipped {
+				status = "skipped"
+			} else if r.Err != nil {
+				status, errText = "failed", r.Err.Error()
+			}
+			t.AppendRow(table.Row{lightLabel(r.Light), status, errText})
+		}
+
+		// Lines are written one at a time so that a prefixed UI indents
+		// every row of the table.
+		for _, line := range strings.Split(t.Render(), "\n") {
+			ui.Output(line)
+		}
+	}
+
+	if skipped > 0 {
+		ui.Error(fmt.Sprintf("Failed to update %d of %d light(s), skipped %d", failed, len(results), skipped))
+		return 1
+	}
+
+	if failed > 0 {
+		ui.Error(fmt.Sprintf("Failed to update %d of %d light(s)", failed, len(results)))
+		return 1
+	}
+
+	return 0
+}
+
+// modifyLightOptions fetches the current light options, applies mutate to
+// every light in the accessory, and writes the result back.
+func modifyLightOptions(ctx context.Context, light *keylight.KeyLight, mutate func(l *keylight.KeyLightLight)) error {
+	return modifyLightChannels(ctx, light, nil, func(lights []*keylight.KeyLightLight) error {
+		for _, l := range lights {
+			mutate(l)
+		}
+		return nil
+	})
+}
+
+// modifyLightChannels fetches the current light options, applies mutate to the
+// lights of the accessory that are selected by channels, or to every light if
+// channels is nil, and writes the result back.
+func modifyLightChannels(ctx context.Context, light *keylight.KeyLight, channels []int, mutate func(lights []*keylight.KeyLightLight) error) error {
+	opts, err := light.FetchLightOptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch light options, err: %w", err)
+	}
+
+	newOpts := opts.Copy()
+	selected, err := selectChannels(newOpts, channels)
+	if err != nil {
+		return err
+	}
+	if err := mutate(selected); err != nil {
+		return err
+	}
+
+	_, err = light.UpdateLightOptions(ctx, newOpts)
+	if err != nil {
+		return fmt.Errorf("failed to update light, err: %w", err)
+	}
+
+	return nil
+}
+
+// reportFetchErrors reports the lights that could not be read by commands that
+// render the successful results themselves, and returns the exit code of the
+// command.
+func reportFetchErrors(ui cli.Ui, results []*lightResult) int {
+	code := 0
+	for _, r := range results {
+		if r.Err != nil {
+			ui.Error(fmt.Sprintf("Failed to read light (%s), err: %v", lightLabel(r.Light), r.Err))
+			code = 1
+		}
+	}
+	return code
+}
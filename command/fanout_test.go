@@ -0,0 +1,149 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// testLights returns n lights with distinct names.
+func testLights(n int) []*keylight.KeyLight {
+	var lights []*keylight.KeyLight
+	for i := 0; i < n; i++ {
+		lights = append(lights, &keylight.KeyLight{Name: fmt.Sprintf(`Elgato\ Key\ Light\ %d`, i)})
+	}
+	return lights
+}
+
+func TestLightFanOut_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	fanOut := lightFanOut{Concurrency: 2}
+	results := fanOut.Run(context.Background(), testLights(6), func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if idx == 3 {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+
+	if peak != 2 {
+		t.Errorf("expected at most 2 lights to be contacted at the same time, got %d", peak)
+	}
+	for idx, r := range results {
+		if r.Light.Name != fmt.Sprintf(`Elgato\ Key\ Light\ %d`, idx) {
+			t.Errorf("expected results in the order of the lights, got %s at %d", r.Light.Name, idx)
+		}
+		if (r.Err != nil) != (idx == 3) {
+			t.Errorf("light %d: unexpected error %v", idx, r.Err)
+		}
+	}
+}
+
+func TestLightFanOut_FailFast(t *testing.T) {
+	fanOut := lightFanOut{Concurrency: 1, FailFast: true}
+	results := fanOut.Run(context.Background(), testLights(3), func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		if idx == 0 {
+			return errors.New("unreachable")
+		}
+		return nil
+	})
+
+	if results[0].Err == nil || results[0].Err == errSkipped {
+		t.Errorf("expected the first light to fail, got %v", results[0].Err)
+	}
+	for _, r := range results[1:] {
+		if r.Err != errSkipped {
+			t.Errorf("expected the remaining lights to be skipped, got %v", r.Err)
+		}
+	}
+}
+
+func TestReportResults(t *testing.T) {
+	lights := testLights(3)
+
+	cases := []struct {
+		name    string
+		results []*lightResult
+		code    int
+		table   bool
+		errText string
+	}{
+		{
+			name:    "single success",
+			results: []*lightResult{{Light: lights[0]}},
+		},
+		{
+			name:    "several successes",
+			results: []*lightResult{{Light: lights[0]}, {Light: lights[1]}},
+			table:   true,
+		},
+		{
+			name:    "failure",
+			results: []*lightResult{{Light: lights[0]}, {Light: lights[1], Err: errors.New("unreachable")}},
+			code:    1,
+			table:   true,
+			errText: "Failed to update 1 of 2 light(s)",
+		},
+		{
+			name:    "skipped",
+			results: []*lightResult{{Light: lights[0], Err: errors.New("unreachable")}, {Light: lights[1], Err: errSkipped}, {Light: lights[2], Err: errSkipped}},
+			code:    1,
+			table:   true,
+			errText: "Failed to update 1 of 3 light(s), skipped 2",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := cli.NewMockUi()
+			ui := &cli.PrefixedUi{OutputPrefix: "  ", ErrorPrefix: "==> ", Ui: mock}
+
+			if code := reportResults(ui, tc.results); code != tc.code {
+				t.Errorf("expected exit code %d, got %d", tc.code, code)
+			}
+
+			out := mock.OutputWriter.String()
+			if !tc.table {
+				if out != "" {
+					t.Errorf("expected no output, got %s", out)
+				}
+			} else {
+				for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+					if !strings.HasPrefix(line, "  ") {
+						t.Errorf("expected every line of the table to be prefixed, got %q", line)
+					}
+				}
+				for _, r := range tc.results {
+					if !strings.Contains(out, lightLabel(r.Light)) {
+						t.Errorf("expected the table to include %s, got:\n%s", lightLabel(r.Light), out)
+					}
+				}
+			}
+
+			if errOut := mock.ErrorWriter.String(); !strings.Contains(errOut, tc.errText) {
+				t.Errorf("expected errors to contain %q, got %q", tc.errText, errOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// The output formats that are supported by commands which accept -format.
+const (
+	formatTable    = "table"
+	formatJSON     = "json"
+	formatYAML     = "yaml"
+	formatCSV      = "csv"
+	formatTemplate = "template"
+)
+
+// formatOptionsUsage returns the help string for the -format and -template
+// options.
+func formatOptionsUsage() string {
+	helpText := `
+  -format <format>
+    Sets the output format, one of: table, json, yaml, csv or template.
+    Field names are stable and safe to use from scripts.
+
+  -template <template>
+    The Go text/template to render for each light when using -format template,
+    e.g: '{{ .Name }} {{ .Brightness }}'.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// recordWriter renders a slice of records in the format selected with -format.
+// Records are flat structs whose json tags define the stable field names used
+// by every format.
+type recordWriter struct {
+	Format   string
+	Template string
+
+	tmpl *template.Template
+}
+
+// AddFlags registers the -format and -template flags on the given FlagSet.
+func (r *recordWriter) AddFlags(f *flag.FlagSet) {
+	f.StringVar(&r.Format, "format", formatTable, "")
+	f.StringVar(&r.Template, "template", "", "")
+}
+
+// Validate ensures that the selected format is supported and that a template
+// was provided and can be parsed if required.
+func (r *recordWriter) Validate() error {
+	switch r.Format {
+	case formatTable, formatJSON, formatYAML, formatCSV:
+		if r.Template != "" {
+			return fmt.Errorf("-template can only be used with -format %s", formatTemplate)
+		}
+		return nil
+	case formatTemplate:
+		if r.Template == "" {
+			return fmt.Errorf("-template must be provided with -format %s", formatTemplate)
+		}
+
+		tmpl, err := template.New("output").Parse(r.Template)
+		if err != nil {
+			return fmt.Errorf("failed to parse template, err: %w", err)
+		}
+		r.tmpl = tmpl
+		return nil
+	default:
+		return fmt.Errorf("unsupported format '%s'", r.Format)
+	}
+}
+
+// IsTable returns whether the command should render its human readable table.
+func (r *recordWriter) IsTable() bool {
+	return r.Format == formatTable
+}
+
+// Write renders records, which must be a slice of structs, to w.
+func (r *recordWriter) Write(w io.Writer, records interface{}) error {
+	switch r.Format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case formatYAML:
+		data, err := marshalYAML(records)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case formatCSV:
+		return writeCSVRecords(w, records)
+	case formatTemplate:
+		v := reflect.ValueOf(records)
+		for i := 0; i < v.Len(); i++ {
+			if err := r.tmpl.Execute(w, v.Index(i).Interface()); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("format '%s' cannot be written as records", r.Format)
+	}
+}
+
+// writeCSVRecords writes a header row using the json field names of the
+// record type, followed by one row per record.
+func writeCSVRecords(w io.Writer, records interface{}) error {
+	v := reflect.ValueOf(records)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(recordFieldNames(v.Type().Elem())); err != nil {
+		return err
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := cw.Write(recordFieldValues(v.Index(i))); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// recordFieldNames returns the field names of a record type, flattening any
+// embedded records.
+func recordFieldNames(t reflect.Type) []string {
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			result = append(result, recordFieldNames(field.Type)...)
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		result = append(result, name)
+	}
+	return result
+}
+
+// recordFieldValues returns the values of a record in the same order as
+// recordFieldNames.
+func recordFieldValues(v reflect.Value) []string {
+	var result []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if v.Type().Field(i).Anonymous {
+			result = append(result, recordFieldValues(field)...)
+			continue
+		}
+
+		if values, ok := field.Interface().([]string); ok {
+			result = append(result, strings.Join(values, ","))
+			continue
+		}
+		result = append(result, fmt.Sprint(field.Interface()))
+	}
+	return result
+}
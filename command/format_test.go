@@ -0,0 +1,147 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testBaseRecord struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+type testRecord struct {
+	testBaseRecord `yaml:",inline"`
+
+	Brightness int      `json:"brightness" yaml:"brightness"`
+	Groups     []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Note       string
+}
+
+var testRecords = []testRecord{
+	{testBaseRecord: testBaseRecord{Name: "111A"}, Brightness: 20, Groups: []string{"desk", "office"}, Note: "a, b"},
+	{testBaseRecord: testBaseRecord{Name: "861A"}, Brightness: 100},
+}
+
+func TestRecordWriter_Validate(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   string
+		template string
+		valid    bool
+	}{
+		{name: "table", format: formatTable, valid: true},
+		{name: "json", format: formatJSON, valid: true},
+		{name: "yaml", format: formatYAML, valid: true},
+		{name: "csv", format: formatCSV, valid: true},
+		{name: "template", format: formatTemplate, template: "{{ .Name }}", valid: true},
+		{name: "template without format", format: formatJSON, template: "{{ .Name }}"},
+		{name: "format without template", format: formatTemplate},
+		{name: "invalid template", format: formatTemplate, template: "{{ .Name "},
+		{name: "unknown format", format: "xml"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := recordWriter{Format: tc.format, Template: tc.template}
+			if err := w.Validate(); (err == nil) != tc.valid {
+				t.Errorf("expected valid: %v, got err: %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestRecordWriter_Write(t *testing.T) {
+	cases := []struct {
+		name     string
+		format   string
+		template string
+		expected string
+	}{
+		{
+			name:   "json",
+			format: formatJSON,
+			expected: `[
+  {
+    "name": "111A",
+    "brightness": 20,
+    "groups": [
+      "desk",
+      "office"
+    ],
+    "Note": "a, b"
+  },
+  {
+    "name": "861A",
+    "brightness": 100,
+    "Note": ""
+  }
+]
+`,
+		},
+		{
+			name:   "yaml",
+			format: formatYAML,
+			expected: `- name: 111A
+  brightness: 20
+  groups:
+    - desk
+    - office
+  note: a, b
+- name: 861A
+  brightness: 100
+  note: ""
+`,
+		},
+		{
+			name:   "csv",
+			format: formatCSV,
+			expected: `name,brightness,groups,Note
+111A,20,"desk,office","a, b"
+861A,100,,
+`,
+		},
+		{
+			name:     "template",
+			format:   formatTemplate,
+			template: "{{ .Name }}={{ .Brightness }}",
+			expected: "111A=20\n861A=100\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := recordWriter{Format: tc.format, Template: tc.template}
+			if err := w.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := w.Write(&buf, testRecords); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out := buf.String(); out != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, out)
+			}
+		})
+	}
+}
+
+func TestRecordWriter_WriteTable(t *testing.T) {
+	w := recordWriter{Format: formatTable}
+	if !w.IsTable() {
+		t.Errorf("expected the table format to be rendered by the command")
+	}
+	if err := w.Write(&bytes.Buffer{}, testRecords); err == nil {
+		t.Errorf("expected an error when writing records as a table")
+	}
+}
+
+func TestRecordWriter_TemplateError(t *testing.T) {
+	w := recordWriter{Format: formatTemplate, Template: "{{ .Missing }}"}
+	if err := w.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Write(&bytes.Buffer{}, testRecords); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
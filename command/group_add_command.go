@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type GroupAddCommand struct {
+	Meta
+}
+
+func (c *GroupAddCommand) Help() string {
+	helpText := `
+Usage: keylightctl group add [options] <group> <light-id>...
+
+ Add lights to a group, creating the group if it does not already exist. Each
+ light can be a full key light name, a short ID, an address, or a reference to
+ another group, e.g: @desk.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *GroupAddCommand) Synopsis() string {
+	return "Add lights to a group"
+}
+
+func (f *GroupAddCommand) Name() string { return "group add" }
+
+func (c *GroupAddCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l < 2 {
+		c.UI.Error("This command requires a group name and at least (1) light")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	name := args[0]
+	if err := validateName(name); err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid group name, err: %v", err))
+		return 1
+	}
+
+	groups, err := loadGroups()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	members := groups.Groups[name]
+MEMBERS:
+	for _, light := range args[1:] {
+		light = strings.TrimSpace(light)
+		for _, existing := range members {
+			if existing == light {
+				continue MEMBERS
+			}
+		}
+		members = append(members, light)
+	}
+	groups.Groups[name] = members
+
+	if _, err := groups.Expand(lightListFlags{groupReferencePrefix + name}); err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid group, err: %v", err))
+		return 1
+	}
+
+	if err := groups.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Info(fmt.Sprintf("Group '%s' now contains: %s", name, strings.Join(members, ", ")))
+
+	return 0
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestGroupAddCommand(t *testing.T) {
+	setupTestConfig(t)
+
+	ui := cli.NewMockUi()
+	cmd := &GroupAddCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "desk", "111A", "861A")
+	runCommand(t, cmd, ui, 0, "desk", "861A", " 9C2B ")
+
+	groups, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+	if members := groups.Groups["desk"]; !reflect.DeepEqual(members, groupMembers{"111A", "861A", "9C2B"}) {
+		t.Errorf("expected the new members to be appended once, got %v", members)
+	}
+}
+
+func TestGroupAddCommand_Invalid(t *testing.T) {
+	cases := map[string][]string{
+		"no lights":     {"desk"},
+		"invalid name":  {"@desk", "111A"},
+		"self":          {"desk", "@desk"},
+		"cycle":         {"office", "@desk"},
+		"unknown group": {"desk", "@nothing"},
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestConfig(t)
+			writeGroups(t, "desk: [111A, \"@office\"]\n")
+
+			ui := cli.NewMockUi()
+			cmd := &GroupAddCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 1, args...)
+
+			groups, err := loadGroups()
+			if err != nil {
+				t.Fatalf("failed to load groups: %v", err)
+			}
+			if len(groups.Groups) != 1 || !reflect.DeepEqual(groups.Groups["desk"], groupMembers{"111A", "@office"}) {
+				t.Errorf("expected the groups to be unchanged, got %v", groups.Groups)
+			}
+		})
+	}
+}
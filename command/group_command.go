@@ -0,0 +1,44 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type GroupCommand struct {
+	Meta
+}
+
+func (c *GroupCommand) Help() string {
+	helpText := `
+Usage: keylightctl group <subcommand> [options] [args]
+
+ This command groups subcommands for managing light groups and aliases.
+
+ Groups are stored in groups.yaml within the keylightctl config dir and can be
+ used anywhere a light is accepted by prefixing the group name with @, or by
+ using the -group flag. A group with a single member acts as an alias.
+
+ Create a group containing two lights:
+
+     $ keylightctl group add desk 111A 861A
+
+ Switch on every light in the group:
+
+     $ keylightctl switch -light @desk on
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *GroupCommand) Synopsis() string {
+	return "Manage light groups and aliases"
+}
+
+func (f *GroupCommand) Name() string { return "group" }
+
+func (c *GroupCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type GroupListCommand struct {
+	Meta
+}
+
+func (c *GroupListCommand) Help() string {
+	helpText := `
+Usage: keylightctl group list [options]
+
+ List the configured light groups and their members.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *GroupListCommand) Synopsis() string {
+	return "List light groups"
+}
+
+func (f *GroupListCommand) Name() string { return "group list" }
+
+func (c *GroupListCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	groups, err := loadGroups()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(groups.Groups) == 0 {
+		c.UI.Error("No groups are configured, try 'keylightctl group add'")
+		return 1
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Group", "Members"})
+
+	for _, name := range groups.Names() {
+		t.AppendRows([]table.Row{
+			{name, strings.Join(groups.Groups[name], ", ")},
+		})
+	}
+	t.Render()
+
+	return 0
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type GroupRemoveCommand struct {
+	Meta
+}
+
+func (c *GroupRemoveCommand) Help() string {
+	helpText := `
+Usage: keylightctl group remove [options] <group> [<light-id>...]
+
+ Remove lights from a group. If no lights are provided then the whole group is
+ deleted. Groups that no longer have any members are also deleted.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *GroupRemoveCommand) Synopsis() string {
+	return "Remove lights from a group, or delete a group"
+}
+
+func (f *GroupRemoveCommand) Name() string { return "group remove" }
+
+func (c *GroupRemoveCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l < 1 {
+		c.UI.Error("This command requires at least (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	groups, err := loadGroups()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	name := args[0]
+	members, ok := groups.Groups[name]
+	if !ok {
+		c.UI.Error(fmt.Sprintf("No group named '%s'", name))
+		return 1
+	}
+
+	var kept groupMembers
+	if len(args) > 1 {
+		remove := make(map[string]bool)
+		for _, light := range args[1:] {
+			remove[strings.TrimSpace(light)] = true
+		}
+
+		for _, member := range members {
+			if remove[member] {
+				delete(remove, member)
+				continue
+			}
+			kept = append(kept, member)
+		}
+
+		for light := range remove {
+			c.UI.Error(fmt.Sprintf("Group '%s' does not contain '%s'", name, light))
+			return 1
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(groups.Groups, name)
+	} else {
+		groups.Groups[name] = kept
+	}
+
+	if err := groups.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(kept) == 0 {
+		c.UI.Info(fmt.Sprintf("Deleted group '%s'", name))
+	} else {
+		c.UI.Info(fmt.Sprintf("Group '%s' now contains: %s", name, strings.Join(kept, ", ")))
+	}
+
+	return 0
+}
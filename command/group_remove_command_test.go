@@ -0,0 +1,29 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestGroupRemoveCommand(t *testing.T) {
+	setupTestConfig(t)
+	writeGroups(t, "desk: [111A, 861A]\nkey: 111A\n")
+
+	ui := cli.NewMockUi()
+	cmd := &GroupRemoveCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "desk", "861A")
+	runCommand(t, cmd, ui, 1, "desk", "9C2B")
+	runCommand(t, cmd, ui, 0, "key")
+	runCommand(t, cmd, ui, 1, "key")
+
+	groups, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+	expected := map[string]groupMembers{"desk": {"111A"}}
+	if !reflect.DeepEqual(groups.Groups, expected) {
+		t.Errorf("expected %v, got %v", expected, groups.Groups)
+	}
+}
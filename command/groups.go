@@ -0,0 +1,151 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// groupsFileName is the name of the group configuration within the config
+	// dir.
+	groupsFileName = "groups.yaml"
+
+	// groupReferencePrefix marks a -light value as a reference to a group
+	// rather than an individual light, e.g: `-light @desk`.
+	groupReferencePrefix = "@"
+)
+
+// groupMembers is the list of lights that belong to a group. In the config
+// file it may be written either as a list, or as a single value for groups
+// that alias a single light, e.g:
+//
+//	desk: [111A, 861A]
+//	key: 111A
+type groupMembers []string
+
+func (m *groupMembers) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*m = groupMembers{value.Value}
+		return nil
+	}
+
+	var members []string
+	if err := value.Decode(&members); err != nil {
+		return err
+	}
+	*m = members
+	return nil
+}
+
+// groupConfig is the set of user defined light groups and aliases.
+type groupConfig struct {
+	Groups map[string]groupMembers
+
+	path string
+}
+
+// loadGroups reads the group configuration from the config dir. A missing
+// file is not an error and results in an empty configuration.
+func loadGroups() (*groupConfig, error) {
+	path, err := configFilePath(groupsFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &groupConfig{
+		Groups: make(map[string]groupMembers),
+		path:   path,
+	}
+
+	err = readYAMLFile(path, &cfg.Groups)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load groups, err: %w", err)
+	}
+	if cfg.Groups == nil {
+		cfg.Groups = make(map[string]groupMembers)
+	}
+
+	return cfg, nil
+}
+
+// Save persists the group configuration to disk.
+func (g *groupConfig) Save() error {
+	if err := writeYAMLFile(g.path, g.Groups); err != nil {
+		return fmt.Errorf("failed to save groups, err: %w", err)
+	}
+
+	return nil
+}
+
+// Names returns the names of all groups in sorted order.
+func (g *groupConfig) Names() []string {
+	var names []string
+	for name := range g.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expand replaces every group reference in lights with the members of the
+// group. Groups may reference other groups.
+func (g *groupConfig) Expand(lights lightListFlags) (lightListFlags, error) {
+	return g.expand(lights, nil)
+}
+
+func (g *groupConfig) expand(lights lightListFlags, parents []string) (lightListFlags, error) {
+	var result lightListFlags
+	for _, light := range lights {
+		if !isGroupReference(light) {
+			result = append(result, light)
+			continue
+		}
+
+		name := strings.TrimPrefix(light, groupReferencePrefix)
+		for _, parent := range parents {
+			if parent == name {
+				return nil, fmt.Errorf("group '%s' references itself", name)
+			}
+		}
+
+		members, ok := g.Groups[name]
+		if !ok {
+			return nil, fmt.Errorf("no group named '%s'", name)
+		}
+
+		expanded, err := g.expand(lightListFlags(members), append(parents, name))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded...)
+	}
+
+	return result, nil
+}
+
+// isGroupReference returns whether the given -light value refers to a group.
+func isGroupReference(light string) bool {
+	return strings.HasPrefix(light, groupReferencePrefix)
+}
+
+// validateName checks that name can be used to define a group or scene.
+func validateName(name string) error {
+	if name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if isGroupReference(name) {
+		return fmt.Errorf("name must not start with '%s'", groupReferencePrefix)
+	}
+
+	if strings.ContainsAny(name, " \t:/") {
+		return errors.New("name must not contain whitespace, ':' or '/'")
+	}
+
+	return nil
+}
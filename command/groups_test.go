@@ -0,0 +1,139 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeGroups writes the groups file in the test config dir.
+func writeGroups(t *testing.T, yaml string) {
+	t.Helper()
+
+	path, err := configFilePath(groupsFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupConfig_Expand(t *testing.T) {
+	setupTestConfig(t)
+	writeGroups(t, `
+desk: [111A, 861A]
+key: 111A
+office: ["@desk", "@key", 9C2B]
+`)
+
+	groups, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		lights   lightListFlags
+		expected lightListFlags
+	}{
+		{name: "no groups", lights: lightListFlags{"111A", "/^Desk/"}, expected: lightListFlags{"111A", "/^Desk/"}},
+		{name: "group", lights: lightListFlags{"@desk"}, expected: lightListFlags{"111A", "861A"}},
+		{name: "alias", lights: lightListFlags{"@key", "9C2B"}, expected: lightListFlags{"111A", "9C2B"}},
+		{name: "nested", lights: lightListFlags{"@office"}, expected: lightListFlags{"111A", "861A", "111A", "9C2B"}},
+		{name: "repeated", lights: lightListFlags{"@key", "@key"}, expected: lightListFlags{"111A", "111A"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expanded, err := groups.Expand(tc.lights)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(expanded, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, expanded)
+			}
+		})
+	}
+}
+
+func TestGroupConfig_ExpandInvalid(t *testing.T) {
+	setupTestConfig(t)
+	writeGroups(t, `
+self: ["@self"]
+a: ["@b"]
+b: [111A, "@c"]
+c: ["@a"]
+missing: ["@nothing"]
+`)
+
+	groups, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+
+	cases := map[string]string{
+		"@self":    "group 'self' references itself",
+		"@a":       "group 'a' references itself",
+		"@c":       "group 'c' references itself",
+		"@missing": "no group named 'nothing'",
+		"@unknown": "no group named 'unknown'",
+	}
+
+	for light, expected := range cases {
+		t.Run(light, func(t *testing.T) {
+			_, err := groups.Expand(lightListFlags{light})
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected an error containing %q, got %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestGroupConfig_SaveAndLoad(t *testing.T) {
+	setupTestConfig(t)
+
+	groups, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+	if len(groups.Groups) != 0 {
+		t.Fatalf("expected no groups without a file, got %v", groups.Groups)
+	}
+
+	groups.Groups["desk"] = groupMembers{"111A", "861A"}
+	groups.Groups["key"] = groupMembers{"111A"}
+	if err := groups.Save(); err != nil {
+		t.Fatalf("failed to save groups: %v", err)
+	}
+
+	loaded, err := loadGroups()
+	if err != nil {
+		t.Fatalf("failed to load groups: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Groups, groups.Groups) {
+		t.Errorf("expected %v, got %v", groups.Groups, loaded.Groups)
+	}
+	if names := loaded.Names(); !reflect.DeepEqual(names, []string{"desk", "key"}) {
+		t.Errorf("expected sorted names, got %v", names)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"desk", "desk-left", "Desk_2"} {
+		if err := validateName(name); err != nil {
+			t.Errorf("%q: unexpected error: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "@desk", "left desk", "desk:left", "desk/left", "desk\tleft"} {
+		if err := validateName(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
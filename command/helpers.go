@@ -2,8 +2,8 @@ package command
 
 import (
 	"context"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/endocrimes/keylight-go"
 )
@@ -19,18 +19,54 @@ func (f *lightListFlags) Set(value string) error {
 	return nil
 }
 
+// displayNameTimeout is the maximum time we wait for a light to return its
+// accessory info when looking up its display name.
+const displayNameTimeout = 2 * time.Second
+
+// fetchDisplayName returns the display name that is configured on the light,
+// or an empty string if it could not be retrieved.
+func fetchDisplayName(ctx context.Context, light *keylight.KeyLight) string {
+	ctx, cancelFn := context.WithTimeout(ctx, displayNameTimeout)
+	defer cancelFn()
+
+	info, err := light.FetchAccessoryInfo(ctx)
+	if err != nil {
+		return ""
+	}
+	return info.DisplayName
+}
+
+// lightLabel returns a human readable identifier for the light that can also
+// be passed back to -light, falling back to its address for lights that were
+// provided directly.
+func lightLabel(light *keylight.KeyLight) string {
+	if light.Name != "" {
+		return light.Name
+	}
+	return joinLightAddress(light.DNSAddr, light.Port)
+}
+
 type lightDiscoverer struct {
 	RequiredLights []string
 	AllLights      bool
 	Discovery      keylight.Discovery
 
+	selectors        []*lightSelector
 	discoveredLights map[string]*keylight.KeyLight
+	matches          map[*lightSelector][]lightIdentity
+	identities       map[string]lightIdentity
 }
 
 func (l *lightDiscoverer) runCollector(ctx context.Context) error {
 	if l.discoveredLights == nil {
 		l.discoveredLights = make(map[string]*keylight.KeyLight)
 	}
+	if l.matches == nil {
+		l.matches = make(map[*lightSelector][]lightIdentity)
+	}
+	if l.identities == nil {
+		l.identities = make(map[string]lightIdentity)
+	}
 
 	resultsCh := l.Discovery.ResultsCh()
 	for {
@@ -47,39 +83,79 @@ func (l *lightDiscoverer) runCollector(ctx context.Context) error {
 				continue
 			}
 
-			for _, req := range l.RequiredLights {
-				// TODO: Should check if the requirement is a full name or short name
-				//       and compare differently based on the two (full match vs suffix)
-				if strings.HasSuffix(light.Name, req) {
-					l.discoveredLights[light.Name] = light
+			_, seen := l.identities[light.Name]
+			identity := l.identify(ctx, light)
+			for _, s := range l.selectors {
+				if !s.Matches(identity) {
+					continue
+				}
+				l.discoveredLights[light.Name] = light
+				if !seen {
+					l.matches[s] = append(l.matches[s], identity)
 				}
 			}
 
-			if len(l.discoveredLights) == len(l.RequiredLights) {
+			if l.complete() {
 				return nil
 			}
 		}
 	}
 }
 
-func validateAllRequiredLights(lights []*keylight.KeyLight, requirements []string) error {
-	if len(requirements) == 0 {
-		return nil
+// complete returns whether discovery can stop early, because every selector
+// has been matched by the mDNS name of a light. Globs and regular expressions
+// may match lights that have not been discovered yet, and display names and
+// serial numbers may be shared by lights that have not been discovered yet, so
+// if any selector relies on them, discovery runs until the context is done in
+// order to detect ambiguous selectors.
+func (l *lightDiscoverer) complete() bool {
+	for _, s := range l.selectors {
+		matches := l.matches[s]
+		if s.IsPattern() || len(matches) == 0 || !s.MatchesName(matches[0]) {
+			return false
+		}
 	}
+	return true
+}
 
-REQUIREMENTS:
-	for _, req := range requirements {
-		for _, light := range lights {
-			if strings.HasSuffix(light.Name, req) {
-				continue REQUIREMENTS
-			}
+// identify returns the identity of a discovered light. The display name and
+// serial number are only fetched from the light if a selector does not match
+// its name or address, as it requires an additional request.
+func (l *lightDiscoverer) identify(ctx context.Context, light *keylight.KeyLight) lightIdentity {
+	if identity, ok := l.identities[light.Name]; ok {
+		return identity
+	}
+
+	identity := newLightIdentity(light)
+	for _, s := range l.selectors {
+		if !s.Matches(identity) {
+			identity = fetchLightIdentity(ctx, light)
+			break
+		}
+	}
+
+	l.identities[light.Name] = identity
+	return identity
+}
+
+// validateAllRequiredLights ensures that every selector was matched by a
+// discovered light, and that no exact selector matched several lights.
+func (l *lightDiscoverer) validateAllRequiredLights() error {
+	for _, s := range l.selectors {
+		if err := s.Check(l.matches[s]); err != nil {
+			return err
 		}
-		return fmt.Errorf("no light found for requirement '%s'", req)
 	}
 
 	return nil
 }
 
+// Matched returns the identities of the lights that matched the idx'th
+// required light.
+func (l *lightDiscoverer) Matched(idx int) []lightIdentity {
+	return l.matches[l.selectors[idx]]
+}
+
 func (l *lightDiscoverer) DiscoveredLights() []*keylight.KeyLight {
 	var result []*keylight.KeyLight
 	for _, light := range l.discoveredLights {
@@ -89,6 +165,12 @@ func (l *lightDiscoverer) DiscoveredLights() []*keylight.KeyLight {
 }
 
 func (l *lightDiscoverer) Run(ctx context.Context) ([]*keylight.KeyLight, error) {
+	selectors, err := parseLightSelectors(l.RequiredLights)
+	if err != nil {
+		return nil, err
+	}
+	l.selectors = selectors
+
 	childCtx, cancelFn := context.WithCancel(ctx)
 	defer cancelFn()
 
@@ -102,20 +184,33 @@ func (l *lightDiscoverer) Run(ctx context.Context) ([]*keylight.KeyLight, error)
 		doneCh <- err
 	}()
 
-	err := l.runCollector(childCtx)
+	err = l.runCollector(childCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	discoveryErr := <-doneCh
+	// Stop discovery if the collector finished early, and keep draining its
+	// results so that it is not blocked on a full channel while stopping.
+	cancelFn()
+	resultsCh := l.Discovery.ResultsCh()
+	var discoveryErr error
+	for done := false; !done; {
+		select {
+		case discoveryErr = <-doneCh:
+			done = true
+		case _, ok := <-resultsCh:
+			if !ok {
+				resultsCh = nil
+			}
+		}
+	}
 	if discoveryErr != nil {
 		return nil, discoveryErr
 	}
 
-	lights := l.DiscoveredLights()
-	err = validateAllRequiredLights(lights, l.RequiredLights)
+	err = l.validateAllRequiredLights()
 	if err != nil {
 		return nil, err
 	}
-	return lights, nil
+	return l.DiscoveredLights(), nil
 }
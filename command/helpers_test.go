@@ -0,0 +1,211 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// setupTestConfig points the config dir at a temporary directory and disables
+// the agent, so that commands only talk to the lights that a test provides.
+func setupTestConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("XDG_CONFIG_DIRS", dir)
+	t.Setenv(agentAddrEnvVar, "off")
+	t.Setenv(configFileEnvVar, "")
+	t.Setenv(configProfileEnvVar, "")
+	for _, name := range configSettingNames {
+		t.Setenv(configEnvVarPrefix+strings.ToUpper(name), "")
+	}
+
+	return dir
+}
+
+// newTestLight starts a simulated accessory and returns it along with its
+// host:port address.
+func newTestLight(t *testing.T, cfg simulator.Config) (*simulator.Simulator, string) {
+	t.Helper()
+
+	sim := simulator.New(cfg)
+	srv := httptest.NewServer(sim)
+	t.Cleanup(srv.Close)
+
+	return sim, strings.TrimPrefix(srv.URL, "http://")
+}
+
+// lightFromAddress returns a client for the light at a host:port address.
+func lightFromAddress(t *testing.T, addr string) *keylight.KeyLight {
+	t.Helper()
+
+	address, err := parseLightAddress(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	return address.KeyLight()
+}
+
+// runCommand runs the command with the given arguments, failing the test if
+// the exit code does not match.
+func runCommand(t *testing.T, c cli.Command, ui *cli.MockUi, expected int, args ...string) {
+	t.Helper()
+
+	if code := c.Run(args); code != expected {
+		t.Fatalf("expected exit code %d, got %d\nstdout: %s\nstderr: %s", expected, code, ui.OutputWriter.String(), ui.ErrorWriter.String())
+	}
+}
+
+// captureStdout returns everything that fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	fn()
+	w.Close()
+	return <-done
+}
+
+// fakeDiscovery reports the given lights, and then runs until it is stopped.
+type fakeDiscovery struct {
+	lights    []*keylight.KeyLight
+	resultsCh chan *keylight.KeyLight
+}
+
+func newFakeDiscovery(lights ...*keylight.KeyLight) *fakeDiscovery {
+	return &fakeDiscovery{
+		lights:    lights,
+		resultsCh: make(chan *keylight.KeyLight, 1),
+	}
+}
+
+func (d *fakeDiscovery) Run(ctx context.Context) error {
+	defer close(d.resultsCh)
+
+	for _, light := range d.lights {
+		select {
+		case d.resultsCh <- light:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (d *fakeDiscovery) ResultsCh() <-chan *keylight.KeyLight {
+	return d.resultsCh
+}
+
+// newDiscoveredTestLight starts a simulated accessory and returns it as it
+// would be reported by discovery.
+func newDiscoveredTestLight(t *testing.T, name string, cfg simulator.Config) *keylight.KeyLight {
+	t.Helper()
+
+	_, addr := newTestLight(t, cfg)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	return &keylight.KeyLight{Name: name, DNSAddr: host, Port: portNum}
+}
+
+func TestLightDiscoverer_StopsAtName(t *testing.T) {
+	left := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 111A`, simulator.Config{DisplayName: "Desk"})
+	right := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 222B`, simulator.Config{DisplayName: "Desk"})
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFn()
+
+	discoverer := lightDiscoverer{
+		Discovery:      newFakeDiscovery(left, right),
+		RequiredLights: []string{"111A"},
+	}
+
+	start := time.Now()
+	found, err := discoverer.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected discovery to stop once 111A was found, took %s", elapsed)
+	}
+	if len(found) != 1 || found[0].Name != left.Name {
+		t.Errorf("expected only 111A to be found, got %v", found)
+	}
+}
+
+func TestLightDiscoverer_AmbiguousDisplayName(t *testing.T) {
+	left := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 111A`, simulator.Config{DisplayName: "Desk"})
+	right := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 222B`, simulator.Config{DisplayName: "Desk"})
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancelFn()
+
+	discoverer := lightDiscoverer{
+		Discovery:      newFakeDiscovery(left, right),
+		RequiredLights: []string{"Desk"},
+	}
+
+	_, err := discoverer.Run(ctx)
+	var ambiguous *ambiguousSelectorError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected an ambiguous selector error, got %v", err)
+	}
+	if len(ambiguous.Candidates) != 2 {
+		t.Errorf("expected 2 candidates, got %v", ambiguous.Candidates)
+	}
+}
+
+func TestLightDiscoverer_Pattern(t *testing.T) {
+	left := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 111A`, simulator.Config{DisplayName: "Desk left"})
+	right := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 222B`, simulator.Config{DisplayName: "Desk right"})
+	other := newDiscoveredTestLight(t, `Elgato\ Key\ Light\ 333C`, simulator.Config{DisplayName: "Shelf"})
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancelFn()
+
+	discoverer := lightDiscoverer{
+		Discovery:      newFakeDiscovery(left, right, other),
+		RequiredLights: []string{"/^desk/"},
+	}
+
+	found, err := discoverer.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected both desk lights to be found, got %v", found)
+	}
+}
@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type IdentifyCommand struct {
+	Meta
+}
+
+func (c *IdentifyCommand) Help() string {
+	helpText := `
+Usage: keylightctl identify [options]
+
+ Flash keylights so that they can be found physically. Use -sequential to
+ flash each light in turn while printing its name, e.g. to find out which
+ fixture is which:
+
+     $ keylightctl identify -all -sequential
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Identify Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Identify all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Identify") + `
+
+  -group <group>
+    Identify all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -sequential
+    Identify one light at a time, in order, printing the name of each light
+    as it flashes.
+
+  -interval <duration>
+    Sets the time between lights when identifying sequentially (default: 5s)
+
+  ` + fanOutOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *IdentifyCommand) Synopsis() string {
+	return "Flash keylights to find them physically"
+}
+
+func (f *IdentifyCommand) Name() string { return "identify" }
+
+func (c *IdentifyCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout, interval time.Duration
+	var selection lightSelection
+	var fanOut lightFanOut
+	var sequential bool
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.BoolVar(&sequential, "sequential", false, "")
+	flags.DurationVar(&interval, "interval", 5*time.Second, "")
+	fanOut.AddFlags(flags)
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval < 0 {
+		c.UI.Error("--interval must not be negative")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	if sequential {
+		return reportResults(c.UI, c.identifySequentially(ctx, found, interval))
+	}
+
+	updateCtx, updateCancelFn := context.WithTimeout(ctx, 15*time.Second)
+	defer updateCancelFn()
+
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		return identifyLight(ctx, light)
+	})
+
+	return reportResults(c.UI, results)
+}
+
+// identifySequentially identifies each light in turn, waiting interval after
+// each light so that the flashes can be told apart. Lights that were not
+// reached before ctx is cancelled are skipped.
+func (c *IdentifyCommand) identifySequentially(ctx context.Context, lights []*keylight.KeyLight, interval time.Duration) []*lightResult {
+	results := make([]*lightResult, len(lights))
+	for idx, light := range lights {
+		results[idx] = &lightResult{Light: light}
+		if ctx.Err() != nil {
+			results[idx].Err = errSkipped
+			continue
+		}
+
+		label := lightLabel(light)
+		if displayName := fetchDisplayName(ctx, light); displayName != "" {
+			label = fmt.Sprintf("%s (%s)", label, displayName)
+		}
+		c.UI.Output(fmt.Sprintf("[%d/%d] Identifying %s", idx+1, len(lights), label))
+
+		identifyCtx, cancelFn := context.WithTimeout(ctx, agentLightTimeout)
+		results[idx].Err = identifyLight(identifyCtx, light)
+		cancelFn()
+
+		if results[idx].Err == nil && idx < len(lights)-1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+	}
+	return results
+}
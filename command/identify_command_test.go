@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestIdentifyCommand(t *testing.T) {
+	setupTestConfig(t)
+	first, firstAddr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740"})
+	second, secondAddr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02741"})
+
+	ui := cli.NewMockUi()
+	cmd := &IdentifyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "-light", firstAddr, "-light", secondAddr)
+
+	if first.Identified() != 1 || second.Identified() != 1 {
+		t.Errorf("expected each light to be identified once, got %d and %d", first.Identified(), second.Identified())
+	}
+}
+
+func TestIdentifyCommand_Sequential(t *testing.T) {
+	setupTestConfig(t)
+	first, firstAddr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740", DisplayName: "Left"})
+	second, secondAddr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02741", DisplayName: "Right"})
+
+	ui := cli.NewMockUi()
+	cmd := &IdentifyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "-light", firstAddr, "-light", secondAddr, "-sequential", "-interval", "10ms")
+
+	if first.Identified() != 1 || second.Identified() != 1 {
+		t.Errorf("expected each light to be identified once, got %d and %d", first.Identified(), second.Identified())
+	}
+
+	out := ui.OutputWriter.String()
+	for _, expected := range []string{"[1/2] Identifying", "[2/2] Identifying", "(Left)", "(Right)"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestIdentifyCommand_InvalidInterval(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{})
+
+	ui := cli.NewMockUi()
+	cmd := &IdentifyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 1, "-light", addr, "-sequential", "-interval", "-1s")
+
+	if sim.Identified() != 0 {
+		t.Errorf("expected the light not to be identified")
+	}
+}
+
+// identifyTestLights starts n simulated accessories and returns them along
+// with their clients.
+func identifyTestLights(t *testing.T, n int, cfg simulator.Config) ([]*simulator.Simulator, []*keylight.KeyLight) {
+	t.Helper()
+
+	var sims []*simulator.Simulator
+	var lights []*keylight.KeyLight
+	for i := 0; i < n; i++ {
+		sim, addr := newTestLight(t, cfg)
+		sims = append(sims, sim)
+		lights = append(lights, lightFromAddress(t, addr))
+	}
+	return sims, lights
+}
+
+func TestIdentifySequentially_Interrupted(t *testing.T) {
+	sims, lights := identifyTestLights(t, 3, simulator.Config{})
+
+	// The first light is identified immediately, and the command is
+	// interrupted while it waits before the second.
+	ctx, cancelFn := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelFn()
+
+	cmd := &IdentifyCommand{Meta: Meta{UI: cli.NewMockUi()}}
+	results := cmd.identifySequentially(ctx, lights, time.Minute)
+
+	if results[0].Err != nil || sims[0].Identified() != 1 {
+		t.Errorf("expected the first light to be identified, got %v", results[0].Err)
+	}
+	for idx, r := range results[1:] {
+		if r.Err != errSkipped || sims[idx+1].Identified() != 0 {
+			t.Errorf("light %d: expected to be skipped, got %v", idx+1, r.Err)
+		}
+	}
+}
+
+func TestIdentifySequentially_Failure(t *testing.T) {
+	sims, lights := identifyTestLights(t, 2, simulator.Config{})
+	sims[0].SetFailureRate(1)
+
+	// A light that fails to flash is not waited for.
+	cmd := &IdentifyCommand{Meta: Meta{UI: cli.NewMockUi()}}
+	start := time.Now()
+	results := cmd.identifySequentially(context.Background(), lights, time.Minute)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the interval to be skipped after a failure, took %s", elapsed)
+	}
+
+	if results[0].Err == nil {
+		t.Errorf("expected the first light to fail")
+	}
+	if results[1].Err != nil || sims[1].Identified() != 1 {
+		t.Errorf("expected the second light to be identified, got %v", results[1].Err)
+	}
+}
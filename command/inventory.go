@@ -0,0 +1,216 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+const (
+	// inventoryFileName is the name of the light inventory within the config
+	// dir.
+	inventoryFileName = "inventory.json"
+
+	// inventoryProbeTimeout is the maximum amount of time we will wait when
+	// checking whether a cached light is still reachable at its last known
+	// address.
+	inventoryProbeTimeout = 500 * time.Millisecond
+)
+
+// inventoryEntry is a single light that has been recorded in the inventory.
+type inventoryEntry struct {
+	Name         string    `json:"name"`
+	ShortID      string    `json:"short_id"`
+	DNSAddr      string    `json:"dns_addr"`
+	Port         int       `json:"port"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+	DisplayName  string    `json:"display_name,omitempty"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// KeyLight returns a client for the light at its last known address.
+func (e *inventoryEntry) KeyLight() *keylight.KeyLight {
+	return &keylight.KeyLight{
+		Name:    e.Name,
+		DNSAddr: e.DNSAddr,
+		Port:    e.Port,
+	}
+}
+
+// Identity returns the names that the light may be referred to by.
+func (e *inventoryEntry) Identity() lightIdentity {
+	return lightIdentity{
+		Name:         e.Name,
+		DisplayName:  e.DisplayName,
+		SerialNumber: e.SerialNumber,
+		Address:      e.Address(),
+	}
+}
+
+// Address returns the host:port pair the light was last seen at.
+func (e *inventoryEntry) Address() string {
+	return joinLightAddress(e.DNSAddr, e.Port)
+}
+
+// Reachable performs a cheap TCP probe to check whether the light is still
+// listening at its last known address.
+func (e *inventoryEntry) Reachable() bool {
+	conn, err := net.DialTimeout("tcp", e.Address(), inventoryProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// inventory is the on-disk cache of previously discovered lights, used to
+// avoid paying for mDNS discovery on every invocation.
+type inventory struct {
+	Lights []*inventoryEntry `json:"lights"`
+
+	path string
+}
+
+// loadInventory reads the inventory from the config dir. A missing inventory
+// file is not an error and results in an empty inventory.
+func loadInventory() (*inventory, error) {
+	path, err := configFilePath(inventoryFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &inventory{path: path}
+	err = readJSONFile(path, inv)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load inventory, err: %w", err)
+	}
+
+	return inv, nil
+}
+
+// Save persists the inventory to disk.
+func (i *inventory) Save() error {
+	sort.Slice(i.Lights, func(a, b int) bool {
+		return i.Lights[a].Name < i.Lights[b].Name
+	})
+
+	if err := writeJSONFile(i.path, i); err != nil {
+		return fmt.Errorf("failed to save inventory, err: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup returns all of the entries that match the given selector.
+func (i *inventory) Lookup(s *lightSelector) []*inventoryEntry {
+	var result []*inventoryEntry
+	for _, entry := range i.Lights {
+		if s.Matches(entry.Identity()) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// inventoryIdentities returns the identities of the given entries.
+func inventoryIdentities(entries []*inventoryEntry) []lightIdentity {
+	var result []lightIdentity
+	for _, entry := range entries {
+		result = append(result, entry.Identity())
+	}
+	return result
+}
+
+// Get returns the entry with the given full name, or nil if it is unknown.
+func (i *inventory) Get(name string) *inventoryEntry {
+	for _, entry := range i.Lights {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// GetBySerialNumber returns the entry with the given serial number, or nil if
+// it is unknown.
+func (i *inventory) GetBySerialNumber(serial string) *inventoryEntry {
+	for _, entry := range i.Lights {
+		if entry.SerialNumber != "" && strings.EqualFold(entry.SerialNumber, serial) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Record inserts or updates the given entry, keyed by its name. An entry with
+// the same serial number replaces the existing one, e.g: when a light that was
+// first provided by address is later discovered under its mDNS name.
+func (i *inventory) Record(entry *inventoryEntry) {
+	for idx, existing := range i.Lights {
+		if existing.Name == entry.Name || entry.SerialNumber != "" && strings.EqualFold(existing.SerialNumber, entry.SerialNumber) {
+			i.Lights[idx] = entry
+			return
+		}
+	}
+	i.Lights = append(i.Lights, entry)
+}
+
+// Forget removes all entries that match the given selector and returns the
+// removed entries.
+func (i *inventory) Forget(s *lightSelector) []*inventoryEntry {
+	var kept, removed []*inventoryEntry
+	for _, entry := range i.Lights {
+		if s.Matches(entry.Identity()) {
+			removed = append(removed, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	i.Lights = kept
+	return removed
+}
+
+// newInventoryEntry builds an inventory entry for a freshly discovered light.
+// If known is non-nil and already has a serial number, its accessory info is
+// reused rather than querying the light again.
+func newInventoryEntry(ctx context.Context, light *keylight.KeyLight, known *inventoryEntry) (*inventoryEntry, error) {
+	entry := &inventoryEntry{
+		Name:     light.Name,
+		ShortID:  lightShortID(light.Name),
+		DNSAddr:  light.DNSAddr,
+		Port:     light.Port,
+		LastSeen: time.Now().UTC(),
+	}
+
+	if known != nil && known.SerialNumber != "" {
+		entry.SerialNumber = known.SerialNumber
+		entry.DisplayName = known.DisplayName
+		return entry, nil
+	}
+
+	info, err := light.FetchAccessoryInfo(ctx)
+	if err != nil {
+		return entry, fmt.Errorf("failed to fetch accessory info (%s), err: %w", light.Name, err)
+	}
+	entry.SerialNumber = info.SerialNumber
+	entry.DisplayName = info.DisplayName
+
+	return entry, nil
+}
+
+// lightShortID returns the short identifier of a light from its mDNS instance
+// name, e.g: `Elgato\ Key\ Light\ 111A` => `111A`.
+func lightShortID(name string) string {
+	fields := strings.Fields(strings.ReplaceAll(name, `\ `, " "))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
@@ -0,0 +1,49 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type InventoryCommand struct {
+	Meta
+}
+
+func (c *InventoryCommand) Help() string {
+	helpText := `
+Usage: keylightctl inventory <subcommand> [options] [args]
+
+ This command groups subcommands for interacting with the light inventory.
+
+ The inventory is an on-disk cache of lights that have previously been
+ discovered. Commands that accept -light will use the recorded address of a
+ light rather than going through mDNS discovery, and will only fall back to
+ discovery if the light is unknown or no longer reachable.
+
+ Record all lights that are currently available on the local network:
+
+     $ keylightctl inventory refresh
+
+ List the recorded lights:
+
+     $ keylightctl inventory list
+
+ Remove a light from the inventory:
+
+     $ keylightctl inventory forget 111A
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *InventoryCommand) Synopsis() string {
+	return "Interact with the light inventory"
+}
+
+func (f *InventoryCommand) Name() string { return "inventory" }
+
+func (c *InventoryCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
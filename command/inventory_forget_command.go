@@ -0,0 +1,115 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type InventoryForgetCommand struct {
+	Meta
+}
+
+func (c *InventoryForgetCommand) Help() string {
+	helpText := `
+Usage: keylightctl inventory forget [options] <light-id>...
+
+ Remove lights from the inventory. Each light can either be a full key light
+ name, e.g: Elgato\ Key\ Light\ 111A, a short ID, e.g: 111A, the display
+ name or serial number of the light, or a glob or /regex/ to remove several
+ lights at once.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Inventory Forget Options:
+
+  -all
+    Remove every light from the inventory.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *InventoryForgetCommand) Synopsis() string {
+	return "Remove keylights from the inventory"
+}
+
+func (f *InventoryForgetCommand) Name() string { return "inventory forget" }
+
+func (c *InventoryForgetCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var allLights bool
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.BoolVar(&allLights, "all", false, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if allLights && len(args) != 0 {
+		c.UI.Error("Cannot specify --all and lights together")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if !allLights && len(args) == 0 {
+		c.UI.Error("One of --all or at least one light must be provided")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	var removed []*inventoryEntry
+	if allLights {
+		removed = inv.Lights
+		inv.Lights = nil
+	}
+
+	for _, req := range args {
+		selector, err := parseLightSelector(strings.TrimSpace(req))
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+
+		matches := inv.Lookup(selector)
+		if len(matches) == 0 {
+			c.UI.Error(fmt.Sprintf("No light in the inventory matches '%s'", req))
+			return 1
+		}
+		if err := selector.Check(inventoryIdentities(matches)); err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+
+		removed = append(removed, inv.Forget(selector)...)
+	}
+
+	if err := inv.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	for _, entry := range removed {
+		c.UI.Output(fmt.Sprintf("- %s", entry.Name))
+	}
+	c.UI.Info(fmt.Sprintf("Removed %d light(s) from the inventory", len(removed)))
+
+	return 0
+}
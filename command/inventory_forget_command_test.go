@@ -0,0 +1,51 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestInventoryForgetCommand(t *testing.T) {
+	cases := []struct {
+		name      string
+		args      []string
+		code      int
+		remaining int
+	}{
+		{name: "short id", args: []string{"111A"}, remaining: 2},
+		{name: "several", args: []string{"111A", "9C2B"}, remaining: 1},
+		{name: "glob", args: []string{"*A"}, remaining: 1},
+		{name: "all", args: []string{"-all"}, remaining: 0},
+		{name: "ambiguous", args: []string{"Desk"}, code: 1, remaining: 3},
+		{name: "unknown", args: []string{"111A", "4F1E"}, code: 1, remaining: 3},
+		{name: "no lights", args: []string{}, code: 1, remaining: 3},
+		{name: "all and lights", args: []string{"-all", "111A"}, code: 1, remaining: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTestConfig(t)
+			inv, err := loadInventory()
+			if err != nil {
+				t.Fatalf("failed to load inventory: %v", err)
+			}
+			inv.Lights = testInventoryEntries()
+			if err := inv.Save(); err != nil {
+				t.Fatalf("failed to save inventory: %v", err)
+			}
+
+			ui := cli.NewMockUi()
+			cmd := &InventoryForgetCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, tc.code, tc.args...)
+
+			inv, err = loadInventory()
+			if err != nil {
+				t.Fatalf("failed to load inventory: %v", err)
+			}
+			if len(inv.Lights) != tc.remaining {
+				t.Errorf("expected %d lights to remain, got %d", tc.remaining, len(inv.Lights))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type InventoryListCommand struct {
+	Meta
+}
+
+func (c *InventoryListCommand) Help() string {
+	helpText := `
+Usage: keylightctl inventory list [options]
+
+ List the keylights that are recorded in the inventory.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *InventoryListCommand) Synopsis() string {
+	return "List the keylights recorded in the inventory"
+}
+
+func (f *InventoryListCommand) Name() string { return "inventory list" }
+
+func (c *InventoryListCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(inv.Lights) == 0 {
+		c.UI.Error("No lights are recorded in the inventory, try 'keylightctl inventory refresh'")
+		return 1
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "Name", "Short ID", "Display Name", "Address", "Serial Number", "Last Seen"})
+
+	for idx, entry := range inv.Lights {
+		t.AppendRows([]table.Row{
+			{idx, entry.Name, entry.ShortID, entry.DisplayName, entry.Address(), entry.SerialNumber, entry.LastSeen.Local().Format(time.RFC3339)},
+		})
+	}
+	t.Render()
+
+	return 0
+}
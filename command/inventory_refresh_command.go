@@ -0,0 +1,128 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type InventoryRefreshCommand struct {
+	Meta
+}
+
+func (c *InventoryRefreshCommand) Help() string {
+	helpText := `
+Usage: keylightctl inventory refresh [options]
+
+ Discover all keylights on the local network and record them in the inventory.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Inventory Refresh Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -prune
+    Remove lights from the inventory that were not found during discovery.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *InventoryRefreshCommand) Synopsis() string {
+	return "Record discovered keylights in the inventory"
+}
+
+func (f *InventoryRefreshCommand) Name() string { return "inventory refresh" }
+
+func (c *InventoryRefreshCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var prune bool
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	flags.BoolVar(&prune, "prune", false, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	discovery, err := keylight.NewDiscovery()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to setup mDNS discovery, err: %v", err))
+		return 1
+	}
+
+	discoverer := lightDiscoverer{
+		Discovery: discovery,
+		AllLights: true,
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	c.UI.Info("Starting discovery")
+
+	found, err := discoverer.Run(discoveryCtx)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to discover lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no accessories during discovery")
+		return 1
+	}
+
+	if prune {
+		inv.Lights = nil
+	}
+
+	infoCtx, infoCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer infoCancelFn()
+
+	for _, light := range found {
+		entry, err := newInventoryEntry(infoCtx, light, nil)
+		if err != nil {
+			c.UI.Warn(fmt.Sprintf("Recording light without serial number, err: %v", err))
+		}
+		inv.Record(entry)
+		c.UI.Output(fmt.Sprintf("- %s (%s)", entry.Name, entry.Address()))
+	}
+
+	if err := inv.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Info(fmt.Sprintf("Recorded %d light(s) in the inventory", len(found)))
+
+	return 0
+}
@@ -0,0 +1,183 @@
+package command
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+)
+
+// testInventoryEntries returns entries for three lights, two of which share
+// a display name.
+func testInventoryEntries() []*inventoryEntry {
+	return []*inventoryEntry{
+		{Name: `Elgato\ Key\ Light\ 861A`, ShortID: "861A", DNSAddr: "192.168.1.21", Port: 9123, SerialNumber: "BW33J1A02741", DisplayName: "Desk"},
+		{Name: `Elgato\ Key\ Light\ 111A`, ShortID: "111A", DNSAddr: "192.168.1.20", Port: 9123, SerialNumber: "BW33J1A02740", DisplayName: "Desk"},
+		{Name: `Elgato\ Key\ Light\ 9C2B`, ShortID: "9C2B", DNSAddr: "192.168.1.22", Port: 9123},
+	}
+}
+
+func TestInventory_Record(t *testing.T) {
+	inv := &inventory{Lights: testInventoryEntries()}
+
+	// An entry with a known name is replaced.
+	inv.Record(&inventoryEntry{Name: `Elgato\ Key\ Light\ 9C2B`, DNSAddr: "192.168.1.30", Port: 9123})
+	if entry := inv.Get(`Elgato\ Key\ Light\ 9C2B`); entry == nil || entry.DNSAddr != "192.168.1.30" {
+		t.Errorf("expected the entry to be updated, got %+v", entry)
+	}
+
+	// A light that was recorded under its address is replaced once it is
+	// discovered under its mDNS name.
+	inv.Record(&inventoryEntry{Name: "192.168.1.20:9123", DNSAddr: "192.168.1.20", Port: 9123, SerialNumber: "bw33j1a02740"})
+	if entry := inv.GetBySerialNumber("BW33J1A02740"); entry == nil || entry.Name != "192.168.1.20:9123" {
+		t.Errorf("expected the entry with the same serial number to be replaced, got %+v", entry)
+	}
+	if entry := inv.Get(`Elgato\ Key\ Light\ 111A`); entry != nil {
+		t.Errorf("expected the previous entry to be gone, got %+v", entry)
+	}
+
+	// Entries without a serial number never match each other by it.
+	inv.Record(&inventoryEntry{Name: `Elgato\ Key\ Light\ 4F1E`, DNSAddr: "192.168.1.23", Port: 9123})
+	if len(inv.Lights) != 4 {
+		t.Errorf("expected a new entry to be added, got %d entries", len(inv.Lights))
+	}
+}
+
+func TestInventory_Forget(t *testing.T) {
+	cases := []struct {
+		selector string
+		removed  []string
+	}{
+		{selector: "111A", removed: []string{`Elgato\ Key\ Light\ 111A`}},
+		{selector: "Desk", removed: []string{`Elgato\ Key\ Light\ 861A`, `Elgato\ Key\ Light\ 111A`}},
+		{selector: "BW33J1A02741", removed: []string{`Elgato\ Key\ Light\ 861A`}},
+		{selector: "*9C*", removed: []string{`Elgato\ Key\ Light\ 9C2B`}},
+		{selector: "4F1E", removed: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.selector, func(t *testing.T) {
+			inv := &inventory{Lights: testInventoryEntries()}
+			s, err := parseLightSelector(tc.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			removed := inv.Forget(s)
+			if len(removed) != len(tc.removed) {
+				t.Fatalf("expected %d entries to be removed, got %d", len(tc.removed), len(removed))
+			}
+			for idx, entry := range removed {
+				if entry.Name != tc.removed[idx] {
+					t.Errorf("expected %s to be removed, got %s", tc.removed[idx], entry.Name)
+				}
+				if inv.Get(entry.Name) != nil {
+					t.Errorf("expected %s to be gone from the inventory", entry.Name)
+				}
+			}
+			if len(inv.Lights)+len(removed) != 3 {
+				t.Errorf("expected the other entries to be kept, got %d", len(inv.Lights))
+			}
+		})
+	}
+}
+
+func TestInventory_SaveAndLoad(t *testing.T) {
+	setupTestConfig(t)
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("failed to load inventory: %v", err)
+	}
+	if len(inv.Lights) != 0 {
+		t.Fatalf("expected an empty inventory without a file, got %d entries", len(inv.Lights))
+	}
+
+	for _, entry := range testInventoryEntries() {
+		inv.Record(entry)
+	}
+	if err := inv.Save(); err != nil {
+		t.Fatalf("failed to save inventory: %v", err)
+	}
+
+	loaded, err := loadInventory()
+	if err != nil {
+		t.Fatalf("failed to load inventory: %v", err)
+	}
+
+	expected := []string{`Elgato\ Key\ Light\ 111A`, `Elgato\ Key\ Light\ 861A`, `Elgato\ Key\ Light\ 9C2B`}
+	if len(loaded.Lights) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(loaded.Lights))
+	}
+	for idx, entry := range loaded.Lights {
+		if entry.Name != expected[idx] {
+			t.Errorf("expected the entries to be sorted by name, got %s at %d", entry.Name, idx)
+		}
+	}
+	if entry := loaded.Get(`Elgato\ Key\ Light\ 861A`); entry.SerialNumber != "BW33J1A02741" || entry.DisplayName != "Desk" || entry.Address() != "192.168.1.21:9123" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestInventoryEntry_Reachable(t *testing.T) {
+	_, addr := newTestLight(t, simulator.Config{})
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	entry := &inventoryEntry{Name: `Elgato\ Key\ Light\ 111A`, DNSAddr: host, Port: portNum}
+	if !entry.Reachable() {
+		t.Errorf("expected the light to be reachable")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	entry.Port = closedPort
+	if entry.Reachable() {
+		t.Errorf("expected a closed port not to be reachable")
+	}
+}
+
+func TestNewInventoryEntry(t *testing.T) {
+	_, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740", DisplayName: "Desk"})
+	light := lightFromAddress(t, addr)
+	light.Name = `Elgato\ Key\ Light\ 111A`
+
+	entry, err := newInventoryEntry(context.Background(), light, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.ShortID != "111A" || entry.SerialNumber != "BW33J1A02740" || entry.DisplayName != "Desk" || entry.Address() != addr {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	known := &inventoryEntry{SerialNumber: "BW33J1A02799", DisplayName: "Known"}
+	entry, err = newInventoryEntry(context.Background(), light, known)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.SerialNumber != "BW33J1A02799" || entry.DisplayName != "Known" {
+		t.Errorf("expected the known accessory info to be reused, got %+v", entry)
+	}
+}
+
+func TestLightShortID(t *testing.T) {
+	cases := map[string]string{
+		`Elgato\ Key\ Light\ 111A`:      "111A",
+		`Elgato\ Key\ Light\ Air\ 9C2B`: "9C2B",
+		"Elgato Light Strip 4F1E":       "4F1E",
+		"":                              "",
+	}
+
+	for name, expected := range cases {
+		if id := lightShortID(name); id != expected {
+			t.Errorf("%q: expected %q, got %q", name, expected, id)
+		}
+	}
+}
@@ -31,6 +31,9 @@ type Meta struct {
 
 	// Whether to not-colorize output
 	noColor bool
+
+	// The profile of the config file to use
+	profile string
 }
 
 // FlagSet returns a FlagSet with the common flags that every
@@ -44,7 +47,7 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	// client connectivity options.
 	if fs&FlagSetClient != 0 {
 		f.BoolVar(&m.noColor, "no-color", false, "")
-
+		f.StringVar(&m.profile, "profile", "", "")
 	}
 
 	f.SetOutput(&uiErrorWriter{ui: m.UI})
@@ -52,6 +55,26 @@ func (m *Meta) FlagSet(n string, fs FlagSetFlags) *flag.FlagSet {
 	return f
 }
 
+// parseFlags parses args and then sets every flag that was not provided on the
+// command line to its value from the config file or environment, if any.
+// Errors are reported to the UI.
+func (m *Meta) parseFlags(f *flag.FlagSet, args []string) error {
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadEffectiveConfig(f.Name(), m.profile)
+	if err == nil {
+		err = cfg.Apply(f)
+	}
+	if err != nil {
+		m.UI.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
 // AutocompleteFlags returns a set of flag completions for the given flag set.
 func (m *Meta) AutocompleteFlags(fs FlagSetFlags) complete.Flags {
 	if fs&FlagSetClient == 0 {
@@ -60,6 +83,7 @@ func (m *Meta) AutocompleteFlags(fs FlagSetFlags) complete.Flags {
 
 	return complete.Flags{
 		"-no-color": complete.PredictNothing,
+		"-profile":  complete.PredictAnything,
 	}
 }
 
@@ -76,6 +100,10 @@ func generalOptionsUsage() string {
 	helpText := `
   -no-color
     Disables colored command output.
+
+  -profile <name>
+    Use the named profile of the config file. Defaults to the value of
+    KEYLIGHTCTL_PROFILE, or the profile set in the config file.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -0,0 +1,499 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	// mqttOnline and mqttOffline are the payloads of availability topics.
+	mqttOnline  = "online"
+	mqttOffline = "offline"
+
+	// mqttPublishTimeout is the maximum time we wait for the broker to
+	// acknowledge a publish or subscribe.
+	mqttPublishTimeout = 5 * time.Second
+
+	// mqttCommandQueueSize is the number of received commands that may wait
+	// to be applied before further commands are dropped.
+	mqttCommandQueueSize = 16
+)
+
+// mqttLightState is the state payload of a light, in the format used by the
+// Home Assistant MQTT light JSON schema.
+type mqttLightState struct {
+	State      string `json:"state"`
+	Brightness int    `json:"brightness"`
+	ColorTemp  int    `json:"color_temp"`
+	ColorMode  string `json:"color_mode"`
+	Kelvin     int    `json:"kelvin"`
+}
+
+// mqttLightCommand is the payload of a set command. Fields that are omitted
+// are left unchanged.
+type mqttLightCommand struct {
+	State      string `json:"state"`
+	Brightness *int   `json:"brightness"`
+	ColorTemp  *int   `json:"color_temp"`
+	Kelvin     *int   `json:"kelvin"`
+}
+
+// mqttAvailability is an entry in the availability list of a Home Assistant
+// discovery config.
+type mqttAvailability struct {
+	Topic string `json:"topic"`
+}
+
+// mqttDevice is the device block of a Home Assistant discovery config.
+type mqttDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// mqttDiscoveryConfig is the Home Assistant MQTT discovery config of a
+// dimmable color temperature light.
+type mqttDiscoveryConfig struct {
+	Name                string             `json:"name"`
+	UniqueID            string             `json:"unique_id"`
+	Schema              string             `json:"schema"`
+	StateTopic          string             `json:"state_topic"`
+	CommandTopic        string             `json:"command_topic"`
+	Availability        []mqttAvailability `json:"availability"`
+	AvailabilityMode    string             `json:"availability_mode"`
+	Brightness          bool               `json:"brightness"`
+	BrightnessScale     int                `json:"brightness_scale"`
+	SupportedColorModes []string           `json:"supported_color_modes"`
+	MinMireds           int                `json:"min_mireds"`
+	MaxMireds           int                `json:"max_mireds"`
+	Device              mqttDevice         `json:"device"`
+}
+
+// mqttEntity is a light that is published as a single light to the broker:
+// either a whole accessory, or one of the lights of an accessory with several.
+type mqttEntity struct {
+	Key string
+	// Channel is the index of the light within the accessory, or -1 for the
+	// whole accessory.
+	Channel int
+}
+
+// ID returns the topic ID of the entity.
+func (e mqttEntity) ID() string {
+	id := mqttLightID(e.Key)
+	if e.Channel < 0 {
+		return id
+	}
+	return fmt.Sprintf("%s_%d", id, e.Channel)
+}
+
+// mqttBridge publishes the state of the lights tracked by an agent to an MQTT
+// broker, and applies commands that are received from it. Lights use the
+// following topics, where <id> is the short ID of the light:
+//
+//	<prefix>/<id>/state          retained state of the light
+//	<prefix>/<id>/set            commands to change the light
+//	<prefix>/<id>/availability   whether the light is reachable
+//	<prefix>/availability        whether the bridge is connected
+//
+// Accessories with several lights publish each of their lights separately,
+// with the index of the light appended to the ID, e.g: 111A_0 and 111A_1.
+type mqttBridge struct {
+	UI     cli.Ui
+	Agent  *agent
+	Client mqtt.Client
+
+	// Prefix is the prefix of all light topics, and DiscoveryPrefix is the
+	// prefix that Home Assistant discovery configs are published under. An
+	// empty DiscoveryPrefix disables Home Assistant discovery.
+	Prefix          string
+	DiscoveryPrefix string
+
+	mu sync.Mutex
+	// ids maps the topic ID of every light to its entity.
+	ids map[string]mqttEntity
+	// announced holds the topic IDs that discovery configs were published
+	// for, keyed by the agent key of the accessory.
+	announced map[string][]string
+	// info caches the accessory info used for discovery configs.
+	info map[string]*keylight.AccessoryInfo
+	// commands holds received commands until Run applies them. The client
+	// calls handleCommand from its router, which must not block.
+	commands chan mqtt.Message
+}
+
+// AvailabilityTopic returns the topic that the availability of the bridge is
+// published to, which is also used as the last will of the client.
+func (b *mqttBridge) AvailabilityTopic() string {
+	return b.Prefix + "/availability"
+}
+
+// Start subscribes to the command topics and publishes the current state of
+// every known light. It is called each time the client (re)connects.
+func (b *mqttBridge) Start() {
+	topic := b.Prefix + "/+/set"
+	token := b.Client.Subscribe(topic, 1, b.handleCommand)
+	if err := waitMQTT(token); err != nil {
+		b.UI.Warn(fmt.Sprintf("Failed to subscribe to %s, err: %v", topic, err))
+	}
+
+	b.publish(b.AvailabilityTopic(), mqttOnline)
+
+	for _, key := range b.Agent.Keys() {
+		b.announce(key)
+	}
+}
+
+// Stop marks the bridge as offline.
+func (b *mqttBridge) Stop() {
+	b.publish(b.AvailabilityTopic(), mqttOffline)
+}
+
+// HandleEvent publishes the result of a light event.
+func (b *mqttBridge) HandleEvent(e *lightEvent) {
+	key := lightLabel(&keylight.KeyLight{Name: e.Name, DNSAddr: e.Address, Port: e.Port})
+
+	switch e.Type {
+	case lightEventAppeared:
+		b.announce(key)
+	case lightEventDisappeared:
+		_, record := b.Agent.client(key)
+		for _, entity := range b.entities(key, record) {
+			b.publish(b.lightTopic(entity, "availability"), mqttOffline)
+		}
+	default:
+		b.publishState(key)
+	}
+}
+
+// entities returns the entities that an accessory is published as. The
+// number of lights is only known once the accessory has been reached, until
+// then it is published as a whole.
+func (b *mqttBridge) entities(key string, record agentLightRecord) []mqttEntity {
+	if len(record.Lights) <= 1 {
+		return []mqttEntity{{Key: key, Channel: -1}}
+	}
+
+	entities := make([]mqttEntity, 0, len(record.Lights))
+	for idx := range record.Lights {
+		entities = append(entities, mqttEntity{Key: key, Channel: idx})
+	}
+	return entities
+}
+
+// announce publishes the discovery configs, availability and state of every
+// light of an accessory.
+func (b *mqttBridge) announce(key string) {
+	_, record := b.Agent.client(key)
+	entities := b.entities(key, record)
+
+	b.publishDiscovery(key, entities)
+
+	availability := mqttOffline
+	if record.Reachable {
+		availability = mqttOnline
+	}
+	for _, entity := range entities {
+		b.publish(b.lightTopic(entity, "availability"), availability)
+	}
+
+	if record.Reachable {
+		b.publishState(key)
+	}
+}
+
+func (b *mqttBridge) publishState(key string) {
+	_, record := b.Agent.client(key)
+	if len(record.Lights) == 0 {
+		return
+	}
+
+	for _, entity := range b.entities(key, record) {
+		l := record.Lights[0]
+		if entity.Channel >= 0 {
+			l = record.Lights[entity.Channel]
+		}
+
+		state := mqttLightState{
+			State:      strings.ToUpper(l.Power),
+			Brightness: l.Brightness,
+			ColorTemp:  l.Temperature,
+			ColorMode:  "color_temp",
+			Kelvin:     l.Kelvin,
+		}
+
+		payload, err := json.Marshal(state)
+		if err != nil {
+			continue
+		}
+		b.publish(b.lightTopic(entity, "state"), string(payload))
+	}
+}
+
+// publishDiscovery publishes a discovery config for every entity of an
+// accessory, and removes the configs of entities that were announced before
+// the number of lights of the accessory was known.
+func (b *mqttBridge) publishDiscovery(key string, entities []mqttEntity) {
+	if b.DiscoveryPrefix == "" {
+		return
+	}
+
+	light, record := b.Agent.client(key)
+	info := b.accessoryInfo(key, light)
+
+	name := record.DisplayName
+	if name == "" {
+		name = key
+	}
+
+	device := mqttDevice{
+		Identifiers:  []string{"keylightctl_" + mqttLightID(key)},
+		Name:         name,
+		Manufacturer: "Elgato",
+	}
+	if info != nil {
+		if info.SerialNumber != "" {
+			device.Identifiers = []string{info.SerialNumber}
+		}
+		device.Model = info.ProductName
+		device.SWVersion = info.FirmwareVersion
+	}
+
+	var ids []string
+	current := make(map[string]bool)
+	for _, entity := range entities {
+		id := entity.ID()
+		ids = append(ids, id)
+		current[id] = true
+
+		config := mqttDiscoveryConfig{
+			Name:         name,
+			UniqueID:     "keylightctl_" + id,
+			Schema:       "json",
+			StateTopic:   b.lightTopic(entity, "state"),
+			CommandTopic: b.lightTopic(entity, "set"),
+			Availability: []mqttAvailability{
+				{Topic: b.AvailabilityTopic()},
+				{Topic: b.lightTopic(entity, "availability")},
+			},
+			AvailabilityMode:    "all",
+			Brightness:          true,
+			BrightnessScale:     maxBrightness,
+			SupportedColorModes: []string{"color_temp"},
+			MinMireds:           minTemperature,
+			MaxMireds:           maxTemperature,
+			Device:              device,
+		}
+		if entity.Channel >= 0 {
+			config.Name = fmt.Sprintf("%s %s%d", name, lightChannelSeparator, entity.Channel)
+		}
+
+		payload, err := json.Marshal(config)
+		if err != nil {
+			continue
+		}
+		b.publish(b.discoveryTopic(id), string(payload))
+	}
+
+	b.mu.Lock()
+	if b.announced == nil {
+		b.announced = make(map[string][]string)
+	}
+	previous := b.announced[key]
+	b.announced[key] = ids
+	b.mu.Unlock()
+
+	// An empty retained config removes the entity from Home Assistant.
+	for _, id := range previous {
+		if !current[id] {
+			b.publish(b.discoveryTopic(id), "")
+		}
+	}
+}
+
+// discoveryTopic returns the topic of the discovery config of an entity.
+func (b *mqttBridge) discoveryTopic(id string) string {
+	return fmt.Sprintf("%s/light/keylightctl_%s/config", b.DiscoveryPrefix, id)
+}
+
+// accessoryInfo returns the cached accessory info of a light, fetching it the
+// first time it is needed. It returns nil if the light can not be reached.
+func (b *mqttBridge) accessoryInfo(key string, light *keylight.KeyLight) *keylight.AccessoryInfo {
+	b.mu.Lock()
+	info, ok := b.info[key]
+	b.mu.Unlock()
+	if ok {
+		return info
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), agentLightTimeout)
+	defer cancelFn()
+	info, err := light.FetchAccessoryInfo(ctx)
+	if err != nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.info == nil {
+		b.info = make(map[string]*keylight.AccessoryInfo)
+	}
+	b.info[key] = info
+	b.mu.Unlock()
+	return info
+}
+
+// Run applies the commands that are received from the broker, in the order
+// they arrived, until ctx is cancelled.
+func (b *mqttBridge) Run(ctx context.Context) {
+	commands := b.queue()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-commands:
+			b.applyCommand(ctx, msg)
+		}
+	}
+}
+
+// handleCommand queues a command for Run. It is called by the client's router,
+// so it must return without waiting for the light to be updated.
+func (b *mqttBridge) handleCommand(_ mqtt.Client, msg mqtt.Message) {
+	select {
+	case b.queue() <- msg:
+	default:
+		b.UI.Warn(fmt.Sprintf("Dropping command received on %s, too many commands are pending", msg.Topic()))
+	}
+}
+
+func (b *mqttBridge) queue() chan mqtt.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.commands == nil {
+		b.commands = make(chan mqtt.Message, mqttCommandQueueSize)
+	}
+	return b.commands
+}
+
+func (b *mqttBridge) applyCommand(ctx context.Context, msg mqtt.Message) {
+	id := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), b.Prefix+"/"), "/set")
+
+	b.mu.Lock()
+	entity, ok := b.ids[id]
+	b.mu.Unlock()
+	if !ok {
+		b.UI.Warn(fmt.Sprintf("Ignoring command for unknown light '%s'", id))
+		return
+	}
+
+	mutate, err := decodeMQTTCommand(msg.Payload())
+	if err != nil {
+		b.UI.Warn(fmt.Sprintf("Ignoring invalid command for %s, err: %v", entity.Key, err))
+		return
+	}
+
+	var channels []int
+	if entity.Channel >= 0 {
+		channels = []int{entity.Channel}
+	}
+	if err := b.Agent.ModifyChannels(ctx, entity.Key, channels, mutate); err != nil {
+		b.UI.Warn(fmt.Sprintf("Failed to update light (%s), err: %v", entity.Key, err))
+	}
+}
+
+// decodeMQTTCommand parses a set command into a mutation. Plain ON, OFF and
+// TOGGLE payloads are accepted in addition to JSON commands.
+func decodeMQTTCommand(payload []byte) (func(l *keylight.KeyLightLight), error) {
+	var cmd mqttLightCommand
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(payload, &cmd); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd.State = trimmed
+	}
+
+	state := strings.ToUpper(cmd.State)
+	if state != "" && state != "ON" && state != "OFF" && state != "TOGGLE" {
+		return nil, fmt.Errorf("state must be ON, OFF or TOGGLE, got '%s'", cmd.State)
+	}
+
+	if cmd.ColorTemp != nil && cmd.Kelvin != nil {
+		return nil, errors.New("cannot specify color_temp and kelvin together")
+	}
+
+	return func(l *keylight.KeyLightLight) {
+		switch state {
+		case "ON":
+			l.On = 1
+		case "OFF":
+			l.On = 0
+		case "TOGGLE":
+			l.On = 1 - l.On
+		}
+		if cmd.Brightness != nil {
+			l.Brightness = clamp(*cmd.Brightness, minBrightness, maxBrightness)
+		}
+		if cmd.ColorTemp != nil {
+			l.Temperature = clamp(*cmd.ColorTemp, minTemperature, maxTemperature)
+		}
+		if cmd.Kelvin != nil {
+			l.Temperature = kelvinToMireds(*cmd.Kelvin)
+		}
+	}, nil
+}
+
+// register returns the topic ID of an entity, recording it so that commands
+// can be routed back to the light.
+func (b *mqttBridge) register(entity mqttEntity) string {
+	id := entity.ID()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ids == nil {
+		b.ids = make(map[string]mqttEntity)
+	}
+	b.ids[id] = entity
+	return id
+}
+
+func (b *mqttBridge) lightTopic(entity mqttEntity, suffix string) string {
+	return fmt.Sprintf("%s/%s/%s", b.Prefix, b.register(entity), suffix)
+}
+
+func (b *mqttBridge) publish(topic, payload string) {
+	token := b.Client.Publish(topic, 1, true, payload)
+	if err := waitMQTT(token); err != nil {
+		b.UI.Warn(fmt.Sprintf("Failed to publish to %s, err: %v", topic, err))
+	}
+}
+
+// mqttLightID returns the topic ID of a light: its short ID, or its address
+// with separators replaced for lights that were provided by address.
+func mqttLightID(key string) string {
+	id := lightShortID(key)
+	if isDirectLightAddress(key) {
+		id = key
+	}
+	return strings.NewReplacer(".", "_", ":", "_", "/", "_", "+", "_", "#", "_", " ", "_").Replace(id)
+}
+
+// waitMQTT waits for token to complete and returns its error.
+func waitMQTT(token mqtt.Token) error {
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return errors.New("timed out waiting for broker")
+	}
+	return token.Error()
+}
@@ -0,0 +1,226 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type MQTTCommand struct {
+	Meta
+}
+
+func (c *MQTTCommand) Help() string {
+	helpText := `
+Usage: keylightctl mqtt [options]
+
+ Bridge keylights to an MQTT broker. The state of each light is published to
+ <prefix>/<id>/state, where <id> is the short ID of the light, and commands
+ published to <prefix>/<id>/set are applied to the light. Commands are JSON
+ objects such as {"state": "ON", "brightness": 50, "color_temp": 250}, or one
+ of the plain payloads ON, OFF and TOGGLE. Accessories with several lights
+ publish each light separately, with its index appended to the ID, e.g:
+ <prefix>/111A_1/state.
+
+ Home Assistant MQTT discovery configs are published so that lights appear as
+ dimmable color temperature lights automatically.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+MQTT Specific Options:
+
+  -broker <url>
+    The URL of the MQTT broker, e.g: tcp://localhost:1883, ssl://host:8883 or
+    ws://host:9001 (default: tcp://localhost:1883)
+
+  -username <username>
+    The username to authenticate with the broker.
+
+  -password <password>
+    The password to authenticate with the broker. Defaults to the value of
+    the MQTT_PASSWORD environment variable.
+
+  -client-id <id>
+    The MQTT client ID (default: keylightctl-<hostname>)
+
+  -topic-prefix <prefix>
+    The prefix of all light topics (default: keylightctl)
+
+  -discovery-prefix <prefix>
+    The Home Assistant discovery prefix. Set to an empty string to disable
+    Home Assistant discovery. (default: homeassistant)
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories before bridging starts
+    (default: 5s)
+
+  -all
+    Bridge all keylights. Lights that are discovered while bridging are
+    added as they appear. This is the default if no lights or groups are
+    provided.
+
+  ` + lightOptionUsage("Bridge") + `
+
+  -group <group>
+    Bridge all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -interval <duration>
+    Sets the time between polls of the light state (default: 5s)
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs while bridging all lights
+    (default: 1m)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *MQTTCommand) Synopsis() string {
+	return "Bridge keylights to MQTT and Home Assistant"
+}
+
+func (c *MQTTCommand) Name() string { return "mqtt" }
+
+func (c *MQTTCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout, interval, discoveryInterval time.Duration
+	var selection lightSelection
+	var broker, username, password, clientID, topicPrefix, discoveryPrefix string
+
+	hostname, _ := os.Hostname()
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&broker, "broker", "tcp://localhost:1883", "")
+	flags.StringVar(&username, "username", "", "")
+	flags.StringVar(&password, "password", os.Getenv("MQTT_PASSWORD"), "")
+	flags.StringVar(&clientID, "client-id", "keylightctl-"+hostname, "")
+	flags.StringVar(&topicPrefix, "topic-prefix", "keylightctl", "")
+	flags.StringVar(&discoveryPrefix, "discovery-prefix", "homeassistant", "")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.DurationVar(&interval, "interval", 5*time.Second, "")
+	flags.DurationVar(&discoveryInterval, "discovery-interval", time.Minute, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if len(selection.Lights) == 0 && len(selection.Groups) == 0 {
+		selection.AllLights = true
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval <= 0 || discoveryInterval <= 0 {
+		c.UI.Error("--interval and --discovery-interval must be positive")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	topicPrefix = strings.TrimSuffix(topicPrefix, "/")
+	if topicPrefix == "" || strings.ContainsAny(topicPrefix, "+#") {
+		c.UI.Error("--topic-prefix must not be empty or contain wildcards")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := &agent{
+		UI:               c.UI,
+		DiscoveryTimeout: timeout,
+		PollInterval:     interval,
+	}
+	if selection.AllLights {
+		a.DiscoveryInterval = discoveryInterval
+	}
+
+	bridge := &mqttBridge{
+		UI:              c.UI,
+		Agent:           a,
+		Prefix:          topicPrefix,
+		DiscoveryPrefix: strings.TrimSuffix(discoveryPrefix, "/"),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetWill(bridge.AvailabilityTopic(), mqttOffline, 1, true).
+		SetOnConnectHandler(func(mqtt.Client) {
+			c.UI.Info(fmt.Sprintf("Connected to %s", broker))
+			bridge.Start()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			c.UI.Warn(fmt.Sprintf("Lost connection to %s, reconnecting, err: %v", broker, err))
+		})
+
+	go bridge.Run(ctx)
+
+	bridge.Client = mqtt.NewClient(opts)
+	if err := waitMQTT(bridge.Client.Connect()); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to connect to %s, err: %v", broker, err))
+		return 1
+	}
+	defer bridge.Client.Disconnect(250)
+	defer bridge.Stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 && !selection.AllLights {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	a.OnEvent = bridge.HandleEvent
+
+	var wg sync.WaitGroup
+	for _, light := range found {
+		wg.Add(1)
+		go func(light *keylight.KeyLight) {
+			defer wg.Done()
+			a.observe(ctx, light)
+		}(light)
+	}
+	wg.Wait()
+
+	a.Run(ctx)
+	return 0
+}
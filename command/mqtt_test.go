@@ -0,0 +1,675 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// fakeMQTTToken is a token that has already completed.
+type fakeMQTTToken struct {
+	err error
+}
+
+func (t *fakeMQTTToken) Wait() bool                     { return true }
+func (t *fakeMQTTToken) WaitTimeout(time.Duration) bool { return true }
+func (t *fakeMQTTToken) Error() error                   { return t.err }
+
+func (t *fakeMQTTToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// fakeMQTTMessage is a message that was received on a topic.
+type fakeMQTTMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMQTTMessage) Duplicate() bool   { return false }
+func (m *fakeMQTTMessage) Qos() byte         { return 1 }
+func (m *fakeMQTTMessage) Retained() bool    { return false }
+func (m *fakeMQTTMessage) Topic() string     { return m.topic }
+func (m *fakeMQTTMessage) MessageID() uint16 { return 0 }
+func (m *fakeMQTTMessage) Payload() []byte   { return m.payload }
+func (m *fakeMQTTMessage) Ack()              {}
+
+// fakeMQTTClient records the messages that are published and routes messages
+// that a test delivers to the matching subscription.
+type fakeMQTTClient struct {
+	mu        sync.Mutex
+	retained  map[string]string
+	published []string
+	subs      map[string]mqtt.MessageHandler
+}
+
+func newFakeMQTTClient() *fakeMQTTClient {
+	return &fakeMQTTClient{
+		retained: make(map[string]string),
+		subs:     make(map[string]mqtt.MessageHandler),
+	}
+}
+
+func (c *fakeMQTTClient) IsConnected() bool      { return true }
+func (c *fakeMQTTClient) IsConnectionOpen() bool { return true }
+func (c *fakeMQTTClient) Connect() mqtt.Token    { return &fakeMQTTToken{} }
+func (c *fakeMQTTClient) Disconnect(uint)        {}
+
+func (c *fakeMQTTClient) Publish(topic string, _ byte, retained bool, payload interface{}) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.published = append(c.published, topic)
+	if retained {
+		c.retained[topic] = payload.(string)
+	}
+	return &fakeMQTTToken{}
+}
+
+func (c *fakeMQTTClient) Subscribe(topic string, _ byte, callback mqtt.MessageHandler) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.subs[topic] = callback
+	return &fakeMQTTToken{}
+}
+
+func (c *fakeMQTTClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	for topic, qos := range filters {
+		c.Subscribe(topic, qos, callback)
+	}
+	return &fakeMQTTToken{}
+}
+
+func (c *fakeMQTTClient) Unsubscribe(topics ...string) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(c.subs, topic)
+	}
+	return &fakeMQTTToken{}
+}
+
+func (c *fakeMQTTClient) AddRoute(topic string, callback mqtt.MessageHandler) {
+	c.Subscribe(topic, 0, callback)
+}
+
+func (c *fakeMQTTClient) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.ClientOptionsReader{}
+}
+
+// Retained returns the last retained payload that was published to topic.
+func (c *fakeMQTTClient) Retained(topic string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	payload, ok := c.retained[topic]
+	return payload, ok
+}
+
+// Deliver passes a message to the handler of the subscription filter.
+func (c *fakeMQTTClient) Deliver(t *testing.T, filter, topic, payload string) {
+	t.Helper()
+
+	c.mu.Lock()
+	handler, ok := c.subs[filter]
+	c.mu.Unlock()
+	if !ok {
+		t.Fatalf("no subscription for %s", filter)
+	}
+	handler(c, &fakeMQTTMessage{topic: topic, payload: []byte(payload)})
+}
+
+// newTestBridge returns a bridge for a simulated light that has been
+// observed by the agent and published to the fake client.
+func newTestBridge(t *testing.T) (*mqttBridge, *fakeMQTTClient, *simulator.Simulator) {
+	t.Helper()
+	setupTestConfig(t)
+
+	sim, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740", DisplayName: "Desk"})
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	ui := cli.NewMockUi()
+	a := &agent{UI: ui}
+	a.observe(context.Background(), &keylight.KeyLight{
+		Name:    `Elgato\ Key\ Light\ 111A`,
+		DNSAddr: host,
+		Port:    portNum,
+	})
+
+	client := newFakeMQTTClient()
+	bridge := &mqttBridge{
+		UI:              ui,
+		Agent:           a,
+		Client:          client,
+		Prefix:          "keylightctl",
+		DiscoveryPrefix: "homeassistant",
+	}
+	bridge.Start()
+
+	return bridge, client, sim
+}
+
+func TestMQTTBridge_Topics(t *testing.T) {
+	_, client, _ := newTestBridge(t)
+
+	if _, ok := client.subs["keylightctl/+/set"]; !ok {
+		t.Errorf("expected a subscription to keylightctl/+/set, got %v", client.subs)
+	}
+
+	expected := map[string]string{
+		"keylightctl/availability":      mqttOnline,
+		"keylightctl/111A/availability": mqttOnline,
+	}
+	for topic, want := range expected {
+		if got, ok := client.Retained(topic); !ok || got != want {
+			t.Errorf("expected %s to be %q, got %q", topic, want, got)
+		}
+	}
+
+	payload, ok := client.Retained("keylightctl/111A/state")
+	if !ok {
+		t.Fatalf("expected the state to be published")
+	}
+
+	var state mqttLightState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		t.Fatalf("failed to decode state %q: %v", payload, err)
+	}
+	want := mqttLightState{State: "OFF", Brightness: 20, ColorTemp: 213, ColorMode: "color_temp", Kelvin: miredsToKelvin(213)}
+	if state != want {
+		t.Errorf("expected state %+v, got %+v", want, state)
+	}
+}
+
+func TestMQTTBridge_Discovery(t *testing.T) {
+	_, client, _ := newTestBridge(t)
+
+	topic := "homeassistant/light/keylightctl_111A/config"
+	payload, ok := client.Retained(topic)
+	if !ok {
+		t.Fatalf("expected a discovery config on %s", topic)
+	}
+
+	var config mqttDiscoveryConfig
+	if err := json.Unmarshal([]byte(payload), &config); err != nil {
+		t.Fatalf("failed to decode discovery config %q: %v", payload, err)
+	}
+
+	if config.Name != "Desk" || config.UniqueID != "keylightctl_111A" || config.Schema != "json" {
+		t.Errorf("unexpected discovery config %+v", config)
+	}
+	if config.StateTopic != "keylightctl/111A/state" || config.CommandTopic != "keylightctl/111A/set" {
+		t.Errorf("unexpected topics, state: %s, command: %s", config.StateTopic, config.CommandTopic)
+	}
+	if len(config.Availability) != 2 || config.Availability[0].Topic != "keylightctl/availability" || config.Availability[1].Topic != "keylightctl/111A/availability" {
+		t.Errorf("unexpected availability %+v", config.Availability)
+	}
+	if config.MinMireds != minTemperature || config.MaxMireds != maxTemperature || config.BrightnessScale != maxBrightness {
+		t.Errorf("unexpected ranges %+v", config)
+	}
+	if len(config.Device.Identifiers) != 1 || config.Device.Identifiers[0] != "BW33J1A02740" || config.Device.Model != "Elgato Key Light" {
+		t.Errorf("unexpected device %+v", config.Device)
+	}
+}
+
+func TestMQTTBridge_Command(t *testing.T) {
+	bridge, client, sim := newTestBridge(t)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	go bridge.Run(ctx)
+
+	// The router must not be blocked while the light is updated.
+	sim.SetLatency(200 * time.Millisecond)
+	start := time.Now()
+	client.Deliver(t, "keylightctl/+/set", "keylightctl/111A/set", `{"state":"ON","brightness":55}`)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected handling a command not to block, took %s", elapsed)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l := sim.Options().Lights[0]
+		if l.On == 1 && l.Brightness == 55 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the command to be applied, got %+v", l)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDecodeMQTTCommand(t *testing.T) {
+	cases := []struct {
+		name     string
+		payload  string
+		initial  keylight.KeyLightLight
+		expected keylight.KeyLightLight
+		err      bool
+	}{
+		{
+			name:     "plain on",
+			payload:  "ON",
+			initial:  keylight.KeyLightLight{Brightness: 20, Temperature: 213},
+			expected: keylight.KeyLightLight{On: 1, Brightness: 20, Temperature: 213},
+		},
+		{
+			name:     "plain toggle",
+			payload:  " toggle\n",
+			initial:  keylight.KeyLightLight{On: 1, Brightness: 20, Temperature: 213},
+			expected: keylight.KeyLightLight{On: 0, Brightness: 20, Temperature: 213},
+		},
+		{
+			name:     "json off",
+			payload:  `{"state":"OFF"}`,
+			initial:  keylight.KeyLightLight{On: 1, Brightness: 20, Temperature: 213},
+			expected: keylight.KeyLightLight{On: 0, Brightness: 20, Temperature: 213},
+		},
+		{
+			name:     "json brightness and color_temp are clamped",
+			payload:  `{"brightness":500,"color_temp":10}`,
+			initial:  keylight.KeyLightLight{Brightness: 20, Temperature: 213},
+			expected: keylight.KeyLightLight{Brightness: maxBrightness, Temperature: minTemperature},
+		},
+		{
+			name:     "json kelvin",
+			payload:  `{"state":"ON","kelvin":5000}`,
+			initial:  keylight.KeyLightLight{Brightness: 20, Temperature: 213},
+			expected: keylight.KeyLightLight{On: 1, Brightness: 20, Temperature: kelvinToMireds(5000)},
+		},
+		{
+			name:    "invalid state",
+			payload: "DIM",
+			err:     true,
+		},
+		{
+			name:    "invalid json",
+			payload: `{"state":`,
+			err:     true,
+		},
+		{
+			name:    "color_temp and kelvin",
+			payload: `{"color_temp":200,"kelvin":5000}`,
+			err:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mutate, err := decodeMQTTCommand([]byte(tc.payload))
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			l := tc.initial
+			mutate(&l)
+			if l != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, l)
+			}
+		})
+	}
+}
+
+// testMQTTBroker is a minimal in-process MQTT 3.1.1 broker. It supports QoS 0
+// and 1 publishes, retained messages, wildcard subscriptions and last wills,
+// and delivers every message to subscribers at QoS 0.
+type testMQTTBroker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	retained map[string][]byte
+	sessions map[*testMQTTSession]bool
+}
+
+// testMQTTSession is a client connected to a testMQTTBroker.
+type testMQTTSession struct {
+	conn net.Conn
+
+	mu      sync.Mutex
+	filters []string
+}
+
+func (s *testMQTTSession) write(cp packets.ControlPacket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp.Write(s.conn)
+}
+
+func (s *testMQTTSession) subscribed(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, filter := range s.filters {
+		if matchMQTTTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestMQTTBroker(t *testing.T) *testMQTTBroker {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	b := &testMQTTBroker{
+		listener: listener,
+		retained: make(map[string][]byte),
+		sessions: make(map[*testMQTTSession]bool),
+	}
+	go b.serve()
+
+	t.Cleanup(func() {
+		listener.Close()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for s := range b.sessions {
+			s.conn.Close()
+		}
+	})
+	return b
+}
+
+// URL returns the address that clients connect to.
+func (b *testMQTTBroker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testMQTTBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *testMQTTBroker) handle(conn net.Conn) {
+	s := &testMQTTSession{conn: conn}
+	var will *packets.PublishPacket
+
+	defer func() {
+		conn.Close()
+		b.mu.Lock()
+		delete(b.sessions, s)
+		b.mu.Unlock()
+		if will != nil {
+			b.route(will)
+		}
+	}()
+
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			if p.WillFlag {
+				will = packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				will.TopicName = p.WillTopic
+				will.Payload = p.WillMessage
+				will.Retain = p.WillRetain
+			}
+			b.mu.Lock()
+			b.sessions[s] = true
+			b.mu.Unlock()
+			s.write(packets.NewControlPacket(packets.Connack))
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				s.write(ack)
+			}
+			b.route(p)
+		case *packets.SubscribePacket:
+			s.mu.Lock()
+			s.filters = append(s.filters, p.Topics...)
+			s.mu.Unlock()
+
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = make([]byte, len(p.Topics))
+			s.write(ack)
+
+			for _, msg := range b.retainedFor(p.Topics) {
+				s.write(msg)
+			}
+		case *packets.PingreqPacket:
+			s.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			will = nil
+			return
+		}
+	}
+}
+
+// route stores retained messages and delivers a message to every session
+// that is subscribed to its topic.
+func (b *testMQTTBroker) route(p *packets.PublishPacket) {
+	b.mu.Lock()
+	if p.Retain {
+		if len(p.Payload) == 0 {
+			delete(b.retained, p.TopicName)
+		} else {
+			b.retained[p.TopicName] = p.Payload
+		}
+	}
+	var sessions []*testMQTTSession
+	for s := range b.sessions {
+		sessions = append(sessions, s)
+	}
+	b.mu.Unlock()
+
+	for _, s := range sessions {
+		if s.subscribed(p.TopicName) {
+			s.write(newTestMQTTPublish(p.TopicName, p.Payload, false))
+		}
+	}
+}
+
+// retainedFor returns the retained messages that match any of filters.
+func (b *testMQTTBroker) retainedFor(filters []string) []*packets.PublishPacket {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result []*packets.PublishPacket
+	for topic, payload := range b.retained {
+		for _, filter := range filters {
+			if matchMQTTTopic(filter, topic) {
+				result = append(result, newTestMQTTPublish(topic, payload, true))
+				break
+			}
+		}
+	}
+	return result
+}
+
+func newTestMQTTPublish(topic string, payload []byte, retained bool) *packets.PublishPacket {
+	p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	p.TopicName = topic
+	p.Payload = payload
+	p.Retain = retained
+	return p
+}
+
+// matchMQTTTopic returns whether topic matches a subscription filter, which
+// may contain + and # wildcards.
+func matchMQTTTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for idx, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if idx >= len(topicLevels) || (level != "+" && level != topicLevels[idx]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+// testMQTTObserver is a client that records the last message on every topic.
+type testMQTTObserver struct {
+	mqtt.Client
+
+	mu       sync.Mutex
+	messages map[string]string
+}
+
+func newTestMQTTObserver(t *testing.T, broker *testMQTTBroker) *testMQTTObserver {
+	t.Helper()
+
+	o := &testMQTTObserver{messages: make(map[string]string)}
+	o.Client = mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker.URL()).SetClientID("observer"))
+	if err := waitMQTT(o.Connect()); err != nil {
+		t.Fatalf("failed to connect observer: %v", err)
+	}
+	t.Cleanup(func() { o.Disconnect(0) })
+
+	err := waitMQTT(o.Subscribe("#", 0, func(_ mqtt.Client, msg mqtt.Message) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.messages[msg.Topic()] = string(msg.Payload())
+	}))
+	if err != nil {
+		t.Fatalf("failed to subscribe observer: %v", err)
+	}
+	return o
+}
+
+// Await waits until the last message on topic satisfies fn, and returns it.
+func (o *testMQTTObserver) Await(t *testing.T, topic string, fn func(payload string) bool) string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		o.mu.Lock()
+		payload, ok := o.messages[topic]
+		o.mu.Unlock()
+		if ok && fn(payload) {
+			return payload
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s, last payload: %q", topic, payload)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Received returns whether a message was received on topic.
+func (o *testMQTTObserver) Received(topic string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.messages[topic]
+	return ok
+}
+
+func TestMQTTBridge_Broker(t *testing.T) {
+	setupTestConfig(t)
+	broker := newTestMQTTBroker(t)
+
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2, SerialNumber: "BW33J1A02740", DisplayName: "Desk"})
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid address %s: %v", addr, err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	ui := cli.NewMockUi()
+	a := &agent{UI: ui}
+	bridge := &mqttBridge{
+		UI:              ui,
+		Agent:           a,
+		Prefix:          "keylightctl",
+		DiscoveryPrefix: "homeassistant",
+	}
+	a.OnEvent = bridge.HandleEvent
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	go bridge.Run(ctx)
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker.URL()).
+		SetClientID("keylightctl").
+		SetWill(bridge.AvailabilityTopic(), mqttOffline, 1, true).
+		SetOnConnectHandler(func(mqtt.Client) { bridge.Start() })
+	bridge.Client = mqtt.NewClient(opts)
+	if err := waitMQTT(bridge.Client.Connect()); err != nil {
+		t.Fatalf("failed to connect bridge: %v", err)
+	}
+	defer bridge.Client.Disconnect(0)
+
+	a.observe(context.Background(), &keylight.KeyLight{
+		Name:    `Elgato\ Key\ Light\ 111A`,
+		DNSAddr: host,
+		Port:    portNum,
+	})
+
+	observer := newTestMQTTObserver(t, broker)
+	isOnline := func(payload string) bool { return payload == mqttOnline }
+	observer.Await(t, "keylightctl/availability", isOnline)
+
+	// Each light of the accessory is published as a separate entity.
+	for _, id := range []string{"111A_0", "111A_1"} {
+		observer.Await(t, "keylightctl/"+id+"/availability", isOnline)
+		observer.Await(t, "keylightctl/"+id+"/state", func(payload string) bool {
+			return strings.Contains(payload, `"state":"OFF"`)
+		})
+
+		payload := observer.Await(t, "homeassistant/light/keylightctl_"+id+"/config", func(string) bool { return true })
+		var config mqttDiscoveryConfig
+		if err := json.Unmarshal([]byte(payload), &config); err != nil {
+			t.Fatalf("failed to decode discovery config %q: %v", payload, err)
+		}
+		if config.CommandTopic != "keylightctl/"+id+"/set" || config.Device.Identifiers[0] != "BW33J1A02740" {
+			t.Errorf("unexpected discovery config %+v", config)
+		}
+	}
+	if observer.Received("keylightctl/111A/state") {
+		t.Errorf("expected the accessory not to be published as a whole")
+	}
+
+	token := observer.Publish("keylightctl/111A_1/set", 1, false, `{"state":"ON","brightness":55}`)
+	if err := waitMQTT(token); err != nil {
+		t.Fatalf("failed to publish command: %v", err)
+	}
+
+	observer.Await(t, "keylightctl/111A_1/state", func(payload string) bool {
+		return strings.Contains(payload, `"state":"ON"`) && strings.Contains(payload, `"brightness":55`)
+	})
+	lights := sim.Options().Lights
+	if lights[0].On != 0 || lights[1].On != 1 || lights[1].Brightness != 55 {
+		t.Errorf("expected only the second light to be changed, got %+v, %+v", lights[0], lights[1])
+	}
+}
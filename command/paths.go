@@ -0,0 +1,130 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDirName is the name of the directory that keylightctl creates inside
+// the users configuration directory to persist state between invocations.
+const configDirName = "keylightctl"
+
+// configDir returns the directory that keylightctl uses to store its
+// persistent state, creating it if it does not already exist.
+func configDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine user config dir, err: %w", err)
+	}
+
+	dir := filepath.Join(base, configDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create config dir (%s), err: %w", dir, err)
+	}
+
+	return dir, nil
+}
+
+// configFilePath returns the path of the named file within the config dir.
+func configFilePath(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// readJSONFile decodes the JSON file at path into target. It returns
+// os.ErrNotExist (wrapped) if the file does not exist.
+func readJSONFile(path string, target interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to parse %s, err: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeJSONFile atomically replaces the file at path with the JSON encoding
+// of value.
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// readYAMLFile decodes the YAML file at path into target. It returns
+// os.ErrNotExist (wrapped) if the file does not exist.
+func readYAMLFile(path string, target interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to parse %s, err: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeYAMLFile atomically replaces the file at path with the YAML encoding
+// of value.
+func writeYAMLFile(path string, value interface{}) error {
+	data, err := marshalYAML(value)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// marshalYAML encodes value as YAML using two space indentation, which is
+// easier to edit by hand than the default.
+func marshalYAML(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place so that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"github.com/endocrimes/keylight-go"
+)
+
+// discoveredLightRecord is the machine readable representation of a light
+// that was found during discovery.
+type discoveredLightRecord struct {
+	Name        string `json:"name" yaml:"name"`
+	ShortID     string `json:"short_id" yaml:"short_id"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	Address     string `json:"address" yaml:"address"`
+	Port        int    `json:"port" yaml:"port"`
+}
+
+func newDiscoveredLightRecord(light *keylight.KeyLight, displayName string) discoveredLightRecord {
+	return discoveredLightRecord{
+		Name:        light.Name,
+		ShortID:     lightShortID(light.Name),
+		DisplayName: displayName,
+		Address:     light.DNSAddr,
+		Port:        light.Port,
+	}
+}
+
+// lightStateRecord is the machine readable representation of the state of a
+// single light within an accessory. Accessories with several lights produce a
+// record per light, distinguished by LightIndex.
+type lightStateRecord struct {
+	Name        string `json:"name" yaml:"name"`
+	ShortID     string `json:"short_id" yaml:"short_id"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	Address     string `json:"address" yaml:"address"`
+	Port        int    `json:"port" yaml:"port"`
+	LightIndex  int    `json:"light_index" yaml:"light_index"`
+	Power       string `json:"power" yaml:"power"`
+	Brightness  int    `json:"brightness" yaml:"brightness"`
+	Temperature int    `json:"temperature" yaml:"temperature"`
+	Kelvin      int    `json:"kelvin" yaml:"kelvin"`
+}
+
+// newLightStateRecords returns a record for each light of the accessory that is
+// selected by channels, or for every light if channels is nil.
+func newLightStateRecords(light *keylight.KeyLight, displayName string, opts *keylight.KeyLightOptions, channels []int) []lightStateRecord {
+	var result []lightStateRecord
+	for idx, l := range opts.Lights {
+		if !channelSelected(channels, idx) {
+			continue
+		}
+		result = append(result, lightStateRecord{
+			Name:        light.Name,
+			ShortID:     lightShortID(light.Name),
+			DisplayName: displayName,
+			Address:     light.DNSAddr,
+			Port:        light.Port,
+			LightIndex:  idx,
+			Power:       powerStateString(l.On),
+			Brightness:  l.Brightness,
+			Temperature: l.Temperature,
+			Kelvin:      miredsToKelvin(l.Temperature),
+		})
+	}
+	return result
+}
+
+// powerStateString returns the human readable representation of the on field
+// of a light.
+func powerStateString(on int) string {
+	if on == 1 {
+		return "on"
+	}
+	return "off"
+}
+
+// lightDetailRecord extends lightStateRecord with the accessory info and
+// device settings of the accessory that the light belongs to.
+type lightDetailRecord struct {
+	lightStateRecord `yaml:",inline"`
+
+	ProductName           string   `json:"product_name" yaml:"product_name"`
+	SerialNumber          string   `json:"serial_number" yaml:"serial_number"`
+	HardwareBoardType     int      `json:"hardware_board_type" yaml:"hardware_board_type"`
+	FirmwareVersion       string   `json:"firmware_version" yaml:"firmware_version"`
+	FirmwareBuildNumber   int      `json:"firmware_build_number" yaml:"firmware_build_number"`
+	Features              []string `json:"features" yaml:"features"`
+	PowerOnBehavior       int      `json:"power_on_behavior" yaml:"power_on_behavior"`
+	PowerOnBrightness     int      `json:"power_on_brightness" yaml:"power_on_brightness"`
+	PowerOnTemperature    int      `json:"power_on_temperature" yaml:"power_on_temperature"`
+	SwitchOnDurationMs    int      `json:"switch_on_duration_ms" yaml:"switch_on_duration_ms"`
+	SwitchOffDurationMs   int      `json:"switch_off_duration_ms" yaml:"switch_off_duration_ms"`
+	ColorChangeDurationMs int      `json:"color_change_duration_ms" yaml:"color_change_duration_ms"`
+}
+
+func newLightDetailRecords(light *keylight.KeyLight, details *lightDetails, channels []int) []lightDetailRecord {
+	var result []lightDetailRecord
+	for _, state := range newLightStateRecords(light, details.Info.DisplayName, details.Options, channels) {
+		result = append(result, lightDetailRecord{
+			lightStateRecord:      state,
+			ProductName:           details.Info.ProductName,
+			SerialNumber:          details.Info.SerialNumber,
+			HardwareBoardType:     details.Info.HardwareBoardType,
+			FirmwareVersion:       details.Info.FirmwareVersion,
+			FirmwareBuildNumber:   details.Info.FirmwareBuildNumber,
+			Features:              details.Info.Features,
+			PowerOnBehavior:       details.Settings.PowerOnBehavior,
+			PowerOnBrightness:     details.Settings.PowerOnBrightness,
+			PowerOnTemperature:    details.Settings.PowerOnTemperature,
+			SwitchOnDurationMs:    details.Settings.SwitchOnDurationMs,
+			SwitchOffDurationMs:   details.Settings.SwitchOffDurationMs,
+			ColorChangeDurationMs: details.Settings.ColorChangeDurationMs,
+		})
+	}
+	return result
+}
+
+// lightSettingsRecord is the machine readable representation of the device
+// settings of an accessory.
+type lightSettingsRecord struct {
+	Name                  string `json:"name" yaml:"name"`
+	ShortID               string `json:"short_id" yaml:"short_id"`
+	Address               string `json:"address" yaml:"address"`
+	Port                  int    `json:"port" yaml:"port"`
+	PowerOnBehavior       int    `json:"power_on_behavior" yaml:"power_on_behavior"`
+	PowerOnBrightness     int    `json:"power_on_brightness" yaml:"power_on_brightness"`
+	PowerOnTemperature    int    `json:"power_on_temperature" yaml:"power_on_temperature"`
+	SwitchOnDurationMs    int    `json:"switch_on_duration_ms" yaml:"switch_on_duration_ms"`
+	SwitchOffDurationMs   int    `json:"switch_off_duration_ms" yaml:"switch_off_duration_ms"`
+	ColorChangeDurationMs int    `json:"color_change_duration_ms" yaml:"color_change_duration_ms"`
+}
+
+func newLightSettingsRecord(light *keylight.KeyLight, settings *keylight.KeyLightSettings) lightSettingsRecord {
+	return lightSettingsRecord{
+		Name:                  light.Name,
+		ShortID:               lightShortID(light.Name),
+		Address:               light.DNSAddr,
+		Port:                  light.Port,
+		PowerOnBehavior:       settings.PowerOnBehavior,
+		PowerOnBrightness:     settings.PowerOnBrightness,
+		PowerOnTemperature:    settings.PowerOnTemperature,
+		SwitchOnDurationMs:    settings.SwitchOnDurationMs,
+		SwitchOffDurationMs:   settings.SwitchOffDurationMs,
+		ColorChangeDurationMs: settings.ColorChangeDurationMs,
+	}
+}
+
+// configRecord is the machine readable representation of the effective value
+// of a config setting and where it came from.
+type configRecord struct {
+	Setting string `json:"setting" yaml:"setting"`
+	Value   string `json:"value" yaml:"value"`
+	Source  string `json:"source" yaml:"source"`
+}
+
+func newConfigRecord(value *configValue) configRecord {
+	return configRecord{
+		Setting: value.Setting,
+		Value:   value.Value,
+		Source:  value.Source,
+	}
+}
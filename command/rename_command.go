@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+type RenameCommand struct {
+	Meta
+}
+
+func (c *RenameCommand) Help() string {
+	helpText := `
+Usage: keylightctl rename [options] <display-name>
+
+ Change the display name of a keylight. The display name is stored on the
+ device, is shown by describe and discover, and can be used with -light to
+ select the light.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Rename Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -light <light-id-or-addr>
+    The light to rename. Can either be a full key light name, e.g:
+    Elgato\ Key\ Light\ 111A, a short ID, e.g: 111A, its current display
+    name, a serial number, or an address. Must match exactly one light.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *RenameCommand) Synopsis() string {
+	return "Change the display name of a keylight"
+}
+
+func (f *RenameCommand) Name() string { return "rename" }
+
+func (c *RenameCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var requestedLights lightListFlags
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	flags.Var(&requestedLights, "light", "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 1 {
+		c.UI.Error("This command requires (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	displayName := strings.TrimSpace(args[0])
+	if displayName == "" {
+		c.UI.Error("Display name must not be empty")
+		return 1
+	}
+
+	if len(requestedLights) != 1 {
+		c.UI.Error("Exactly one --light must be provided")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	selection := lightSelection{Lights: requestedLights}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) != 1 {
+		c.UI.Error(fmt.Sprintf("Expected to find exactly one light, found %d", len(found)))
+		return 1
+	}
+	light := found[0]
+
+	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer updateCancelFn()
+
+	if err := updateDisplayName(updateCtx, light, displayName); err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to rename light (%s), err: %v", lightLabel(light), err))
+		return 1
+	}
+
+	inv, err := loadInventory()
+	if err == nil {
+		if entry := inv.Get(light.Name); entry != nil {
+			entry.DisplayName = displayName
+			err = inv.Save()
+		}
+	}
+	if err != nil {
+		c.UI.Warn(fmt.Sprintf("Failed to update inventory, err: %v", err))
+	}
+
+	c.UI.Info(fmt.Sprintf("Renamed %s to '%s'", lightLabel(light), displayName))
+
+	return 0
+}
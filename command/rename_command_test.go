@@ -0,0 +1,64 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestRenameCommand(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740", DisplayName: "Old"})
+
+	ui := cli.NewMockUi()
+	cmd := &RenameCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "-light", addr, " Desk Left ")
+
+	if displayName := sim.Info().DisplayName; displayName != "Desk Left" {
+		t.Errorf("expected the light to be renamed, got %q", displayName)
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("failed to load inventory: %v", err)
+	}
+	entry := inv.Get(`Elgato\ Key\ Light\ BW33J1A02740`)
+	if entry == nil || entry.DisplayName != "Desk Left" {
+		t.Fatalf("expected the inventory to be updated, got %+v", entry)
+	}
+
+	// The light can now be selected by its new name without discovery.
+	switchUI := cli.NewMockUi()
+	switchCmd := &SwitchCommand{Meta: Meta{UI: switchUI}}
+	runCommand(t, switchCmd, switchUI, 0, "-light", "desk left", "-timeout", "100ms", "on")
+	if on := sim.Options().Lights[0].On; on != 1 {
+		t.Errorf("expected the renamed light to be switched on")
+	}
+}
+
+func TestRenameCommand_Invalid(t *testing.T) {
+	cases := map[string]func(addr string) []string{
+		"no name":     func(addr string) []string { return []string{"-light", addr} },
+		"empty name":  func(addr string) []string { return []string{"-light", addr, " "} },
+		"no light":    func(addr string) []string { return []string{"Desk"} },
+		"two lights":  func(addr string) []string { return []string{"-light", addr, "-light", "111A", "Desk"} },
+		"two names":   func(addr string) []string { return []string{"-light", addr, "Desk", "Left"} },
+		"unreachable": func(addr string) []string { return []string{"-light", "127.0.0.1:1", "Desk"} },
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestConfig(t)
+			sim, addr := newTestLight(t, simulator.Config{DisplayName: "Old"})
+
+			ui := cli.NewMockUi()
+			cmd := &RenameCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 1, args(addr)...)
+
+			if displayName := sim.Info().DisplayName; displayName != "Old" {
+				t.Errorf("expected the light to keep its name, got %q", displayName)
+			}
+		})
+	}
+}
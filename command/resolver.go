@@ -0,0 +1,427 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// inventoryRecordTimeout is the maximum time we spend fetching metadata for
+// newly discovered lights before recording them in the inventory.
+const inventoryRecordTimeout = 5 * time.Second
+
+// lightResolver turns the lights that were requested on the command line into
+// clients. Direct addresses are used without discovery, named lights are
+// looked up in the inventory, then a running agent is asked for the remaining
+// lights, and mDNS discovery is only used for lights that neither of them could
+// provide.
+type lightResolver struct {
+	UI              cli.Ui
+	RequestedLights lightListFlags
+	AllLights       bool
+
+	// AllowChannels permits requesting a single light of an accessory, e.g:
+	// 111A#1. The selected channels are recorded in Channels.
+	AllowChannels bool
+	Channels      lightChannels
+
+	// LookupHost resolves hostnames that may also be the names of lights. It
+	// defaults to the system resolver.
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+
+	selectorChannels map[*lightSelector]int
+	// directSerials maps the serial numbers of the lights that were provided
+	// by address to their clients.
+	directSerials map[string]*keylight.KeyLight
+	// unresolvedHosts are the requested lights that looked like hostnames,
+	// but did not resolve and so were matched as names instead.
+	unresolvedHosts map[string]bool
+}
+
+func (r *lightResolver) Resolve(ctx context.Context) ([]*keylight.KeyLight, error) {
+	inv, err := loadInventory()
+	if err != nil {
+		r.UI.Warn(fmt.Sprintf("Ignoring light inventory, err: %v", err))
+		inv = nil
+	}
+
+	r.Channels = make(lightChannels)
+	r.selectorChannels = make(map[*lightSelector]int)
+	r.directSerials = make(map[string]*keylight.KeyLight)
+	r.unresolvedHosts = make(map[string]bool)
+
+	result, err := r.resolve(ctx, inv)
+	if err != nil {
+		return nil, r.explainUnresolvedHost(err)
+	}
+
+	return r.dedupeDirectLights(ctx, result, inv), nil
+}
+
+// isAddress returns whether a requested light is an address. Hostnames without
+// a scheme or port, e.g: keylight.local, may also be the name of a light, e.g:
+// desk.left, so they are only used as addresses if they do not match a light
+// in the inventory and resolve.
+func (r *lightResolver) isAddress(ctx context.Context, inv *inventory, req string) bool {
+	if !isDirectLightAddress(req) {
+		return false
+	}
+	if !isBareHostname(req) {
+		return true
+	}
+
+	if inv != nil {
+		if s, err := parseLightSelector(req); err == nil && len(inv.Lookup(s)) > 0 {
+			return false
+		}
+	}
+
+	lookupHost := r.LookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+
+	lookupCtx, cancelFn := context.WithTimeout(ctx, hostLookupTimeout)
+	defer cancelFn()
+	if _, err := lookupHost(lookupCtx, req); err != nil {
+		r.unresolvedHosts[strings.ToLower(req)] = true
+		return false
+	}
+	return true
+}
+
+// explainUnresolvedHost adds to the error of a requested light that was not
+// found why it was not used as an address.
+func (r *lightResolver) explainUnresolvedHost(err error) error {
+	var notFound *noLightFoundError
+	if !errors.As(err, &notFound) || !r.unresolvedHosts[strings.ToLower(notFound.Selector)] {
+		return err
+	}
+	return fmt.Errorf("%w, and it did not resolve as a hostname. Include the port, e.g: %s:%d, to always use it as an address",
+		err, notFound.Selector, defaultLightPort)
+}
+
+func (r *lightResolver) resolve(ctx context.Context, inv *inventory) ([]*keylight.KeyLight, error) {
+	var result []*keylight.KeyLight
+	seen := make(map[string]bool)
+
+	var selectors []*lightSelector
+	var direct []*keylight.KeyLight
+	for _, req := range r.RequestedLights {
+		req, channel := splitLightChannel(req)
+		if channel >= 0 && !r.AllowChannels {
+			return nil, fmt.Errorf("cannot select channel %s%d of '%s', this command only operates on whole accessories", lightChannelSeparator, channel, req)
+		}
+
+		if !r.isAddress(ctx, inv, req) {
+			s, err := parseLightSelector(req)
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, s)
+			r.selectorChannels[s] = channel
+			continue
+		}
+
+		addr, err := parseLightAddress(req)
+		if err != nil {
+			return nil, err
+		}
+
+		light := addr.KeyLight()
+		serial, err := nameDirectLight(ctx, light, inv)
+		if err != nil {
+			r.UI.Warn(fmt.Sprintf("Failed to fetch accessory info from %s, err: %v", addr, err))
+		}
+
+		r.Channels.add(lightLabel(light), channel)
+		if light.Name != "" {
+			if seen[light.Name] {
+				continue
+			}
+			seen[light.Name] = true
+			direct = append(direct, light)
+		}
+		if serial != "" {
+			r.directSerials[strings.ToUpper(serial)] = light
+		}
+		result = append(result, light)
+	}
+
+	if inv != nil && len(direct) > 0 {
+		// Recording lights that were provided by address keeps their names
+		// resolvable, e.g: when they are saved in a scene.
+		r.record(inv, direct)
+	}
+
+	var lightsToDiscover []*lightSelector
+	for _, s := range selectors {
+		cached, err := lookupReachable(inv, s)
+		if err != nil {
+			return nil, err
+		}
+		if len(cached) == 0 {
+			lightsToDiscover = append(lightsToDiscover, s)
+			continue
+		}
+
+		for _, entry := range cached {
+			r.Channels.add(entry.Name, r.selectorChannels[s])
+			if seen[entry.Name] {
+				continue
+			}
+			seen[entry.Name] = true
+			result = append(result, entry.KeyLight())
+		}
+	}
+
+	if len(lightsToDiscover) == 0 && !r.AllLights {
+		return result, nil
+	}
+
+	agentLights, ok, err := r.fromAgent(ctx, lightsToDiscover)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		for _, light := range agentLights {
+			if seen[light.Name] {
+				continue
+			}
+			seen[light.Name] = true
+			result = append(result, light)
+		}
+		return result, nil
+	}
+
+	discovery, err := keylight.NewDiscovery()
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup discoverer, err: %w", err)
+	}
+
+	var required []string
+	for _, s := range lightsToDiscover {
+		required = append(required, s.String())
+	}
+
+	discoverer := lightDiscoverer{
+		Discovery:      discovery,
+		AllLights:      r.AllLights,
+		RequiredLights: required,
+	}
+
+	discoveredLights, err := discoverer.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, s := range lightsToDiscover {
+		for _, identity := range discoverer.Matched(idx) {
+			r.Channels.add(identity.Name, r.selectorChannels[s])
+		}
+	}
+
+	if inv != nil {
+		r.record(inv, discoveredLights)
+	}
+
+	for _, light := range discoveredLights {
+		if seen[light.Name] {
+			continue
+		}
+		seen[light.Name] = true
+		result = append(result, light)
+	}
+
+	return result, nil
+}
+
+// dedupeDirectLights removes the lights that were also provided by address
+// from result. Unless the inventory knows a light that is provided by address,
+// its name differs from the mDNS instance name that it is discovered by, so
+// lights are matched by their serial number instead, which is taken from the
+// inventory or fetched from the light. The channels that were selected for a
+// removed light are merged into those of the light that was provided by
+// address.
+func (r *lightResolver) dedupeDirectLights(ctx context.Context, result []*keylight.KeyLight, inv *inventory) []*keylight.KeyLight {
+	if len(r.directSerials) == 0 {
+		return result
+	}
+
+	direct := make(map[*keylight.KeyLight]bool)
+	for _, light := range r.directSerials {
+		direct[light] = true
+	}
+
+	serials := make([]string, len(result))
+	var wg sync.WaitGroup
+	for idx, light := range result {
+		if direct[light] {
+			continue
+		}
+		if inv != nil {
+			if entry := inv.Get(light.Name); entry != nil && entry.SerialNumber != "" {
+				serials[idx] = entry.SerialNumber
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(idx int, light *keylight.KeyLight) {
+			defer wg.Done()
+
+			ctx, cancelFn := context.WithTimeout(ctx, displayNameTimeout)
+			defer cancelFn()
+			if info, err := light.FetchAccessoryInfo(ctx); err == nil {
+				serials[idx] = info.SerialNumber
+			}
+		}(idx, light)
+	}
+	wg.Wait()
+
+	var deduped []*keylight.KeyLight
+	for idx, light := range result {
+		existing, ok := r.directSerials[strings.ToUpper(serials[idx])]
+		if serials[idx] == "" || !ok || existing == light {
+			deduped = append(deduped, light)
+			continue
+		}
+
+		// Lights without selected channels, e.g: from -all, are selected as
+		// a whole.
+		label := lightLabel(light)
+		channels, selected := r.Channels[label]
+		if !selected || channels == nil {
+			r.Channels.add(lightLabel(existing), -1)
+		}
+		for _, channel := range channels {
+			r.Channels.add(lightLabel(existing), channel)
+		}
+		delete(r.Channels, label)
+	}
+	return deduped
+}
+
+// fromAgent resolves the requested lights using a running agent. It returns
+// false if no agent is running or the agent does not know about every
+// requested light, in which case the caller falls back to discovery. An error
+// is returned if an exact selector matches several lights.
+func (r *lightResolver) fromAgent(ctx context.Context, requested []*lightSelector) ([]*keylight.KeyLight, bool, error) {
+	client, explicit := newAgentClient()
+	if client == nil {
+		return nil, false, nil
+	}
+
+	queryCtx, cancelFn := context.WithTimeout(ctx, agentQueryTimeout)
+	defer cancelFn()
+
+	records, err := client.Lights(queryCtx)
+	if err != nil {
+		if explicit {
+			r.UI.Warn(fmt.Sprintf("Falling back to discovery, failed to query agent, err: %v", err))
+		} else {
+			client.ClearStaleAdvertisement(err)
+		}
+		return nil, false, nil
+	}
+
+	var result []*keylight.KeyLight
+	matches := make(map[*lightSelector][]lightIdentity)
+	for _, record := range records {
+		if !record.Reachable {
+			continue
+		}
+
+		identity := lightIdentity{
+			Name:        record.Name,
+			DisplayName: record.DisplayName,
+			Address:     joinLightAddress(record.Address, record.Port),
+		}
+		include := r.AllLights
+		for _, s := range requested {
+			if s.Matches(identity) {
+				matches[s] = append(matches[s], identity)
+				r.Channels.add(record.Name, r.selectorChannels[s])
+				include = true
+			}
+		}
+
+		if include {
+			result = append(result, &keylight.KeyLight{
+				Name:    record.Name,
+				DNSAddr: record.Address,
+				Port:    record.Port,
+			})
+		}
+	}
+
+	for _, s := range requested {
+		if len(matches[s]) == 0 {
+			return nil, false, nil
+		}
+		if err := s.Check(matches[s]); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, false, nil
+	}
+
+	return result, true, nil
+}
+
+// record updates the inventory with the addresses of freshly discovered or
+// directly addressed lights so that subsequent invocations can skip discovery.
+func (r *lightResolver) record(inv *inventory, lights []*keylight.KeyLight) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), inventoryRecordTimeout)
+	defer cancelFn()
+
+	for _, light := range lights {
+		entry, err := newInventoryEntry(ctx, light, inv.Get(light.Name))
+		if err != nil {
+			r.UI.Warn(fmt.Sprintf("Recording light without serial number, err: %v", err))
+		}
+		inv.Record(entry)
+	}
+
+	if err := inv.Save(); err != nil {
+		r.UI.Warn(err.Error())
+	}
+}
+
+// lookupReachable returns the inventory entries that match the selector, but
+// only if all of them are still reachable. A partial or unreachable match is
+// treated as a cache miss so that the caller falls back to discovery, as are
+// globs and regular expressions, which may match lights that are not in the
+// inventory yet. An error is returned if an exact selector matches several
+// reachable lights.
+func lookupReachable(inv *inventory, s *lightSelector) ([]*inventoryEntry, error) {
+	if inv == nil || s.IsPattern() {
+		return nil, nil
+	}
+
+	entries := inv.Lookup(s)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	for _, entry := range entries {
+		if !entry.Reachable() {
+			return nil, nil
+		}
+	}
+
+	if err := s.Check(inventoryIdentities(entries)); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,187 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestLightResolver_DirectAddress(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740"})
+
+	resolver := lightResolver{
+		UI:              cli.NewMockUi(),
+		RequestedLights: lightListFlags{addr, "http://" + addr + "/"},
+	}
+
+	found, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != `Elgato\ Key\ Light\ BW33J1A02740` {
+		t.Fatalf("expected a single light named after its accessory info, got %v", found)
+	}
+	if joinLightAddress(found[0].DNSAddr, found[0].Port) != addr {
+		t.Errorf("expected the light to be reached at %s, got %s:%d", addr, found[0].DNSAddr, found[0].Port)
+	}
+}
+
+func TestLightResolver_DedupeInventory(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{Lights: 2, SerialNumber: "BW33J1A02740"})
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	// The light was recorded under its mDNS name, but without its serial
+	// number, so the light that is provided by address is not named after it.
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("failed to load inventory: %v", err)
+	}
+	inv.Record(&inventoryEntry{Name: `Elgato\ Key\ Light\ 111A`, DNSAddr: host, Port: portNum})
+	if err := inv.Save(); err != nil {
+		t.Fatalf("failed to save inventory: %v", err)
+	}
+
+	resolver := lightResolver{
+		UI:              cli.NewMockUi(),
+		RequestedLights: lightListFlags{"111A#0", addr + "#1"},
+		AllowChannels:   true,
+	}
+
+	found, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected the light to be resolved once, got %v", found)
+	}
+
+	channels := resolver.Channels.Get(found[0])
+	if len(channels) != 2 || !channelSelected(channels, 0) || !channelSelected(channels, 1) {
+		t.Errorf("expected both channels to be selected, got %v", channels)
+	}
+}
+
+func TestLightResolver_DedupeAgent(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740"})
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	a := &agent{UI: cli.NewMockUi()}
+	a.observe(context.Background(), &keylight.KeyLight{
+		Name:    `Elgato\ Key\ Light\ 111A`,
+		DNSAddr: host,
+		Port:    portNum,
+	})
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+	t.Setenv(agentAddrEnvVar, srv.URL)
+
+	resolver := lightResolver{
+		UI:              cli.NewMockUi(),
+		RequestedLights: lightListFlags{addr, "111A"},
+	}
+
+	found, err := resolver.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(found) != 1 || found[0].Name != `Elgato\ Key\ Light\ BW33J1A02740` {
+		t.Fatalf("expected only the light that was provided by address, got %v", found)
+	}
+	if channels := resolver.Channels.Get(found[0]); channels != nil {
+		t.Errorf("expected the whole accessory to be selected, got %v", channels)
+	}
+}
+
+func TestLightResolver_ChannelsNotAllowed(t *testing.T) {
+	setupTestConfig(t)
+
+	resolver := lightResolver{
+		UI:              cli.NewMockUi(),
+		RequestedLights: lightListFlags{"111A#1"},
+	}
+
+	if _, err := resolver.Resolve(context.Background()); err == nil {
+		t.Errorf("expected an error when selecting a channel")
+	}
+}
+
+func TestLightResolver_IsAddress(t *testing.T) {
+	setupTestConfig(t)
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("failed to load inventory: %v", err)
+	}
+	inv.Record(&inventoryEntry{Name: `Elgato\ Key\ Light\ 111A`, DisplayName: "desk.left", DNSAddr: "192.168.1.20", Port: defaultLightPort})
+
+	cases := []struct {
+		name     string
+		req      string
+		expected bool
+		lookup   bool
+	}{
+		{name: "name", req: "111A", expected: false},
+		{name: "ip", req: "192.168.1.20", expected: true},
+		{name: "host and port", req: "desk.left:9123", expected: true},
+		{name: "url", req: "http://desk.left", expected: true},
+		{name: "display name", req: "desk.left", expected: false},
+		{name: "resolvable hostname", req: "keylight.local", expected: true, lookup: true},
+		{name: "unresolvable hostname", req: "desk.right", expected: false, lookup: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			looked := false
+			resolver := lightResolver{
+				UI: cli.NewMockUi(),
+				LookupHost: func(ctx context.Context, host string) ([]string, error) {
+					looked = true
+					if host == "keylight.local" {
+						return []string{"192.168.1.21"}, nil
+					}
+					return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+				},
+				unresolvedHosts: make(map[string]bool),
+			}
+
+			if isAddress := resolver.isAddress(context.Background(), inv, tc.req); isAddress != tc.expected {
+				t.Errorf("expected isAddress to be %v, got %v", tc.expected, isAddress)
+			}
+			if looked != tc.lookup {
+				t.Errorf("expected the hostname to be looked up: %v, got %v", tc.lookup, looked)
+			}
+		})
+	}
+}
+
+func TestLightResolver_ExplainUnresolvedHost(t *testing.T) {
+	resolver := lightResolver{unresolvedHosts: map[string]bool{"desk.right": true}}
+
+	err := resolver.explainUnresolvedHost(&noLightFoundError{Selector: "desk.right"})
+	if err == nil || !strings.Contains(err.Error(), "did not resolve as a hostname") || !strings.Contains(err.Error(), "desk.right:9123") {
+		t.Errorf("expected the error to explain why the light was not used as an address, got %v", err)
+	}
+
+	var notFound *noLightFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected the error to wrap the original error")
+	}
+
+	other := &noLightFoundError{Selector: "111A"}
+	if err := resolver.explainUnresolvedHost(other); err != other {
+		t.Errorf("expected other errors to be returned unchanged, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type SceneApplyCommand struct {
+	Meta
+}
+
+func (c *SceneApplyCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene apply [options] <scene>
+
+ Restore the power state, brightness and temperature of every light in the
+ given scene.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Scene Apply Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  ` + fanOutOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneApplyCommand) Synopsis() string {
+	return "Apply a saved scene"
+}
+
+func (f *SceneApplyCommand) Name() string { return "scene apply" }
+
+func (c *SceneApplyCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var fanOut lightFanOut
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	fanOut.AddFlags(flags)
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 1 {
+		c.UI.Error("This command requires (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	sc, err := scenes.Get(args[0])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := resolveSceneLights(discoveryCtx, c.UI, sc)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer updateCancelFn()
+
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		for _, sl := range sc.Lights {
+			if !sl.Matches(light) {
+				continue
+			}
+
+			if err := sl.Restore(ctx, light); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return reportResults(c.UI, results)
+}
+
+// resolveSceneLights resolves all of the lights in the scene, returning an
+// error if any of them could not be found.
+func resolveSceneLights(ctx context.Context, ui cli.Ui, sc *scene) ([]*keylight.KeyLight, error) {
+	var requested lightListFlags
+	for _, sl := range sc.Lights {
+		requested = append(requested, sl.Selector())
+	}
+
+	resolver := lightResolver{
+		UI:              ui,
+		RequestedLights: requested,
+	}
+
+	found, err := resolver.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+SCENE_LIGHTS:
+	for _, sl := range sc.Lights {
+		for _, light := range found {
+			if sl.Matches(light) {
+				continue SCENE_LIGHTS
+			}
+		}
+		return nil, fmt.Errorf("no light found for '%s'", sl.Light)
+	}
+
+	return found, nil
+}
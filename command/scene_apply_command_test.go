@@ -0,0 +1,77 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestSceneApplyCommand(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	set := &SetCommand{Meta: Meta{UI: ui}}
+	runCommand(t, set, ui, 0, "-light", addr, "-brightness", "30,70", "-temperature", "150,300")
+
+	save := &SceneSaveCommand{Meta: Meta{UI: ui}}
+	runCommand(t, save, ui, 0, "-light", addr, "evening")
+
+	runCommand(t, set, ui, 0, "-light", addr, "-brightness", "100", "-temperature", "344")
+
+	apply := &SceneApplyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, apply, ui, 0, "evening")
+
+	expected := []keylight.KeyLightLight{
+		{Brightness: 30, Temperature: 150},
+		{Brightness: 70, Temperature: 300},
+	}
+	for idx, want := range expected {
+		if got := *sim.Options().Lights[idx]; got != want {
+			t.Errorf("light %d: expected %+v, got %+v", idx, want, got)
+		}
+	}
+}
+
+func TestSceneApplyCommand_Channel(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	save := &SceneSaveCommand{Meta: Meta{UI: ui}}
+	runCommand(t, save, ui, 0, "-light", addr+"#1", "second")
+
+	set := &SetCommand{Meta: Meta{UI: ui}}
+	runCommand(t, set, ui, 0, "-light", addr, "-brightness", "90")
+
+	apply := &SceneApplyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, apply, ui, 0, "second")
+
+	if opts := sim.Options(); opts.Lights[0].Brightness != 90 || opts.Lights[1].Brightness != 20 {
+		t.Errorf("expected only the second light to be restored, got %+v, %+v", opts.Lights[0], opts.Lights[1])
+	}
+}
+
+func TestSceneApplyCommand_UnknownScene(t *testing.T) {
+	setupTestConfig(t)
+
+	ui := cli.NewMockUi()
+	apply := &SceneApplyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, apply, ui, 1, "missing")
+}
+
+func TestSceneApplyCommand_Failure(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{})
+
+	ui := cli.NewMockUi()
+	save := &SceneSaveCommand{Meta: Meta{UI: ui}}
+	runCommand(t, save, ui, 0, "-light", addr, "default")
+
+	sim.SetFailureRate(1)
+
+	apply := &SceneApplyCommand{Meta: Meta{UI: ui}}
+	runCommand(t, apply, ui, 1, "-timeout", "1s", "default")
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type SceneCommand struct {
+	Meta
+}
+
+func (c *SceneCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene <subcommand> [options] [args]
+
+ This command groups subcommands for saving and restoring the state of lights.
+
+ Scenes are stored in scenes.yaml within the keylightctl config dir, which is a
+ readable file that can be edited by hand or kept in version control.
+
+ Save the current state of the lights in the desk group:
+
+     $ keylightctl scene save -group desk streaming
+
+ Restore the saved state:
+
+     $ keylightctl scene apply streaming
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneCommand) Synopsis() string {
+	return "Save and restore light scenes"
+}
+
+func (f *SceneCommand) Name() string { return "scene" }
+
+func (c *SceneCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
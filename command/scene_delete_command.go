@@ -0,0 +1,76 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type SceneDeleteCommand struct {
+	Meta
+}
+
+func (c *SceneDeleteCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene delete [options] <scene>
+
+ Delete a saved scene.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneDeleteCommand) Synopsis() string {
+	return "Delete a saved scene"
+}
+
+func (f *SceneDeleteCommand) Name() string { return "scene delete" }
+
+func (c *SceneDeleteCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 1 {
+		c.UI.Error("This command requires (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	name := args[0]
+	if _, err := scenes.Get(name); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	delete(scenes.Scenes, name)
+	if err := scenes.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Info(fmt.Sprintf("Deleted scene '%s'", name))
+
+	return 0
+}
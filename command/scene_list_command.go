@@ -0,0 +1,86 @@
+package command
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type SceneListCommand struct {
+	Meta
+}
+
+func (c *SceneListCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene list [options]
+
+ List the saved scenes.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneListCommand) Synopsis() string {
+	return "List saved scenes"
+}
+
+func (f *SceneListCommand) Name() string { return "scene list" }
+
+func (c *SceneListCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(scenes.Scenes) == 0 {
+		c.UI.Error("No scenes are saved, try 'keylightctl scene save'")
+		return 1
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Scene", "Lights"})
+
+	for _, name := range scenes.Names() {
+		var lights []string
+		if sc := scenes.Scenes[name]; sc != nil {
+			for _, sl := range sc.Lights {
+				lights = append(lights, sl.Light)
+			}
+		}
+
+		t.AppendRows([]table.Row{
+			{name, strings.Join(lights, ", ")},
+		})
+	}
+	t.Render()
+
+	return 0
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type SceneSaveCommand struct {
+	Meta
+}
+
+func (c *SceneSaveCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene save [options] <scene>
+
+ Save the current power state, brightness and temperature of the selected
+ lights as a scene. An existing scene with the same name is replaced.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Scene Save Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Save all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Save") + `
+
+  -group <group>
+    Save all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  ` + fanOutOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneSaveCommand) Synopsis() string {
+	return "Save the current state of lights as a scene"
+}
+
+func (f *SceneSaveCommand) Name() string { return "scene save" }
+
+func (c *SceneSaveCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var selection lightSelection
+	var fanOut lightFanOut
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	selection.AllowChannels = true
+	fanOut.AddFlags(flags)
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 1 {
+		c.UI.Error("This command requires (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	name := args[0]
+	if err := validateName(name); err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid scene name, err: %v", err))
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	fetchCtx, fetchCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer fetchCancelFn()
+
+	sceneLights := make([]*sceneLight, len(found))
+	results := fanOut.Run(fetchCtx, found, func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		opts, err := light.FetchLightOptions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch light options, err: %w", err)
+		}
+
+		channels := selection.Channels().Get(light)
+		if _, err := selectChannels(opts, channels); err != nil {
+			return err
+		}
+		sceneLights[idx] = newSceneLight(light, opts, channels)
+		return nil
+	})
+
+	if code := reportFetchErrors(c.UI, results); code != 0 {
+		c.UI.Error(fmt.Sprintf("Scene '%s' was not saved", name))
+		return code
+	}
+
+	sc := &scene{Lights: sceneLights}
+	scenes.Scenes[name] = sc
+	if err := scenes.Save(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Info(fmt.Sprintf("Saved %d light(s) to scene '%s'", len(sc.Lights), name))
+
+	return 0
+}
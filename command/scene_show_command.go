@@ -0,0 +1,87 @@
+package command
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type SceneShowCommand struct {
+	Meta
+}
+
+func (c *SceneShowCommand) Help() string {
+	helpText := `
+Usage: keylightctl scene show [options] <scene>
+
+ Show the saved state of every light in a scene.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SceneShowCommand) Synopsis() string {
+	return "Show the contents of a scene"
+}
+
+func (f *SceneShowCommand) Name() string { return "scene show" }
+
+func (c *SceneShowCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 1 {
+		c.UI.Error("This command requires (1) argument")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	scenes, err := loadScenes()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	sc, err := scenes.Get(args[0])
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Light", "Channel", "Power State", "Brightness", "Temperature"})
+
+	for _, sl := range sc.Lights {
+		for pos, ch := range sl.Channels {
+			powerState := "off"
+			if ch.Powered {
+				powerState = "on"
+			}
+
+			t.AppendRows([]table.Row{
+				{sl.Selector(), sl.ChannelIndex(pos), powerState, ch.Brightness, ch.Temperature},
+			})
+		}
+	}
+	t.Render()
+
+	return 0
+}
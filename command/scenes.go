@@ -0,0 +1,231 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// scenesFileName is the name of the scene configuration within the config dir.
+const scenesFileName = "scenes.yaml"
+
+// scene is a saved state for a set of lights that can be re-applied later.
+type scene struct {
+	Lights []*sceneLight `yaml:"lights"`
+}
+
+func (s *scene) validate() error {
+	if s == nil {
+		return nil
+	}
+	for _, l := range s.Lights {
+		if l == nil {
+			return errors.New("empty light")
+		}
+		if err := l.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sceneLight is the saved state of a single accessory. Light holds a value
+// that can be passed to -light to find the accessory again, optionally with
+// the index of a single light, e.g: `111A#1`.
+type sceneLight struct {
+	Light    string          `yaml:"light"`
+	Channels []*sceneChannel `yaml:"channels"`
+}
+
+// sceneChannel is the saved state of an individual light within an accessory.
+type sceneChannel struct {
+	// Index is the index of the light within the accessory. It is only saved
+	// when some of the lights of an accessory were selected, otherwise the
+	// channels are restored in order.
+	Index *int `yaml:"index,omitempty"`
+
+	Powered     bool `yaml:"powered"`
+	Brightness  int  `yaml:"brightness"`
+	Temperature int  `yaml:"temperature"`
+}
+
+// newSceneLight captures the lights of the given light options that are
+// selected by channels, or every light if channels is nil.
+func newSceneLight(light *keylight.KeyLight, opts *keylight.KeyLightOptions, channels []int) *sceneLight {
+	result := &sceneLight{Light: lightLabel(light)}
+	for idx, l := range opts.Lights {
+		if !channelSelected(channels, idx) {
+			continue
+		}
+
+		channel := &sceneChannel{
+			Powered:     l.On == 1,
+			Brightness:  l.Brightness,
+			Temperature: l.Temperature,
+		}
+		if channels != nil {
+			index := idx
+			channel.Index = &index
+		}
+		result.Channels = append(result.Channels, channel)
+	}
+	return result
+}
+
+// Selector returns the light requirement of the accessory, without the index
+// of a single light.
+func (s *sceneLight) Selector() string {
+	light, _ := splitLightChannel(s.Light)
+	return light
+}
+
+// ChannelIndex returns the index of the light within the accessory that the
+// channel at position pos of Channels restores.
+func (s *sceneLight) ChannelIndex(pos int) int {
+	if c := s.Channels[pos]; c.Index != nil {
+		return *c.Index
+	}
+
+	_, offset := splitLightChannel(s.Light)
+	if offset < 0 {
+		offset = 0
+	}
+	return offset + pos
+}
+
+// Apply restores the saved state of each channel to the lights of an
+// accessory. Channels without an index are restored in order, starting at the
+// light that is selected by Light, if any.
+func (s *sceneLight) Apply(lights []*keylight.KeyLightLight) error {
+	for pos, c := range s.Channels {
+		idx := s.ChannelIndex(pos)
+		if idx < 0 || idx >= len(lights) {
+			return fmt.Errorf("scene includes channel %s%d, but the accessory has %d light(s)", lightChannelSeparator, idx, len(lights))
+		}
+
+		on := 0
+		if c.Powered {
+			on = 1
+		}
+		lights[idx].On = on
+		lights[idx].Brightness = c.Brightness
+		lights[idx].Temperature = c.Temperature
+	}
+
+	return nil
+}
+
+func (s *sceneLight) validate() error {
+	if s.Light == "" {
+		return errors.New("missing light")
+	}
+	for pos, c := range s.Channels {
+		if c == nil {
+			return fmt.Errorf("light '%s': empty channel", s.Light)
+		}
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("light '%s', channel %d: %w", s.Light, pos, err)
+		}
+	}
+	return nil
+}
+
+func (c *sceneChannel) validate() error {
+	if c.Index != nil && *c.Index < 0 {
+		return fmt.Errorf("invalid index %d", *c.Index)
+	}
+	if c.Brightness < minBrightness || c.Brightness > maxBrightness {
+		return fmt.Errorf("brightness %d is outside of %d-%d", c.Brightness, minBrightness, maxBrightness)
+	}
+	if c.Temperature < minTemperature || c.Temperature > maxTemperature {
+		return fmt.Errorf("temperature %d is outside of %d-%d", c.Temperature, minTemperature, maxTemperature)
+	}
+	return nil
+}
+
+// Restore applies the saved state to the light. The current state is fetched
+// first, so that lights of the accessory that are not part of the scene are
+// left alone.
+func (s *sceneLight) Restore(ctx context.Context, light *keylight.KeyLight) error {
+	return modifyLightChannels(ctx, light, nil, s.Apply)
+}
+
+// Matches returns whether the given resolved light is the one described by s.
+func (s *sceneLight) Matches(light *keylight.KeyLight) bool {
+	selector, err := parseLightSelector(s.Selector())
+	if err != nil {
+		return false
+	}
+	return selector.Matches(newLightIdentity(light))
+}
+
+// sceneConfig is the set of saved scenes.
+type sceneConfig struct {
+	Scenes map[string]*scene
+
+	path string
+}
+
+// loadScenes reads the saved scenes from the config dir. A missing file is
+// not an error and results in no scenes.
+func loadScenes() (*sceneConfig, error) {
+	path, err := configFilePath(scenesFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &sceneConfig{
+		Scenes: make(map[string]*scene),
+		path:   path,
+	}
+
+	err = readYAMLFile(path, &cfg.Scenes)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load scenes, err: %w", err)
+	}
+	if cfg.Scenes == nil {
+		cfg.Scenes = make(map[string]*scene)
+	}
+
+	// The file may have been edited by hand, so its values are checked
+	// before they are sent to any light.
+	for name, sc := range cfg.Scenes {
+		if err := sc.validate(); err != nil {
+			return nil, fmt.Errorf("invalid scene '%s' in %s, err: %w", name, path, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Save persists the scenes to disk.
+func (s *sceneConfig) Save() error {
+	if err := writeYAMLFile(s.path, s.Scenes); err != nil {
+		return fmt.Errorf("failed to save scenes, err: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the named scene, or an error if it does not exist.
+func (s *sceneConfig) Get(name string) (*scene, error) {
+	sc, ok := s.Scenes[name]
+	if !ok || sc == nil {
+		return nil, fmt.Errorf("no scene named '%s'", name)
+	}
+	return sc, nil
+}
+
+// Names returns the names of all scenes in sorted order.
+func (s *sceneConfig) Names() []string {
+	var names []string
+	for name := range s.Scenes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
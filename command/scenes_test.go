@@ -0,0 +1,119 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// writeScenes replaces the scenes file with contents, as if it was edited by
+// hand.
+func writeScenes(t *testing.T, contents string) {
+	t.Helper()
+
+	path, err := configFilePath(scenesFileName)
+	if err != nil {
+		t.Fatalf("failed to find the scenes file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadScenes(t *testing.T) {
+	setupTestConfig(t)
+	writeScenes(t, `
+evening:
+  lights:
+    - light: 111A
+      channels:
+        - index: 1
+          powered: true
+          brightness: 40
+          temperature: 250
+`)
+
+	scenes, err := loadScenes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sc, err := scenes.Get("evening")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sc.Lights) != 1 || sc.Lights[0].ChannelIndex(0) != 1 {
+		t.Errorf("unexpected scene %+v", sc.Lights)
+	}
+}
+
+func TestLoadScenes_Invalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		channel  string
+		expected string
+	}{
+		{name: "negative index", channel: "{index: -1, brightness: 40, temperature: 250}", expected: "invalid index -1"},
+		{name: "brightness too low", channel: "{brightness: 0, temperature: 250}", expected: "brightness 0"},
+		{name: "brightness too high", channel: "{brightness: 101, temperature: 250}", expected: "brightness 101"},
+		{name: "temperature in kelvin", channel: "{brightness: 40, temperature: 4000}", expected: "temperature 4000"},
+		{name: "empty channel", channel: "null", expected: "empty channel"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTestConfig(t)
+			writeScenes(t, "evening:\n  lights:\n    - light: 111A\n      channels:\n        - "+tc.channel+"\n")
+
+			_, err := loadScenes()
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !strings.Contains(err.Error(), "scene 'evening'") || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error about %q in scene 'evening', got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSceneLight_Apply(t *testing.T) {
+	negative := -1
+	cases := []struct {
+		name  string
+		light *sceneLight
+		err   bool
+	}{
+		{
+			name:  "in order from offset",
+			light: &sceneLight{Light: "111A#1", Channels: []*sceneChannel{{Powered: true, Brightness: 40, Temperature: 250}}},
+		},
+		{
+			name:  "beyond the last light",
+			light: &sceneLight{Light: "111A#1", Channels: []*sceneChannel{{}, {}}},
+			err:   true,
+		},
+		{
+			name:  "negative index",
+			light: &sceneLight{Light: "111A", Channels: []*sceneChannel{{Index: &negative}}},
+			err:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			lights := []*keylight.KeyLightLight{{}, {}}
+			err := tc.light.Apply(lights)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error to be %v, got %v", tc.err, err)
+			}
+			if err == nil && (lights[1].On != 1 || lights[1].Brightness != 40 || lights[0].On != 0) {
+				t.Errorf("expected only the second light to be set, got %+v, %+v", lights[0], lights[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// schedulesFileName is the name of the schedule rules within the config
+	// dir.
+	schedulesFileName = "schedules.yaml"
+
+	// scheduleSearchDays is how far ahead we look for the next fire time of
+	// rules that only fire on some days, or at solar events that may not occur
+	// for months near the poles.
+	scheduleSearchDays = 366
+)
+
+// scheduleConfig is a set of automation rules. Rules fire in local time, e.g:
+//
+//	location:
+//	  latitude: 52.52
+//	  longitude: 13.40
+//	rules:
+//	  - name: morning
+//	    at: "08:55"
+//	    days: [weekdays]
+//	    lights: [111A]
+//	    switch: "on"
+//	  - name: evening
+//	    at: sunset
+//	    group: desk
+//	    set:
+//	      kelvin: 3200K
+//	  - name: weekly-reset
+//	    cron: "0 18 * * 5"
+//	    scene: default
+type scheduleConfig struct {
+	Location *scheduleLocation `yaml:"location"`
+	Rules    []*scheduleRule   `yaml:"rules"`
+
+	path string
+}
+
+// scheduleLocation is the location that solar events are computed for.
+type scheduleLocation struct {
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+}
+
+// scheduleRule applies an action to a selection of lights whenever its
+// trigger fires. A rule has exactly one of Cron or At, and exactly one of
+// Switch, Set or Scene.
+type scheduleRule struct {
+	Name string `yaml:"name"`
+
+	// Cron is a standard five field cron expression.
+	Cron string `yaml:"cron"`
+
+	// At is either a time of day, e.g: `08:55`, or a solar event with an
+	// optional offset, e.g: `sunset` or `sunrise-30m`. Days optionally limits
+	// the days of the week it fires on.
+	At   string   `yaml:"at"`
+	Days []string `yaml:"days"`
+
+	Lights []string `yaml:"lights"`
+	Groups []string `yaml:"groups"`
+	Group  string   `yaml:"group"`
+	All    bool     `yaml:"all"`
+
+	Switch string             `yaml:"switch"`
+	Set    *scheduleSetAction `yaml:"set"`
+	Scene  string             `yaml:"scene"`
+
+	trigger scheduleTrigger
+}
+
+// scheduleSetAction adjusts the brightness and/or temperature of lights, in
+// the same formats as the set command, e.g: `+10` or `3200K`.
+type scheduleSetAction struct {
+	Brightness  lightAdjustment  `yaml:"brightness"`
+	Temperature lightAdjustment  `yaml:"temperature"`
+	Kelvin      kelvinAdjustment `yaml:"kelvin"`
+}
+
+// scheduleTrigger computes the fire times of a rule.
+type scheduleTrigger interface {
+	// Next returns the first fire time after t, or the zero time if the
+	// trigger will not fire again.
+	Next(t time.Time) time.Time
+}
+
+// loadSchedules reads the schedule rules from path, or from the config dir if
+// path is empty, and validates every rule.
+func loadSchedules(path string) (*scheduleConfig, error) {
+	if path == "" {
+		var err error
+		path, err = configFilePath(schedulesFileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := &scheduleConfig{path: path}
+	if err := readYAMLFile(path, cfg); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no schedule rules found at %s", path)
+		}
+		return nil, fmt.Errorf("failed to load schedule rules, err: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for idx, rule := range cfg.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", idx+1)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name '%s'", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if err := rule.init(cfg.Location); err != nil {
+			return nil, fmt.Errorf("invalid rule '%s', err: %w", rule.Name, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// init validates the rule and parses its trigger.
+func (r *scheduleRule) init(location *scheduleLocation) error {
+	actions := 0
+	for _, set := range []bool{r.Switch != "", r.Set != nil, r.Scene != ""} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return errors.New("exactly one of switch, set or scene must be provided")
+	}
+
+	if r.Switch != "" && r.Switch != "on" && r.Switch != "off" && r.Switch != "toggle" {
+		return errors.New("switch must be 'on', 'off', or 'toggle'")
+	}
+
+	if r.Set != nil {
+		if !r.Set.Brightness.IsSet && !r.Set.Temperature.IsSet && !r.Set.Kelvin.IsSet {
+			return errors.New("set requires at least one of brightness, temperature or kelvin")
+		}
+		if r.Set.Temperature.IsSet && r.Set.Kelvin.IsSet {
+			return errors.New("set cannot specify temperature and kelvin together")
+		}
+		if err := r.Set.Brightness.Validate("brightness", minBrightness, maxBrightness); err != nil {
+			return err
+		}
+		if err := r.Set.Temperature.Validate("temperature", minTemperature, maxTemperature); err != nil {
+			return err
+		}
+	}
+
+	if r.Scene == "" {
+		if err := r.Selection().Validate(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case r.Cron != "" && r.At != "":
+		return errors.New("cannot specify cron and at together")
+	case r.Cron != "":
+		if len(r.Days) != 0 {
+			return errors.New("days can not be used with cron, use the day of week field instead")
+		}
+		schedule, err := cron.ParseStandard(r.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression, err: %w", err)
+		}
+		r.trigger = schedule
+	case r.At != "":
+		days, err := parseScheduleDays(r.Days)
+		if err != nil {
+			return err
+		}
+		trigger, err := parseAtTrigger(r.At, days, location)
+		if err != nil {
+			return err
+		}
+		r.trigger = trigger
+	default:
+		return errors.New("one of cron or at must be provided")
+	}
+
+	return nil
+}
+
+// Next returns the first fire time of the rule after t.
+func (r *scheduleRule) Next(t time.Time) time.Time {
+	return r.trigger.Next(t)
+}
+
+// Trigger returns a human readable description of when the rule fires.
+func (r *scheduleRule) Trigger() string {
+	if r.Cron != "" {
+		return "cron " + r.Cron
+	}
+	if len(r.Days) != 0 {
+		return fmt.Sprintf("at %s on %s", r.At, strings.Join(r.Days, ","))
+	}
+	return "at " + r.At
+}
+
+// Action returns a human readable description of what the rule does.
+func (r *scheduleRule) Action() string {
+	switch {
+	case r.Switch != "":
+		return "switch " + r.Switch
+	case r.Scene != "":
+		return "scene " + r.Scene
+	}
+
+	var parts []string
+	if r.Set.Brightness.IsSet {
+		parts = append(parts, "brightness "+r.Set.Brightness.String())
+	}
+	if r.Set.Temperature.IsSet {
+		parts = append(parts, "temperature "+r.Set.Temperature.String())
+	}
+	if r.Set.Kelvin.IsSet {
+		parts = append(parts, "kelvin "+r.Set.Kelvin.String())
+	}
+	return "set " + strings.Join(parts, ", ")
+}
+
+// Selection returns the lights that the rule applies to.
+func (r *scheduleRule) Selection() *lightSelection {
+	selection := &lightSelection{
+		Lights:        lightListFlags(r.Lights),
+		Groups:        lightListFlags(r.Groups),
+		AllLights:     r.All,
+		AllowChannels: true,
+	}
+	if r.Group != "" {
+		selection.Groups = append(selection.Groups, r.Group)
+	}
+	return selection
+}
+
+// Targets returns a human readable description of the lights the rule
+// applies to.
+func (r *scheduleRule) Targets() string {
+	if r.Scene != "" {
+		return "(scene)"
+	}
+
+	selection := r.Selection()
+	if selection.AllLights {
+		return "all"
+	}
+
+	targets := append([]string{}, selection.Lights...)
+	for _, group := range selection.Groups {
+		targets = append(targets, groupReferencePrefix+group)
+	}
+	return strings.Join(targets, ", ")
+}
+
+// Apply runs the action of the rule.
+func (r *scheduleRule) Apply(ctx context.Context, ui cli.Ui, timeout time.Duration) ([]*lightResult, error) {
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+
+	fanOut := lightFanOut{Concurrency: defaultConcurrency}
+
+	if r.Scene != "" {
+		scenes, err := loadScenes()
+		if err != nil {
+			return nil, err
+		}
+		sc, err := scenes.Get(r.Scene)
+		if err != nil {
+			return nil, err
+		}
+		found, err := resolveSceneLights(discoveryCtx, ui, sc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve lights, err: %w", err)
+		}
+		return fanOut.Run(ctx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+			for _, sl := range sc.Lights {
+				if !sl.Matches(light) {
+					continue
+				}
+				if err := sl.Restore(ctx, light); err != nil {
+					return err
+				}
+			}
+			return nil
+		}), nil
+	}
+
+	selection := r.Selection()
+	found, err := selection.Resolve(discoveryCtx, ui)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve lights, err: %w", err)
+	}
+
+	return fanOut.Run(ctx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		return modifyLightChannels(ctx, light, selection.Channels().Get(light), func(lights []*keylight.KeyLightLight) error {
+			for _, l := range lights {
+				r.mutate(l)
+			}
+			return nil
+		})
+	}), nil
+}
+
+// mutate applies a switch or set action to a light.
+func (r *scheduleRule) mutate(l *keylight.KeyLightLight) {
+	switch r.Switch {
+	case "on":
+		l.On = 1
+	case "off":
+		l.On = 0
+	case "toggle":
+		l.On = 1 - l.On
+	}
+
+	if r.Set != nil {
+		l.Brightness = r.Set.Brightness.Apply(l.Brightness, minBrightness, maxBrightness)
+		l.Temperature = r.Set.Temperature.Apply(l.Temperature, minTemperature, maxTemperature)
+		l.Temperature = r.Set.Kelvin.Apply(l.Temperature)
+	}
+}
+
+// scheduleWeekdays maps the names accepted in the days list to weekdays.
+var scheduleWeekdays = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// parseScheduleDays returns the set of weekdays in days. An empty list means
+// every day.
+func parseScheduleDays(days []string) (map[time.Weekday]bool, error) {
+	if len(days) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[time.Weekday]bool)
+	for _, day := range days {
+		weekdays, ok := scheduleWeekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day '%s', must be one of sun, mon, tue, wed, thu, fri, sat, weekdays or weekends", day)
+		}
+		for _, wd := range weekdays {
+			result[wd] = true
+		}
+	}
+	return result, nil
+}
+
+// dailyTrigger fires once on every matching day, at the time returned by At.
+type dailyTrigger struct {
+	// At returns the fire time on the given day, or false if it does not
+	// fire on that day.
+	At func(day time.Time) (time.Time, bool)
+
+	// Days limits the days of the week the trigger fires on. A nil map
+	// means every day.
+	Days map[time.Weekday]bool
+}
+
+func (d *dailyTrigger) Next(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < scheduleSearchDays; i++ {
+		candidateDay := day.AddDate(0, 0, i)
+		if d.Days != nil && !d.Days[candidateDay.Weekday()] {
+			continue
+		}
+
+		at, ok := d.At(candidateDay)
+		if ok && at.After(t) {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// parseAtTrigger parses a time of day, e.g: `08:55`, or a solar event with an
+// optional offset, e.g: `sunset+30m`.
+func parseAtTrigger(at string, days map[time.Weekday]bool, location *scheduleLocation) (scheduleTrigger, error) {
+	timeOfDay, err := parseTimeOfDay(at, location)
+	if err != nil {
+		return nil, err
+	}
+	return &dailyTrigger{Days: days, At: timeOfDay}, nil
+}
+
+// parseTimeOfDay parses a time of day or solar event in the format of
+// parseAtTrigger, and returns a function that computes it on a given day. The
+// function returns false on days where the event does not occur.
+func parseTimeOfDay(at string, location *scheduleLocation) (func(day time.Time) (time.Time, bool), error) {
+	for _, event := range []string{"sunrise", "sunset"} {
+		if !strings.HasPrefix(at, event) {
+			continue
+		}
+
+		var offset time.Duration
+		if rest := strings.TrimPrefix(at, event); rest != "" {
+			var err error
+			offset, err = time.ParseDuration(rest)
+			if err != nil || (rest[0] != '+' && rest[0] != '-') {
+				return nil, fmt.Errorf("invalid offset '%s', must be a duration such as +30m or -1h", rest)
+			}
+		}
+
+		if location == nil {
+			return nil, fmt.Errorf("%s requires a location with latitude and longitude", event)
+		}
+
+		isSunrise := event == "sunrise"
+		return func(day time.Time) (time.Time, bool) {
+			sunrise, sunset, ok := sunTimes(day, location.Latitude, location.Longitude)
+			if isSunrise {
+				return sunrise.Add(offset), ok
+			}
+			return sunset.Add(offset), ok
+		}, nil
+	}
+
+	parts := strings.Split(at, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid time '%s', must be HH:MM, sunrise or sunset", at)
+	}
+	hour, hourErr := strconv.Atoi(parts[0])
+	minute, minuteErr := strconv.Atoi(parts[1])
+	if hourErr != nil || minuteErr != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return nil, fmt.Errorf("invalid time '%s', must be HH:MM, sunrise or sunset", at)
+	}
+
+	return func(day time.Time) (time.Time, bool) {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location()), true
+	}, nil
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type ScheduleCommand struct {
+	Meta
+}
+
+func (c *ScheduleCommand) Help() string {
+	helpText := `
+Usage: keylightctl schedule <subcommand> [options] [args]
+
+ This command groups subcommands for running automation rules.
+
+ Rules are read from schedules.yaml in the keylightctl config dir, or the file
+ provided with -file. Each rule has a trigger, a selection of lights and an
+ action:
+
+     location:
+       latitude: 52.52
+       longitude: 13.40
+     rules:
+       - name: morning
+         at: "08:55"
+         days: [weekdays]
+         lights: [111A]
+         switch: "on"
+       - name: evening
+         at: sunset+15m
+         group: desk
+         set:
+           kelvin: 3200K
+       - name: friday
+         cron: "0 18 * * 5"
+         scene: wind-down
+
+ Triggers are either a standard five field cron expression (cron), or a time
+ of day or solar event with an optional offset (at), e.g: 08:55, sunrise or
+ sunset-30m. Solar events are computed offline from the location. at rules
+ may be limited to days of the week with days, using sun-sat, weekdays or
+ weekends.
+
+ Rules select lights with lights, groups (or group) or all, and apply one of
+ switch (on, off or toggle), set (brightness, temperature or kelvin, in the
+ same formats as the set command) or scene. Times are in the local timezone.
+
+ Show when each rule will next fire:
+
+     $ keylightctl schedule list
+
+ Run the rules until interrupted:
+
+     $ keylightctl schedule run
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *ScheduleCommand) Synopsis() string {
+	return "Run scheduled automation rules"
+}
+
+func (f *ScheduleCommand) Name() string { return "schedule" }
+
+func (c *ScheduleCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,112 @@
+package command
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/mitchellh/cli"
+)
+
+type ScheduleListCommand struct {
+	Meta
+}
+
+func (c *ScheduleListCommand) Help() string {
+	helpText := `
+Usage: keylightctl schedule list [options]
+
+ List the schedule rules and the next times that they will fire, so that rules
+ can be verified without waiting for them.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Schedule List Options:
+
+  -file <path>
+    The rules file to read (default: schedules.yaml in the config dir)
+
+  -count <count>
+    The number of upcoming fire times to show for each rule (default: 1)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *ScheduleListCommand) Synopsis() string {
+	return "List schedule rules and their next fire times"
+}
+
+func (f *ScheduleListCommand) Name() string { return "schedule list" }
+
+func (c *ScheduleListCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var path string
+	var count int
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&path, "file", "", "")
+	flags.IntVar(&count, "count", 1, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if count < 1 {
+		c.UI.Error("--count must be at least 1")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	schedules, err := loadSchedules(path)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(schedules.Rules) == 0 {
+		c.UI.Error("No schedule rules are defined")
+		return 1
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Rule", "Trigger", "Lights", "Action", "Next"})
+
+	now := time.Now()
+	for _, rule := range schedules.Rules {
+		var next []string
+		at := now
+		for i := 0; i < count; i++ {
+			at = rule.Next(at)
+			if at.IsZero() {
+				next = append(next, "never")
+				break
+			}
+			next = append(next, at.Format("Mon 2006-01-02 15:04 MST"))
+		}
+
+		t.AppendRows([]table.Row{
+			{rule.Name, rule.Trigger(), rule.Targets(), rule.Action(), strings.Join(next, "\n")},
+		})
+	}
+	t.Render()
+
+	return 0
+}
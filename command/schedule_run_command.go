@@ -0,0 +1,181 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mitchellh/cli"
+)
+
+const (
+	// scheduleMaxSleep bounds how long the scheduler sleeps at a time, so
+	// that it notices changes to the system clock or resuming from suspend.
+	scheduleMaxSleep = time.Minute
+
+	// scheduleMissedGrace is how late a rule may run, e.g. after the machine
+	// resumes from suspend. Rules that are later than this are skipped.
+	scheduleMissedGrace = 5 * time.Minute
+)
+
+type ScheduleRunCommand struct {
+	Meta
+}
+
+func (c *ScheduleRunCommand) Help() string {
+	helpText := `
+Usage: keylightctl schedule run [options]
+
+ Run the schedule rules until interrupted. See 'keylightctl schedule' for the
+ format of the rules file.
+
+ Rules that are missed by more than 5 minutes, e.g. because the machine was
+ suspended, are skipped until their next fire time.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Schedule Run Options:
+
+  -file <path>
+    The rules file to read (default: schedules.yaml in the config dir)
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories each time a rule fires
+    (default: 5s)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *ScheduleRunCommand) Synopsis() string {
+	return "Run schedule rules until interrupted"
+}
+
+func (f *ScheduleRunCommand) Name() string { return "schedule run" }
+
+func (c *ScheduleRunCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var path string
+	var timeout time.Duration
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&path, "file", "", "")
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	schedules, err := loadSchedules(path)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(schedules.Rules) == 0 {
+		c.UI.Error("No schedule rules are defined")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	now := time.Now()
+	next := make(map[*scheduleRule]time.Time)
+	for _, rule := range schedules.Rules {
+		next[rule] = rule.Next(now)
+		c.logNext(rule, next[rule])
+	}
+
+	for {
+		wait := scheduleMaxSleep
+		for _, at := range next {
+			if !at.IsZero() && time.Until(at) < wait {
+				wait = time.Until(at)
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, rule := range schedules.Rules {
+			at := next[rule]
+			due, missed := scheduleDue(at, now)
+			if !due {
+				continue
+			}
+
+			if missed {
+				c.UI.Warn(fmt.Sprintf("Skipping rule '%s', missed its fire time of %s", rule.Name, at.Format(time.RFC3339)))
+			} else {
+				c.runRule(ctx, rule, timeout)
+			}
+
+			next[rule] = rule.Next(now)
+			c.logNext(rule, next[rule])
+		}
+	}
+}
+
+// scheduleDue returns whether a rule with the fire time at is due at now, and
+// whether it was missed by more than scheduleMissedGrace and should be
+// skipped. A zero fire time is never due.
+func scheduleDue(at, now time.Time) (due, missed bool) {
+	if at.IsZero() || at.After(now) {
+		return false, false
+	}
+	return true, now.Sub(at) > scheduleMissedGrace
+}
+
+// runRule applies the action of a rule and logs the result for each light.
+func (c *ScheduleRunCommand) runRule(ctx context.Context, rule *scheduleRule, timeout time.Duration) {
+	c.UI.Info(fmt.Sprintf("Running rule '%s': %s", rule.Name, rule.Action()))
+
+	results, err := rule.Apply(ctx, c.UI, timeout)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Rule '%s' failed, err: %v", rule.Name, err))
+		return
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			c.UI.Error(fmt.Sprintf("Rule '%s' failed to update light (%s), err: %v", rule.Name, lightLabel(r.Light), r.Err))
+		}
+	}
+	c.UI.Info(fmt.Sprintf("Rule '%s' updated %d of %d light(s)", rule.Name, len(results)-failed, len(results)))
+}
+
+func (c *ScheduleRunCommand) logNext(rule *scheduleRule, at time.Time) {
+	if at.IsZero() {
+		c.UI.Warn(fmt.Sprintf("Rule '%s' will not fire again", rule.Name))
+		return
+	}
+	c.UI.Output(fmt.Sprintf("Rule '%s' will next fire at %s", rule.Name, at.Format(time.RFC3339)))
+}
@@ -0,0 +1,33 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDue(t *testing.T) {
+	now := time.Date(2024, time.October, 17, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		at     time.Time
+		due    bool
+		missed bool
+	}{
+		{name: "never fires", at: time.Time{}},
+		{name: "in the future", at: now.Add(time.Second)},
+		{name: "now", at: now, due: true},
+		{name: "within the grace period", at: now.Add(-scheduleMissedGrace), due: true},
+		{name: "missed", at: now.Add(-scheduleMissedGrace - time.Second), due: true, missed: true},
+		{name: "missed while suspended", at: now.Add(-8 * time.Hour), due: true, missed: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			due, missed := scheduleDue(tc.at, now)
+			if due != tc.due || missed != tc.missed {
+				t.Errorf("expected due=%v missed=%v, got due=%v missed=%v", tc.due, tc.missed, due, missed)
+			}
+		})
+	}
+}
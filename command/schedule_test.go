@@ -0,0 +1,237 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadTestRule loads a schedule for the given location with the single rule
+// in yaml, which is indented as a list item.
+func loadTestRule(t *testing.T, location, rule string) *scheduleRule {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), schedulesFileName)
+	contents := "location:\n  " + location + "\nrules:\n  - " + strings.ReplaceAll(strings.TrimSpace(rule), "\n", "\n    ") + "\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadSchedules(path)
+	if err != nil {
+		t.Fatalf("failed to load rule: %v", err)
+	}
+	return cfg.Rules[0]
+}
+
+const (
+	berlinLocation = "{latitude: 52.52, longitude: 13.40}"
+	tromsoLocation = "{latitude: 69.65, longitude: 18.96}"
+)
+
+func TestScheduleRule_Next(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	// Fire times are compared as wall clock times in Berlin.
+	cases := []struct {
+		name     string
+		rule     string
+		from     time.Time
+		expected []string
+	}{
+		{
+			name:     "time of day",
+			rule:     "at: \"08:55\"",
+			from:     time.Date(2024, time.October, 17, 9, 0, 0, 0, berlin),
+			expected: []string{"2024-10-18 08:55 CEST", "2024-10-19 08:55 CEST"},
+		},
+		{
+			name:     "weekdays",
+			rule:     "at: \"08:55\"\ndays: [weekdays]",
+			from:     time.Date(2024, time.October, 18, 9, 0, 0, 0, berlin),
+			expected: []string{"2024-10-21 08:55 CEST", "2024-10-22 08:55 CEST"},
+		},
+		{
+			name:     "days",
+			rule:     "at: \"20:00\"\ndays: [sun, wed]",
+			from:     time.Date(2024, time.October, 16, 20, 0, 0, 0, berlin),
+			expected: []string{"2024-10-20 20:00 CEST", "2024-10-23 20:00 CEST"},
+		},
+		{
+			// 02:30 does not exist when the clocks go forward, so the rule
+			// fires an hour later on that day.
+			name:     "daylight saving starts",
+			rule:     "at: \"02:30\"",
+			from:     time.Date(2024, time.March, 30, 12, 0, 0, 0, berlin),
+			expected: []string{"2024-03-31 03:30 CEST", "2024-04-01 02:30 CEST"},
+		},
+		{
+			// 02:30 happens twice when the clocks go back, but the rule only
+			// fires once.
+			name:     "daylight saving ends",
+			rule:     "at: \"02:30\"",
+			from:     time.Date(2024, time.October, 26, 12, 0, 0, 0, berlin),
+			expected: []string{"2024-10-27 02:30", "2024-10-28 02:30 CET"},
+		},
+		{
+			name:     "cron",
+			rule:     "cron: \"0 18 * * 5\"",
+			from:     time.Date(2024, time.October, 17, 9, 0, 0, 0, berlin),
+			expected: []string{"2024-10-18 18:00 CEST", "2024-10-25 18:00 CEST"},
+		},
+		{
+			name:     "cron across daylight saving",
+			rule:     "cron: \"0 7 * * *\"",
+			from:     time.Date(2024, time.October, 26, 9, 0, 0, 0, berlin),
+			expected: []string{"2024-10-27 07:00 CET", "2024-10-28 07:00 CET"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := loadTestRule(t, berlinLocation, tc.rule+"\nlights: [111A]\nswitch: \"on\"")
+
+			at := tc.from
+			for idx, want := range tc.expected {
+				at = rule.Next(at)
+				if got := at.In(berlin).Format("2006-01-02 15:04 MST"); !strings.HasPrefix(got, want) {
+					t.Fatalf("fire time %d: expected %s, got %s", idx, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleRule_NextSolar(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	oslo := mustLoadLocation(t, "Europe/Oslo")
+
+	cases := []struct {
+		name     string
+		location string
+		at       string
+		from     time.Time
+		expected time.Time
+	}{
+		{
+			name:     "sunset",
+			location: berlinLocation,
+			at:       "sunset",
+			from:     time.Date(2024, time.June, 21, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, time.June, 21, 21, 33, 0, 0, berlin),
+		},
+		{
+			name:     "sunset with a negative offset",
+			location: berlinLocation,
+			at:       "sunset-30m",
+			from:     time.Date(2024, time.June, 21, 12, 0, 0, 0, berlin),
+			expected: time.Date(2024, time.June, 21, 21, 3, 0, 0, berlin),
+		},
+		{
+			name:     "sunrise with a positive offset",
+			location: berlinLocation,
+			at:       "sunrise+1h",
+			from:     time.Date(2024, time.December, 21, 0, 0, 0, 0, berlin),
+			expected: time.Date(2024, time.December, 21, 9, 15, 0, 0, berlin),
+		},
+		{
+			// The offset moves the fire time before the start of the search,
+			// so the rule fires on the next day.
+			name:     "offset already passed",
+			location: berlinLocation,
+			at:       "sunset-30m",
+			from:     time.Date(2024, time.June, 21, 21, 10, 0, 0, berlin),
+			expected: time.Date(2024, time.June, 22, 21, 3, 0, 0, berlin),
+		},
+		{
+			// The first sunset after the midnight sun is just after
+			// midnight, so it is reported for the day before.
+			name:     "after polar day",
+			location: tromsoLocation,
+			at:       "sunset",
+			from:     time.Date(2024, time.June, 15, 12, 0, 0, 0, oslo),
+			expected: time.Date(2024, time.July, 27, 0, 21, 0, 0, oslo),
+		},
+		{
+			name:     "after polar night",
+			location: tromsoLocation,
+			at:       "sunrise",
+			from:     time.Date(2024, time.December, 1, 12, 0, 0, 0, oslo),
+			expected: time.Date(2025, time.January, 15, 11, 33, 0, 0, oslo),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := loadTestRule(t, tc.location, "at: "+tc.at+"\nall: true\nswitch: \"off\"")
+
+			at := rule.Next(tc.from)
+			expectNear(t, "fire time", at, tc.expected)
+		})
+	}
+}
+
+func TestDailyTrigger_NeverFires(t *testing.T) {
+	trigger := &dailyTrigger{
+		At: func(day time.Time) (time.Time, bool) {
+			return time.Time{}, false
+		},
+	}
+
+	if at := trigger.Next(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)); !at.IsZero() {
+		t.Errorf("expected the trigger not to fire, got %s", at)
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	location := &scheduleLocation{Latitude: 52.52, Longitude: 13.40}
+
+	valid := []string{"00:00", "8:05", "23:59", "sunrise", "sunset", "sunrise+30m", "sunset-1h30m"}
+	for _, at := range valid {
+		if _, err := parseTimeOfDay(at, location); err != nil {
+			t.Errorf("%s: unexpected error: %v", at, err)
+		}
+	}
+
+	invalid := []string{"", "8", "24:00", "12:60", "-1:00", "noon", "sunrise30m", "sunset+", "sunset+soon"}
+	for _, at := range invalid {
+		if _, err := parseTimeOfDay(at, location); err == nil {
+			t.Errorf("%s: expected an error", at)
+		}
+	}
+
+	if _, err := parseTimeOfDay("sunset", nil); err == nil || !strings.Contains(err.Error(), "requires a location") {
+		t.Errorf("expected an error about the missing location, got %v", err)
+	}
+}
+
+func TestLoadSchedules_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no trigger":         "lights: [111A]\nswitch: \"on\"",
+		"cron and at":        "cron: \"0 8 * * *\"\nat: \"08:00\"\nlights: [111A]\nswitch: \"on\"",
+		"days with cron":     "cron: \"0 8 * * *\"\ndays: [mon]\nlights: [111A]\nswitch: \"on\"",
+		"invalid cron":       "cron: \"0 8 * *\"\nlights: [111A]\nswitch: \"on\"",
+		"unknown day":        "at: \"08:00\"\ndays: [someday]\nlights: [111A]\nswitch: \"on\"",
+		"two actions":        "at: \"08:00\"\nlights: [111A]\nswitch: \"on\"\nscene: evening",
+		"invalid switch":     "at: \"08:00\"\nlights: [111A]\nswitch: dim",
+		"empty set":          "at: \"08:00\"\nlights: [111A]\nset: {}",
+		"no lights":          "at: \"08:00\"\nswitch: \"on\"",
+		"invalid brightness": "at: \"08:00\"\nlights: [111A]\nset: {brightness: \"200\"}",
+	}
+
+	for name, rule := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), schedulesFileName)
+			contents := "rules:\n  - " + strings.ReplaceAll(rule, "\n", "\n    ") + "\n"
+			if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := loadSchedules(path); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
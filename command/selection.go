@@ -0,0 +1,145 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// lightSelection holds the flags that are shared by every command that
+// operates on a selection of lights.
+type lightSelection struct {
+	Lights    lightListFlags
+	Groups    lightListFlags
+	AllLights bool
+
+	// AllowChannels permits selecting a single light of an accessory, e.g:
+	// 111A#1, for commands that can operate on individual lights.
+	AllowChannels bool
+
+	channels lightChannels
+}
+
+// AddFlags registers the -light, -group and -all flags on the given FlagSet.
+// The flags are marked as selection flags, so that they default to the
+// lights, groups and all settings of the config file.
+func (s *lightSelection) AddFlags(f *flag.FlagSet) {
+	f.Var(&s.Lights, "light", "")
+	f.Var(&s.Groups, "group", "")
+	f.BoolVar(&s.AllLights, "all", false, "")
+
+	for _, name := range []string{"light", "group", "all"} {
+		fl := f.Lookup(name)
+		fl.Value = selectionFlag{fl.Value}
+	}
+}
+
+// lightOptionUsage returns the help string for the -light option, with action
+// describing what the command does to the light, e.g: "Switch".
+func lightOptionUsage(action string) string {
+	helpText := `
+  -light <light-id-or-addr>
+    %s the provided light. Can either be a full key light name, e.g:
+    Elgato\ Key\ Light\ 111A, a short ID, e.g: 111A, a display name, e.g:
+    "Left key", a serial number, an address, e.g: 192.168.1.20, or a group
+    reference, e.g: @desk. Globs, e.g: Key*, and regular expressions, e.g:
+    /^Desk/, may match several lights, while every other selector must match
+    exactly one light. Addresses, and lights that are recorded in the
+    inventory while they are reachable, are used without discovery. -light
+    can be provided multiple times.
+`
+	return strings.TrimSpace(fmt.Sprintf(helpText, action))
+}
+
+// selectionFlag marks a flag that was registered by lightSelection. Commands
+// that define their own -light or -all flags, e.g: rename or inventory forget,
+// operate on a single light or are destructive, and so never pick up the
+// selection from the config file.
+type selectionFlag struct {
+	flag.Value
+}
+
+// IsBoolFlag allows -all to be provided without a value.
+func (s selectionFlag) IsBoolFlag() bool {
+	b, ok := s.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// isSelectionFlag returns whether the flag was registered by lightSelection.
+func isSelectionFlag(fl *flag.Flag) bool {
+	_, ok := fl.Value.(selectionFlag)
+	return ok
+}
+
+// Validate ensures that exactly one of -all or an explicit list of lights
+// was provided.
+func (s *lightSelection) Validate() error {
+	explicit := len(s.Lights) != 0 || len(s.Groups) != 0
+
+	if s.AllLights && explicit {
+		return errors.New("Cannot specify --all together with --light or --group")
+	}
+
+	if !s.AllLights && !explicit {
+		return errors.New("One of --all, --light or --group must be provided")
+	}
+
+	return nil
+}
+
+// Requested returns the requested lights with all group references expanded
+// to their members.
+func (s *lightSelection) Requested() (lightListFlags, error) {
+	requested := make(lightListFlags, 0, len(s.Lights)+len(s.Groups))
+	requested = append(requested, s.Lights...)
+	for _, group := range s.Groups {
+		requested = append(requested, groupReferencePrefix+group)
+	}
+
+	hasGroups := false
+	for _, light := range requested {
+		if isGroupReference(light) {
+			hasGroups = true
+			break
+		}
+	}
+	if !hasGroups {
+		return requested, nil
+	}
+
+	groups, err := loadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return groups.Expand(requested)
+}
+
+// Resolve expands the selection and resolves it to the matching lights.
+func (s *lightSelection) Resolve(ctx context.Context, ui cli.Ui) ([]*keylight.KeyLight, error) {
+	requested, err := s.Requested()
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := lightResolver{
+		UI:              ui,
+		RequestedLights: requested,
+		AllLights:       s.AllLights,
+		AllowChannels:   s.AllowChannels,
+	}
+
+	found, err := resolver.Resolve(ctx)
+	s.channels = resolver.Channels
+	return found, err
+}
+
+// Channels returns the lights of each resolved accessory that were selected.
+func (s *lightSelection) Channels() lightChannels {
+	return s.channels
+}
@@ -0,0 +1,219 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// lightIdentity holds the names that a user may use to refer to a light: its
+// mDNS instance name (and the short ID within it), the display name and serial
+// number that are configured on the device, and its address. Fields that are
+// not known are left empty.
+type lightIdentity struct {
+	Name         string
+	DisplayName  string
+	SerialNumber string
+	Address      string
+}
+
+// newLightIdentity returns the identity of the light that is known without
+// contacting it.
+func newLightIdentity(light *keylight.KeyLight) lightIdentity {
+	return lightIdentity{
+		Name:    light.Name,
+		Address: joinLightAddress(light.DNSAddr, light.Port),
+	}
+}
+
+// fetchLightIdentity returns the identity of the light, including the display
+// name and serial number from its accessory info if they can be retrieved.
+func fetchLightIdentity(ctx context.Context, light *keylight.KeyLight) lightIdentity {
+	identity := newLightIdentity(light)
+
+	ctx, cancelFn := context.WithTimeout(ctx, displayNameTimeout)
+	defer cancelFn()
+
+	info, err := light.FetchAccessoryInfo(ctx)
+	if err == nil {
+		identity.DisplayName = info.DisplayName
+		identity.SerialNumber = info.SerialNumber
+	}
+	return identity
+}
+
+// String returns a human readable description of the light for listing
+// candidates, e.g: `Elgato Key Light 111A (Left key)`.
+func (i lightIdentity) String() string {
+	label := unescapeLightName(i.Name)
+	if label == "" {
+		label = i.Address
+	}
+	if i.DisplayName != "" {
+		label = fmt.Sprintf("%s (%s)", label, i.DisplayName)
+	}
+	return label
+}
+
+// fields returns every name of the light that selectors are matched against.
+func (i lightIdentity) fields() []string {
+	var fields []string
+	for _, f := range []string{unescapeLightName(i.Name), lightShortID(i.Name), i.DisplayName, i.SerialNumber, i.Address} {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// unescapeLightName removes the escaping of spaces in mDNS instance names, e.g:
+// `Elgato\ Key\ Light\ 111A` => `Elgato Key Light 111A`.
+func unescapeLightName(name string) string {
+	return strings.ReplaceAll(name, `\ `, " ")
+}
+
+// lightSelector is a parsed light requirement, as provided to -light. It is
+// matched against the full name, short ID, display name, serial number and
+// address of each light, ignoring case:
+//
+//	Elgato\ Key\ Light\ 111A    the full name, with or without escaping
+//	111A                        a short ID, display name or serial number
+//	192.168.1.*                 a glob over addresses
+//	Key*                        a glob
+//	/^Desk (left|right)$/       a regular expression
+//
+// Exact selectors must identify a single light, while globs and regular
+// expressions may match any number of lights.
+type lightSelector struct {
+	raw   string
+	exact string
+
+	// pattern is nil for exact selectors.
+	pattern func(value string) bool
+}
+
+// parseLightSelector parses a single light requirement.
+func parseLightSelector(req string) (*lightSelector, error) {
+	s := &lightSelector{raw: req}
+
+	switch {
+	case len(req) > 2 && strings.HasPrefix(req, "/") && strings.HasSuffix(req, "/"):
+		re, err := regexp.Compile("(?i)" + req[1:len(req)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid light selector '%s', err: %w", req, err)
+		}
+		s.pattern = re.MatchString
+	case strings.ContainsAny(req, "*?["):
+		glob := strings.ToLower(unescapeLightName(req))
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid light selector '%s', err: %w", req, err)
+		}
+		s.pattern = func(value string) bool {
+			ok, _ := path.Match(glob, strings.ToLower(value))
+			return ok
+		}
+	default:
+		s.exact = unescapeLightName(req)
+	}
+
+	return s, nil
+}
+
+// parseLightSelectors parses every light requirement in reqs.
+func parseLightSelectors(reqs []string) ([]*lightSelector, error) {
+	var result []*lightSelector
+	for _, req := range reqs {
+		s, err := parseLightSelector(req)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+func (s *lightSelector) String() string {
+	return s.raw
+}
+
+// IsPattern returns whether the selector is a glob or regular expression, and
+// so may match several lights.
+func (s *lightSelector) IsPattern() bool {
+	return s.pattern != nil
+}
+
+// Matches returns whether the light satisfies the selector.
+func (s *lightSelector) Matches(identity lightIdentity) bool {
+	for _, f := range identity.fields() {
+		if s.pattern != nil {
+			if s.pattern(f) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(f, s.exact) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesName returns whether an exact selector matches the full name or short
+// ID of the light, which are derived from its MAC address and so are not
+// shared with other lights, unlike display names and serial numbers which are
+// configured on each device.
+func (s *lightSelector) MatchesName(identity lightIdentity) bool {
+	if s.pattern != nil {
+		return false
+	}
+	for _, f := range []string{unescapeLightName(identity.Name), lightShortID(identity.Name)} {
+		if f != "" && strings.EqualFold(f, s.exact) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if the selector matched no lights, or if an exact
+// selector matched more than one light.
+func (s *lightSelector) Check(matches []lightIdentity) error {
+	if len(matches) == 0 {
+		return &noLightFoundError{Selector: s.raw}
+	}
+	if s.IsPattern() || len(matches) == 1 {
+		return nil
+	}
+
+	var candidates []string
+	for _, m := range matches {
+		candidates = append(candidates, m.String())
+	}
+	sort.Strings(candidates)
+	return &ambiguousSelectorError{Selector: s.raw, Candidates: candidates}
+}
+
+// noLightFoundError is returned when a selector matched no lights.
+type noLightFoundError struct {
+	Selector string
+}
+
+func (e *noLightFoundError) Error() string {
+	return fmt.Sprintf("no light found for requirement '%s'", e.Selector)
+}
+
+// ambiguousSelectorError is returned when an exact selector matches more than
+// one light, rather than silently controlling all of them.
+type ambiguousSelectorError struct {
+	Selector   string
+	Candidates []string
+}
+
+func (e *ambiguousSelectorError) Error() string {
+	return fmt.Sprintf("'%s' matches %d lights, use a full name, serial number or address to select one of: %s",
+		e.Selector, len(e.Candidates), strings.Join(e.Candidates, ", "))
+}
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLightSelector_Matches(t *testing.T) {
+	identity := lightIdentity{
+		Name:         `Elgato\ Key\ Light\ 111A`,
+		DisplayName:  "Left key",
+		SerialNumber: "BW33J1A02740",
+		Address:      "192.168.1.20:9123",
+	}
+
+	cases := []struct {
+		selector string
+		matches  bool
+		pattern  bool
+	}{
+		{selector: `Elgato\ Key\ Light\ 111A`, matches: true},
+		{selector: "Elgato Key Light 111A", matches: true},
+		{selector: "elgato key light 111a", matches: true},
+		{selector: "111A", matches: true},
+		{selector: "111a", matches: true},
+		{selector: "Left key", matches: true},
+		{selector: "LEFT KEY", matches: true},
+		{selector: "BW33J1A02740", matches: true},
+		{selector: "192.168.1.20:9123", matches: true},
+		{selector: "111", matches: false},
+		{selector: "Left", matches: false},
+		{selector: "Left*", matches: true, pattern: true},
+		{selector: `Elgato\ Key*`, matches: true, pattern: true},
+		{selector: "192.168.1.*", matches: true, pattern: true},
+		{selector: "*111?", matches: true, pattern: true},
+		{selector: "Right*", matches: false, pattern: true},
+		{selector: "/^left/", matches: true, pattern: true},
+		{selector: "/^Desk (left|right)$/", matches: false, pattern: true},
+		{selector: "/a02740$/", matches: true, pattern: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.selector, func(t *testing.T) {
+			s, err := parseLightSelector(tc.selector)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.IsPattern() != tc.pattern {
+				t.Errorf("expected IsPattern to be %v", tc.pattern)
+			}
+			if got := s.Matches(identity); got != tc.matches {
+				t.Errorf("expected Matches to be %v, got %v", tc.matches, got)
+			}
+		})
+	}
+}
+
+func TestLightSelector_MatchesName(t *testing.T) {
+	identity := lightIdentity{
+		Name:         `Elgato\ Key\ Light\ 111A`,
+		DisplayName:  "Left key",
+		SerialNumber: "BW33J1A02740",
+		Address:      "192.168.1.20:9123",
+	}
+
+	cases := map[string]bool{
+		`Elgato\ Key\ Light\ 111A`: true,
+		"111a":                     true,
+		"Left key":                 false,
+		"BW33J1A02740":             false,
+		"Key*":                     false,
+	}
+
+	for selector, expected := range cases {
+		s, err := parseLightSelector(selector)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := s.MatchesName(identity); got != expected {
+			t.Errorf("%s: expected MatchesName to be %v, got %v", selector, expected, got)
+		}
+	}
+}
+
+func TestParseLightSelector_Invalid(t *testing.T) {
+	for _, selector := range []string{"/(/", "[a"} {
+		if _, err := parseLightSelector(selector); err == nil {
+			t.Errorf("%s: expected an error", selector)
+		}
+	}
+}
+
+func TestLightSelector_Check(t *testing.T) {
+	left := lightIdentity{Name: `Elgato\ Key\ Light\ 111A`, DisplayName: "Desk"}
+	right := lightIdentity{Name: `Elgato\ Key\ Light\ 222B`, DisplayName: "Desk"}
+
+	cases := []struct {
+		name      string
+		selector  string
+		matches   []lightIdentity
+		err       bool
+		ambiguous bool
+	}{
+		{name: "single exact match", selector: "111A", matches: []lightIdentity{left}},
+		{name: "no match", selector: "333C", err: true},
+		{name: "ambiguous exact match", selector: "Desk", matches: []lightIdentity{left, right}, err: true, ambiguous: true},
+		{name: "pattern with several matches", selector: "Elgato*", matches: []lightIdentity{left, right}},
+		{name: "pattern without matches", selector: "/^Desk$/", err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := parseLightSelector(tc.selector)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = s.Check(tc.matches)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error to be %v, got %v", tc.err, err)
+			}
+
+			var ambiguous *ambiguousSelectorError
+			if errors.As(err, &ambiguous) != tc.ambiguous {
+				t.Fatalf("expected ambiguous to be %v, got %v", tc.ambiguous, err)
+			}
+			if ambiguous != nil {
+				expected := []string{"Elgato Key Light 111A (Desk)", "Elgato Key Light 222B (Desk)"}
+				if len(ambiguous.Candidates) != 2 || ambiguous.Candidates[0] != expected[0] || ambiguous.Candidates[1] != expected[1] {
+					t.Errorf("expected candidates %v, got %v", expected, ambiguous.Candidates)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type SetCommand struct {
+	Meta
+}
+
+func (c *SetCommand) Help() string {
+	helpText := `
+Usage: keylightctl set [options]
+
+ Change the brightness and/or temperature of keylights without changing
+ whether they are switched on or off.
+
+ For accessories that expose several lights, each of -brightness,
+ -temperature and -kelvin accepts a comma separated list with a value for
+ each selected light of the accessory, in order, e.g: -brightness 20,80. A
+ single light can be selected with -light <light>#<index>, e.g: 111A#1.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Set Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Modify all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Modify") + `
+
+  -group <group>
+    Modify all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -brightness <brightness>
+    Set the brightness to the given percentage (3-100). Prefix the value with
+    + or - to change the brightness relative to its current value, e.g: +10.
+
+  -temperature <temperature>
+    Set the temperature to the given value (143-344). Prefix the value with
+    + or - to change the temperature relative to its current value, e.g: -5.
+
+  -kelvin <kelvin>
+    Set the temperature to the given value in Kelvin, e.g: 4500 or 4500K.
+    Prefix the value with + or - to change the temperature relative to its
+    current value, e.g: +500K. Values outside of the range supported by the
+    light are clamped. Cannot be combined with -temperature.
+
+  ` + fanOutOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SetCommand) Synopsis() string {
+	return "Adjust keylight brightness and temperature"
+}
+
+func (f *SetCommand) Name() string { return "set" }
+
+func (c *SetCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var selection lightSelection
+	var fanOut lightFanOut
+	var brightness, temperature lightAdjustments
+	var kelvin kelvinAdjustments
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	selection.AllowChannels = true
+	fanOut.AddFlags(flags)
+	flags.Var(&brightness, "brightness", "")
+	flags.Var(&temperature, "temperature", "")
+	flags.Var(&kelvin, "kelvin", "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if !brightness.IsSet() && !temperature.IsSet() && !kelvin.IsSet() {
+		c.UI.Error("At least one of --brightness, --temperature and --kelvin must be provided")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if temperature.IsSet() && kelvin.IsSet() {
+		c.UI.Error("Cannot specify --temperature and --kelvin together")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := brightness.Validate("Brightness", minBrightness, maxBrightness); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := temperature.Validate("Temperature", minTemperature, maxTemperature); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer updateCancelFn()
+
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		return modifyLightChannels(ctx, light, selection.Channels().Get(light), func(lights []*keylight.KeyLightLight) error {
+			if err := checkChannelValues("--brightness", len(brightness), len(lights)); err != nil {
+				return err
+			}
+			if err := checkChannelValues("--temperature", len(temperature), len(lights)); err != nil {
+				return err
+			}
+			if err := checkChannelValues("--kelvin", len(kelvin), len(lights)); err != nil {
+				return err
+			}
+
+			for idx, l := range lights {
+				l.Brightness = brightness.At(idx).Apply(l.Brightness, minBrightness, maxBrightness)
+				l.Temperature = temperature.At(idx).Apply(l.Temperature, minTemperature, maxTemperature)
+				l.Temperature = kelvin.At(idx).Apply(l.Temperature)
+			}
+			return nil
+		})
+	})
+
+	return reportResults(c.UI, results)
+}
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestSetCommand(t *testing.T) {
+	cases := []struct {
+		name     string
+		lights   int
+		args     []string
+		expected []keylight.KeyLightLight
+	}{
+		{
+			name:     "absolute",
+			lights:   1,
+			args:     []string{"-brightness", "75", "-temperature", "300"},
+			expected: []keylight.KeyLightLight{{Brightness: 75, Temperature: 300}},
+		},
+		{
+			name:     "relative",
+			lights:   1,
+			args:     []string{"-brightness", "+10", "-temperature", "-13"},
+			expected: []keylight.KeyLightLight{{Brightness: 30, Temperature: 200}},
+		},
+		{
+			name:     "kelvin",
+			lights:   1,
+			args:     []string{"-kelvin", "5000K"},
+			expected: []keylight.KeyLightLight{{Brightness: 20, Temperature: 200}},
+		},
+		{
+			name:     "relative kelvin",
+			lights:   1,
+			args:     []string{"-kelvin", "+305K"},
+			expected: []keylight.KeyLightLight{{Brightness: 20, Temperature: 200}},
+		},
+		{
+			name:     "clamped",
+			lights:   1,
+			args:     []string{"-brightness", "+500"},
+			expected: []keylight.KeyLightLight{{Brightness: 100, Temperature: 213}},
+		},
+		{
+			name:     "per channel",
+			lights:   2,
+			args:     []string{"-brightness", "20,80"},
+			expected: []keylight.KeyLightLight{{Brightness: 20, Temperature: 213}, {Brightness: 80, Temperature: 213}},
+		},
+		{
+			name:     "single value for every channel",
+			lights:   2,
+			args:     []string{"-brightness", "50"},
+			expected: []keylight.KeyLightLight{{Brightness: 50, Temperature: 213}, {Brightness: 50, Temperature: 213}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTestConfig(t)
+			sim, addr := newTestLight(t, simulator.Config{Lights: tc.lights})
+
+			ui := cli.NewMockUi()
+			cmd := &SetCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 0, append([]string{"-light", addr}, tc.args...)...)
+
+			for idx, want := range tc.expected {
+				if got := *sim.Options().Lights[idx]; got != want {
+					t.Errorf("light %d: expected %+v, got %+v", idx, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSetCommand_Channel(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	cmd := &SetCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 0, "-light", addr+"#1", "-brightness", "90")
+
+	if opts := sim.Options(); opts.Lights[0].Brightness != 20 || opts.Lights[1].Brightness != 90 {
+		t.Errorf("expected only the second light to change, got %+v, %+v", opts.Lights[0], opts.Lights[1])
+	}
+}
+
+func TestSetCommand_ChannelCountMismatch(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	cmd := &SetCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 1, "-light", addr, "-brightness", "20,40,60")
+
+	for idx, l := range sim.Options().Lights {
+		if l.Brightness != 20 {
+			t.Errorf("light %d: expected brightness to be unchanged, got %+v", idx, l)
+		}
+	}
+}
+
+func TestSetCommand_RequiresAdjustment(t *testing.T) {
+	setupTestConfig(t)
+
+	ui := cli.NewMockUi()
+	cmd := &SetCommand{Meta: Meta{UI: ui}}
+	runCommand(t, cmd, ui, 1, "-light", "111A")
+}
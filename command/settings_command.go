@@ -0,0 +1,43 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+type SettingsCommand struct {
+	Meta
+}
+
+func (c *SettingsCommand) Help() string {
+	helpText := `
+Usage: keylightctl settings <subcommand> [options] [args]
+
+ This command groups subcommands for reading and changing the device settings
+ of keylights, such as how they behave when power is restored and how long
+ they take to fade between states.
+
+ Show the settings of every light:
+
+     $ keylightctl settings get -all
+
+ Make the desk lights come back at 30% and 5000K after a power cut:
+
+     $ keylightctl settings set -group desk -power-on-brightness 30 \
+         -power-on-kelvin 5000
+
+ Please see the individual subcommand help for detailed usage information.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SettingsCommand) Synopsis() string {
+	return "Read and change keylight device settings"
+}
+
+func (f *SettingsCommand) Name() string { return "settings" }
+
+func (c *SettingsCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
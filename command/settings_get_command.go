@@ -0,0 +1,154 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type SettingsGetCommand struct {
+	Meta
+}
+
+func (c *SettingsGetCommand) Help() string {
+	helpText := `
+Usage: keylightctl settings get [options]
+
+ Show the device settings of the selected keylights.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Settings Get Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Show all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Show") + `
+
+  -group <group>
+    Show all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  ` + fanOutOptionsUsage() + `
+
+  ` + formatOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SettingsGetCommand) Synopsis() string {
+	return "Show keylight device settings"
+}
+
+func (f *SettingsGetCommand) Name() string { return "settings get" }
+
+func (c *SettingsGetCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var selection lightSelection
+	var fanOut lightFanOut
+	var output recordWriter
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	fanOut.AddFlags(flags)
+	output.AddFlags(flags)
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := output.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	fetchCtx, fetchCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer fetchCancelFn()
+
+	sort.Slice(found, func(i, j int) bool {
+		return lightLabel(found[i]) < lightLabel(found[j])
+	})
+
+	settings := make([]*keylight.KeyLightSettings, len(found))
+	results := fanOut.Run(fetchCtx, found, func(ctx context.Context, idx int, light *keylight.KeyLight) error {
+		s, err := light.FetchSettings(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch settings, err: %w", err)
+		}
+		settings[idx] = s
+		return nil
+	})
+
+	var records []lightSettingsRecord
+	for idx, light := range found {
+		if settings[idx] != nil {
+			records = append(records, newLightSettingsRecord(light, settings[idx]))
+		}
+	}
+
+	if !output.IsTable() {
+		if err := output.Write(os.Stdout, records); err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to write output, err: %v", err))
+			return 1
+		}
+		return reportFetchErrors(c.UI, results)
+	}
+
+	renderSettingsTable(records, "")
+
+	return reportFetchErrors(c.UI, results)
+}
@@ -0,0 +1,37 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestSettingsGetCommand_JSON(t *testing.T) {
+	setupTestConfig(t)
+	_, addr := newTestLight(t, simulator.Config{SerialNumber: "BW33J1A02740"})
+
+	ui := cli.NewMockUi()
+	cmd := &SettingsGetCommand{Meta: Meta{UI: ui}}
+
+	out := captureStdout(t, func() {
+		runCommand(t, cmd, ui, 0, "-light", addr, "-format", "json")
+	})
+
+	var records []lightSettingsRecord
+	if err := json.Unmarshal([]byte(out), &records); err != nil {
+		t.Fatalf("failed to decode output %q: %v", out, err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected a single record, got %d", len(records))
+	}
+
+	record := records[0]
+	if record.Name != `Elgato\ Key\ Light\ BW33J1A02740` || record.PowerOnBehavior != 1 || record.PowerOnBrightness != 20 || record.PowerOnTemperature != 213 {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if record.SwitchOnDurationMs != 100 || record.SwitchOffDurationMs != 300 || record.ColorChangeDurationMs != 100 {
+		t.Errorf("unexpected durations %+v", record)
+	}
+}
@@ -0,0 +1,206 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+type SettingsSetCommand struct {
+	Meta
+}
+
+func (c *SettingsSetCommand) Help() string {
+	helpText := `
+Usage: keylightctl settings set [options]
+
+ Change the device settings of the selected keylights. Settings that are not
+ provided are left unchanged.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Settings Set Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories (default: 5s)
+
+  -all
+    Modify all keylights that are discovered within the timeout window
+
+  ` + lightOptionUsage("Modify") + `
+
+  -group <group>
+    Modify all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -power-on-behavior <behavior>
+    Sets how the light behaves when power is restored, as the raw device value.
+
+  -power-on-brightness <brightness>
+    Sets the brightness percentage (3-100) used when power is restored.
+
+  -power-on-temperature <temperature>
+    Sets the temperature (143-344) used when power is restored.
+
+  -power-on-kelvin <kelvin>
+    Sets the temperature used when power is restored in Kelvin, e.g: 5000K.
+    Cannot be combined with -power-on-temperature.
+
+  -switch-on-duration <duration>
+    Sets how long the light takes to fade in when switched on, e.g: 500ms.
+
+  -switch-off-duration <duration>
+    Sets how long the light takes to fade out when switched off, e.g: 1s.
+
+  -color-change-duration <duration>
+    Sets how long the light takes to change brightness or temperature.
+
+  ` + fanOutOptionsUsage() + `
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SettingsSetCommand) Synopsis() string {
+	return "Change keylight device settings"
+}
+
+func (f *SettingsSetCommand) Name() string { return "settings set" }
+
+func (c *SettingsSetCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout time.Duration
+	var selection lightSelection
+	var fanOut lightFanOut
+	var powerOnBehavior, powerOnBrightness, powerOnTemperature int
+	var powerOnKelvin kelvinAdjustment
+	var switchOnDuration, switchOffDuration, colorChangeDuration time.Duration
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	fanOut.AddFlags(flags)
+	flags.IntVar(&powerOnBehavior, "power-on-behavior", -1, "")
+	flags.IntVar(&powerOnBrightness, "power-on-brightness", -1, "")
+	flags.IntVar(&powerOnTemperature, "power-on-temperature", -1, "")
+	flags.Var(&powerOnKelvin, "power-on-kelvin", "")
+	flags.DurationVar(&switchOnDuration, "switch-on-duration", -1, "")
+	flags.DurationVar(&switchOffDuration, "switch-off-duration", -1, "")
+	flags.DurationVar(&colorChangeDuration, "color-change-duration", -1, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if l := len(args); l != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if powerOnBehavior < 0 && powerOnBrightness < 0 && powerOnTemperature < 0 && !powerOnKelvin.IsSet &&
+		switchOnDuration < 0 && switchOffDuration < 0 && colorChangeDuration < 0 {
+		c.UI.Error("At least one setting must be provided")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if powerOnTemperature >= 0 && powerOnKelvin.IsSet {
+		c.UI.Error("Cannot specify --power-on-temperature and --power-on-kelvin together")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if powerOnKelvin.Relative {
+		c.UI.Error("--power-on-kelvin must be an absolute temperature")
+		return 1
+	}
+
+	if powerOnBrightness >= 0 && (powerOnBrightness < minBrightness || powerOnBrightness > maxBrightness) {
+		c.UI.Error(fmt.Sprintf("Power on brightness must be between %d and %d", minBrightness, maxBrightness))
+		return 1
+	}
+
+	if powerOnTemperature >= 0 && (powerOnTemperature < minTemperature || powerOnTemperature > maxTemperature) {
+		c.UI.Error(fmt.Sprintf("Power on temperature must be between %d and %d", minTemperature, maxTemperature))
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
+	defer updateCancelFn()
+
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		settings, err := light.FetchSettings(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch settings, err: %w", err)
+		}
+
+		if powerOnBehavior >= 0 {
+			settings.PowerOnBehavior = powerOnBehavior
+		}
+		if powerOnBrightness >= 0 {
+			settings.PowerOnBrightness = powerOnBrightness
+		}
+		if powerOnTemperature >= 0 {
+			settings.PowerOnTemperature = powerOnTemperature
+		}
+		settings.PowerOnTemperature = powerOnKelvin.Apply(settings.PowerOnTemperature)
+		if switchOnDuration >= 0 {
+			settings.SwitchOnDurationMs = int(switchOnDuration.Milliseconds())
+		}
+		if switchOffDuration >= 0 {
+			settings.SwitchOffDurationMs = int(switchOffDuration.Milliseconds())
+		}
+		if colorChangeDuration >= 0 {
+			settings.ColorChangeDurationMs = int(colorChangeDuration.Milliseconds())
+		}
+
+		_, err = updateLightSettings(ctx, light, settings)
+		if err != nil {
+			return fmt.Errorf("failed to update settings, err: %w", err)
+		}
+
+		return nil
+	})
+
+	return reportResults(c.UI, results)
+}
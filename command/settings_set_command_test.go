@@ -0,0 +1,82 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestSettingsSetCommand(t *testing.T) {
+	// The simulator starts with power on behavior 1 at 20% and 213 mireds,
+	// and switches on in 100ms, off in 300ms and changes color in 100ms.
+	cases := []struct {
+		name     string
+		args     []string
+		expected keylight.KeyLightSettings
+	}{
+		{
+			name:     "single setting",
+			args:     []string{"-power-on-brightness", "60"},
+			expected: keylight.KeyLightSettings{PowerOnBehavior: 1, PowerOnBrightness: 60, PowerOnTemperature: 213, SwitchOnDurationMs: 100, SwitchOffDurationMs: 300, ColorChangeDurationMs: 100},
+		},
+		{
+			name:     "kelvin",
+			args:     []string{"-power-on-kelvin", "5000K"},
+			expected: keylight.KeyLightSettings{PowerOnBehavior: 1, PowerOnBrightness: 20, PowerOnTemperature: 200, SwitchOnDurationMs: 100, SwitchOffDurationMs: 300, ColorChangeDurationMs: 100},
+		},
+		{
+			name:     "durations",
+			args:     []string{"-switch-on-duration", "1s", "-switch-off-duration", "0s", "-color-change-duration", "250ms"},
+			expected: keylight.KeyLightSettings{PowerOnBehavior: 1, PowerOnBrightness: 20, PowerOnTemperature: 213, SwitchOnDurationMs: 1000, SwitchOffDurationMs: 0, ColorChangeDurationMs: 250},
+		},
+		{
+			name:     "everything",
+			args:     []string{"-power-on-behavior", "2", "-power-on-brightness", "3", "-power-on-temperature", "344", "-switch-on-duration", "200ms", "-switch-off-duration", "400ms", "-color-change-duration", "50ms"},
+			expected: keylight.KeyLightSettings{PowerOnBehavior: 2, PowerOnBrightness: 3, PowerOnTemperature: 344, SwitchOnDurationMs: 200, SwitchOffDurationMs: 400, ColorChangeDurationMs: 50},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTestConfig(t)
+			sim, addr := newTestLight(t, simulator.Config{})
+
+			ui := cli.NewMockUi()
+			cmd := &SettingsSetCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 0, append([]string{"-light", addr}, tc.args...)...)
+
+			if settings := sim.Settings(); settings != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, settings)
+			}
+		})
+	}
+}
+
+func TestSettingsSetCommand_Invalid(t *testing.T) {
+	cases := map[string][]string{
+		"no settings":             {},
+		"temperature and kelvin":  {"-power-on-temperature", "200", "-power-on-kelvin", "5000K"},
+		"relative kelvin":         {"-power-on-kelvin", "+500K"},
+		"brightness out of range": {"-power-on-brightness", "101"},
+		"temperature too low":     {"-power-on-temperature", "100"},
+		"arguments":               {"-power-on-brightness", "60", "on"},
+	}
+
+	for name, args := range cases {
+		t.Run(name, func(t *testing.T) {
+			setupTestConfig(t)
+			sim, addr := newTestLight(t, simulator.Config{})
+			initial := sim.Settings()
+
+			ui := cli.NewMockUi()
+			cmd := &SettingsSetCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 1, append([]string{"-light", addr}, args...)...)
+
+			if settings := sim.Settings(); settings != initial {
+				t.Errorf("expected the settings to be unchanged, got %+v", settings)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+// defaultSimulateListenAddr is the address of the first simulated light,
+// using the port of the real accessories.
+const defaultSimulateListenAddr = ":9123"
+
+type SimulateCommand struct {
+	Meta
+}
+
+func (c *SimulateCommand) Help() string {
+	helpText := `
+Usage: keylightctl simulate [options]
+
+ Simulate one or more Elgato Key Lights until interrupted. Each simulated
+ light serves the Elgato HTTP API and is advertised over mDNS, so that every
+ keylightctl command can be used without any lights, e.g:
+
+     $ keylightctl simulate -count 2
+     $ keylightctl switch -all on
+
+ Simulated lights are named Elgato Key Light SIM1, SIM2, and so on, and when
+ -count is greater than 1 they listen on consecutive ports. Values outside of
+ the ranges supported by the lights are clamped, like they are by the real
+ accessories.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Simulate Specific Options:
+
+  -listen <addr>
+    The address of the first simulated light (default: :9123)
+
+  -count <count>
+    The number of lights to simulate (default: 1)
+
+  -lights <count>
+    The number of lights in each simulated accessory (default: 1)
+
+  -latency <duration>
+    Adds the given delay to every request (default: 0s)
+
+  -failure-rate <rate>
+    The fraction of requests, between 0 and 1, that fail with an internal
+    server error (default: 0)
+
+  -advertise
+    Advertise the simulated lights over mDNS (default: true)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (f *SimulateCommand) Synopsis() string {
+	return "Simulate keylights for development and testing"
+}
+
+func (f *SimulateCommand) Name() string { return "simulate" }
+
+func (c *SimulateCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var listenAddr string
+	var count, lights int
+	var latency time.Duration
+	var failureRate float64
+	var advertise bool
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.StringVar(&listenAddr, "listen", defaultSimulateListenAddr, "")
+	flags.IntVar(&count, "count", 1, "")
+	flags.IntVar(&lights, "lights", 1, "")
+	flags.DurationVar(&latency, "latency", 0, "")
+	flags.Float64Var(&failureRate, "failure-rate", 0, "")
+	flags.BoolVar(&advertise, "advertise", true, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if count < 1 || lights < 1 {
+		c.UI.Error("--count and --lights must be at least 1")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if latency < 0 || failureRate < 0 || failureRate > 1 {
+		c.UI.Error("--latency must not be negative and --failure-rate must be between 0 and 1")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	host, portStr, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid --listen address '%s', err: %v", listenAddr, err))
+		return 1
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Invalid --listen address '%s', err: %v", listenAddr, err))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErrCh := make(chan error, count)
+	for i := 1; i <= count; i++ {
+		shortID := fmt.Sprintf("SIM%d", i)
+		name := "Elgato Key Light " + shortID
+
+		sim := simulator.New(simulator.Config{
+			SerialNumber: fmt.Sprintf("SIM%09d", i),
+			Lights:       lights,
+			Latency:      latency,
+			FailureRate:  failureRate,
+		})
+		sim.OnRequest = func(r *http.Request, status int) {
+			c.UI.Output(fmt.Sprintf("%s: %s %s %d", shortID, r.Method, r.URL.Path, status))
+		}
+
+		// Each light listens on the next port, unless ports are chosen by the
+		// system.
+		addr := listenAddr
+		if port != 0 {
+			addr = net.JoinHostPort(host, strconv.Itoa(port+i-1))
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Failed to listen on %s, err: %v", addr, err))
+			return 1
+		}
+
+		server := &http.Server{Handler: sim}
+		go func() {
+			serveErrCh <- server.Serve(listener)
+		}()
+		defer server.Close()
+
+		c.UI.Output(fmt.Sprintf("Simulating %s on %s", name, listener.Addr()))
+
+		if advertise {
+			shutdown, err := sim.Advertise(name, listener.Addr().(*net.TCPAddr).Port)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Failed to advertise %s, err: %v", name, err))
+				return 1
+			}
+			defer shutdown()
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0
+	case err := <-serveErrCh:
+		c.UI.Error(fmt.Sprintf("Failed to serve simulated light, err: %v", err))
+		return 1
+	}
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// julianUnixEpoch is the Julian date of the Unix epoch, and julian2000 is
+	// the Julian date of the J2000 epoch.
+	julianUnixEpoch = 2440587.5
+	julian2000      = 2451545.0
+
+	// sunriseAltitude is the altitude of the center of the sun at sunrise and
+	// sunset, in degrees, accounting for atmospheric refraction and the
+	// radius of the sun.
+	sunriseAltitude = -0.833
+
+	// earthObliquity is the axial tilt of the earth, in degrees.
+	earthObliquity = 23.4397
+)
+
+// sunTimes returns the time of sunrise and sunset on the given day at the
+// given location, using the sunrise equation. Latitude is positive north of
+// the equator and longitude is positive east of Greenwich. ok is false on days
+// where the sun does not rise or set, e.g. during polar day or night.
+func sunTimes(day time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	n := math.Ceil(toJulian(midnight) - julian2000 + 0.0008)
+
+	// Mean solar noon, solar mean anomaly and the equation of the center.
+	meanNoon := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*meanNoon, 360)
+	center := 1.9148*sinDeg(anomaly) + 0.02*sinDeg(2*anomaly) + 0.0003*sinDeg(3*anomaly)
+
+	// Ecliptic longitude, solar transit and declination of the sun.
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := julian2000 + meanNoon + 0.0053*sinDeg(anomaly) - 0.0069*sinDeg(2*ecliptic)
+	declination := math.Asin(sinDeg(ecliptic) * sinDeg(earthObliquity))
+
+	cosHourAngle := (sinDeg(sunriseAltitude) - sinDeg(latitude)*math.Sin(declination)) /
+		(cosDeg(latitude) * math.Cos(declination))
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	hourAngle := math.Acos(cosHourAngle) * 180 / math.Pi
+	sunrise = fromJulian(transit - hourAngle/360).In(day.Location())
+	sunset = fromJulian(transit + hourAngle/360).In(day.Location())
+	return sunrise, sunset, true
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(0, int64((j-julianUnixEpoch)*86400*float64(time.Second))).UTC()
+}
+
+func sinDeg(deg float64) float64 {
+	return math.Sin(deg * math.Pi / 180)
+}
+
+func cosDeg(deg float64) float64 {
+	return math.Cos(deg * math.Pi / 180)
+}
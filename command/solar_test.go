@@ -0,0 +1,114 @@
+package command
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// mustLoadLocation returns the named time zone, failing the test if it is
+// unknown.
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load location %s: %v", name, err)
+	}
+	return loc
+}
+
+// expectNear fails the test if got is more than two minutes from want, which
+// is well within the accuracy of the sunrise equation.
+func expectNear(t *testing.T, label string, got, want time.Time) {
+	t.Helper()
+
+	diff := got.Sub(want)
+	if diff < -2*time.Minute || diff > 2*time.Minute {
+		t.Errorf("%s: expected about %s, got %s", label, want, got)
+	}
+}
+
+func TestSunTimes(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	losAngeles := mustLoadLocation(t, "America/Los_Angeles")
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+
+	cases := []struct {
+		name      string
+		day       time.Time
+		latitude  float64
+		longitude float64
+		sunrise   time.Time
+		sunset    time.Time
+	}{
+		{
+			name:      "berlin summer solstice",
+			day:       time.Date(2024, time.June, 21, 0, 0, 0, 0, berlin),
+			latitude:  52.52,
+			longitude: 13.40,
+			sunrise:   time.Date(2024, time.June, 21, 4, 43, 0, 0, berlin),
+			sunset:    time.Date(2024, time.June, 21, 21, 33, 0, 0, berlin),
+		},
+		{
+			name:      "berlin winter solstice",
+			day:       time.Date(2024, time.December, 21, 0, 0, 0, 0, berlin),
+			latitude:  52.52,
+			longitude: 13.40,
+			sunrise:   time.Date(2024, time.December, 21, 8, 15, 0, 0, berlin),
+			sunset:    time.Date(2024, time.December, 21, 15, 54, 0, 0, berlin),
+		},
+		{
+			name:      "berlin daylight saving starts",
+			day:       time.Date(2024, time.March, 31, 0, 0, 0, 0, berlin),
+			latitude:  52.52,
+			longitude: 13.40,
+			sunrise:   time.Date(2024, time.March, 31, 6, 42, 0, 0, berlin),
+			sunset:    time.Date(2024, time.March, 31, 19, 38, 0, 0, berlin),
+		},
+		{
+			name:      "los angeles daylight saving ends",
+			day:       time.Date(2024, time.November, 3, 0, 0, 0, 0, losAngeles),
+			latitude:  34.05,
+			longitude: -118.24,
+			sunrise:   time.Date(2024, time.November, 3, 6, 15, 0, 0, losAngeles),
+			sunset:    time.Date(2024, time.November, 3, 16, 58, 0, 0, losAngeles),
+		},
+		{
+			name:      "tokyo",
+			day:       time.Date(2024, time.June, 21, 0, 0, 0, 0, tokyo),
+			latitude:  35.68,
+			longitude: 139.69,
+			sunrise:   time.Date(2024, time.June, 21, 4, 25, 0, 0, tokyo),
+			sunset:    time.Date(2024, time.June, 21, 19, 0, 0, 0, tokyo),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sunrise, sunset, ok := sunTimes(tc.day, tc.latitude, tc.longitude)
+			if !ok {
+				t.Fatalf("expected the sun to rise and set")
+			}
+			expectNear(t, "sunrise", sunrise, tc.sunrise)
+			expectNear(t, "sunset", sunset, tc.sunset)
+			if sunrise.Location() != tc.day.Location() {
+				t.Errorf("expected the times to be in %s, got %s", tc.day.Location(), sunrise.Location())
+			}
+		})
+	}
+}
+
+func TestSunTimes_Polar(t *testing.T) {
+	oslo := mustLoadLocation(t, "Europe/Oslo")
+
+	cases := map[string]time.Time{
+		"polar day":   time.Date(2024, time.June, 15, 0, 0, 0, 0, oslo),
+		"polar night": time.Date(2024, time.December, 21, 0, 0, 0, 0, oslo),
+	}
+	for name, day := range cases {
+		if _, _, ok := sunTimes(day, 69.65, 18.96); ok {
+			t.Errorf("%s: expected the sun not to rise and set in Tromsø", name)
+		}
+	}
+}
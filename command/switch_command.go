@@ -3,7 +3,6 @@ package command
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,7 +18,8 @@ func (c *SwitchCommand) Help() string {
 	helpText := `
 Usage: keylightctl switch [options] <on|off|toggle> 
 
- Switch keylights on and off.
+ Switch keylights on and off. For accessories that expose several lights, a
+ single light can be switched with -light <light>#<index>, e.g: 111A#1.
 
 General Options:
 
@@ -33,17 +33,24 @@ Switch Specific Options:
   -all
     Modify all keylights that are discovered within the timeout window
 
-  -light <light-id-or-addr>
-    Modify the provided light. Can either be a full key light name, e.g:
-    Elgato\ Key\ Light\ 111A, or a short ID, e.g: 111A. -light can be provided
-    multiple times and all provided lights will be modified. If only addresses
-    are provided then we will skip going through discovery.
+  ` + lightOptionUsage("Modify") + `
+
+  -group <group>
+    Modify all of the lights in the provided group. -group can be provided
+    multiple times.
 
   -brightness <brightness>
     When switching the light, also set the brightness to the given percentage.
 
   -temperature <temperature>
     When switching the light, also set the temperature to the given value.
+
+  -kelvin <kelvin>
+    When switching the light, also set the temperature to the given value in
+    Kelvin, e.g: 4500 or 4500K. Values outside of the range supported by the
+    light are clamped. Cannot be combined with -temperature.
+
+  ` + fanOutOptionsUsage() + `
 `
 	return strings.TrimSpace(helpText)
 }
@@ -63,19 +70,22 @@ func (c *SwitchCommand) Run(args []string) int {
 	}
 
 	var timeout time.Duration
-	var requestedLights lightListFlags
-	var allLights bool
+	var selection lightSelection
+	var fanOut lightFanOut
 	var brightness, temperature int
+	var kelvin kelvinAdjustment
 
 	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
 	flags.Usage = func() { c.UI.Output(c.Help()) }
 	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
-	flags.Var(&requestedLights, "light", "")
-	flags.BoolVar(&allLights, "all", false, "")
+	selection.AddFlags(flags)
+	selection.AllowChannels = true
+	fanOut.AddFlags(flags)
 	flags.IntVar(&brightness, "brightness", -1, "")
 	flags.IntVar(&temperature, "temperature", -1, "")
+	flags.Var(&kelvin, "kelvin", "")
 
-	if err := flags.Parse(args); err != nil {
+	if err := c.Meta.parseFlags(flags, args); err != nil {
 		return 1
 	}
 
@@ -99,7 +109,13 @@ func (c *SwitchCommand) Run(args []string) int {
 		desiredPowerState = -1 // a bit hacky, but allows for minimal changes
 	}
 
-	if brightness >= 0 || temperature >= 0 {
+	if temperature >= 0 && kelvin.IsSet {
+		c.UI.Error("Cannot specify --temperature and --kelvin together")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if brightness >= 0 || temperature >= 0 || kelvin.IsSet {
 		if desiredPowerState == -1 {
 			c.UI.Error("Cannot specify brightness and temperature while toggling light(s)")
 			c.UI.Error(commandErrorText(c))
@@ -107,23 +123,23 @@ func (c *SwitchCommand) Run(args []string) int {
 		}
 	}
 
-	if allLights && len(requestedLights) != 0 {
-		c.UI.Error("Cannot specify --all and --light together")
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
 		c.UI.Error(commandErrorText(c))
 		return 1
 	}
 
-	if !allLights && len(requestedLights) == 0 {
-		c.UI.Error("One of --all and --light must be provided")
+	if err := fanOut.Validate(); err != nil {
+		c.UI.Error(err.Error())
 		c.UI.Error(commandErrorText(c))
 		return 1
 	}
 
 	discoveryCtx, cancelFn := context.WithTimeout(context.Background(), timeout)
 	defer cancelFn()
-	found, err := c.discoverLights(discoveryCtx, requestedLights, allLights)
+	found, err := selection.Resolve(discoveryCtx, c.UI)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Failed to discover lights, err: %v", err))
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
 		return 1
 	}
 
@@ -135,111 +151,29 @@ func (c *SwitchCommand) Run(args []string) int {
 	updateCtx, updateCancelFn := context.WithTimeout(context.Background(), 15*time.Second)
 	defer updateCancelFn()
 
-	for _, light := range found {
-		opts, err := light.FetchLightOptions(updateCtx)
-		if err != nil {
-			c.UI.Error(fmt.Sprintf("Failed to fetch light options (%s), err: %v", light.Name, err))
-			return 1
-		}
-
-		newOpts := opts.Copy()
-		for _, l := range newOpts.Lights {
-			if desiredPowerState == -1 {
-				if l.On == 0 {
-					l.On = 1
+	results := fanOut.Run(updateCtx, found, func(ctx context.Context, _ int, light *keylight.KeyLight) error {
+		return modifyLightChannels(ctx, light, selection.Channels().Get(light), func(lights []*keylight.KeyLightLight) error {
+			for _, l := range lights {
+				if desiredPowerState == -1 {
+					if l.On == 0 {
+						l.On = 1
+					} else {
+						l.On = 0
+					}
 				} else {
-					l.On = 0
+					l.On = desiredPowerState
+				}
+				if temperature >= 0 {
+					l.Temperature = temperature
+				}
+				l.Temperature = kelvin.Apply(l.Temperature)
+				if brightness >= 0 {
+					l.Brightness = brightness
 				}
-			} else {
-				l.On = desiredPowerState
-			}
-			if temperature >= 0 {
-				l.Temperature = temperature
-			}
-			if brightness >= 0 {
-				l.Brightness = brightness
-			}
-		}
-
-		_, err = light.UpdateLightOptions(updateCtx, newOpts)
-		if err != nil {
-			c.UI.Error(fmt.Sprintf("Failed to update light (%s), err: %v", light.Name, err))
-			return 1
-		}
-	}
-
-	return 0
-}
-
-func (c *SwitchCommand) discoverLights(ctx context.Context, lightInfo lightListFlags, discoverAll bool) ([]*keylight.KeyLight, error) {
-	var result []*keylight.KeyLight
-
-	specifiedLights := selectLights(lightInfo, isDirectLightAddress)
-	if len(specifiedLights) != 0 {
-		for _, lightAddr := range specifiedLights {
-			parts := strings.Split(lightAddr, ":")
-			port, err := strconv.Atoi(parts[1])
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse port from light (%s), err: %w", lightAddr, err)
-			}
-			light := &keylight.KeyLight{
-				DNSAddr: parts[0],
-				Port:    port,
 			}
+			return nil
+		})
+	})
 
-			result = append(result, light)
-		}
-	}
-
-	lightsToDiscover := selectLights(lightInfo, invert(isDirectLightAddress))
-	if len(lightsToDiscover) == 0 && !discoverAll {
-		return result, nil
-	}
-
-	discovery, err := keylight.NewDiscovery()
-	if err != nil {
-		return nil, fmt.Errorf("failed to setup discoverer, err: %w", err)
-	}
-
-	discoverer := lightDiscoverer{
-		Discovery:      discovery,
-		AllLights:      discoverAll,
-		RequiredLights: lightsToDiscover,
-	}
-
-	discoveredLights, err := discoverer.Run(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	result = append(result, discoveredLights...)
-	return result, nil
-}
-
-func selectLights(lights lightListFlags, selectFunc func(string) bool) lightListFlags {
-	var result lightListFlags
-	for _, l := range lights {
-		if selectFunc(l) {
-			result = append(result, l)
-		}
-	}
-
-	return result
-}
-
-// isDirectLightAddress is a hacky implementation to check if the provided string
-// is a light identifier or an address we can use to reach a light - currently it
-// only checks whether the string contains a `:` to seperate the IP or DNS Addr
-// and the Port (as we currently require both, rather than providing a default
-// port).
-func isDirectLightAddress(light string) bool {
-	return strings.Contains(light, ":")
-}
-
-// invert inverts the result of a string -> bool func for use with the
-// `selectLights` function.
-func invert(innerFunc func(string) bool) func(string) bool {
-	return func(str string) bool {
-		return !innerFunc(str)
-	}
+	return reportResults(c.UI, results)
 }
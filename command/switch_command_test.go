@@ -0,0 +1,76 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/endocrimes/keylightctl/simulator"
+	"github.com/mitchellh/cli"
+)
+
+func TestSwitchCommand_OnOff(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{})
+
+	ui := cli.NewMockUi()
+	cmd := &SwitchCommand{Meta: Meta{UI: ui}}
+
+	runCommand(t, cmd, ui, 0, "-light", addr, "-brightness", "60", "on")
+	if l := sim.Options().Lights[0]; l.On != 1 || l.Brightness != 60 {
+		t.Errorf("expected light to be on at 60%%, got %+v", l)
+	}
+
+	runCommand(t, cmd, ui, 0, "-light", addr, "off")
+	if l := sim.Options().Lights[0]; l.On != 0 || l.Brightness != 60 {
+		t.Errorf("expected light to be off at 60%%, got %+v", l)
+	}
+}
+
+func TestSwitchCommand_Channel(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{Lights: 2})
+
+	ui := cli.NewMockUi()
+	cmd := &SwitchCommand{Meta: Meta{UI: ui}}
+
+	runCommand(t, cmd, ui, 0, "-light", addr+"#1", "on")
+	if opts := sim.Options(); opts.Lights[0].On != 0 || opts.Lights[1].On != 1 {
+		t.Errorf("expected only the second light to be on, got %+v, %+v", opts.Lights[0], opts.Lights[1])
+	}
+
+	runCommand(t, cmd, ui, 1, "-light", addr+"#2", "on")
+}
+
+func TestSwitchCommand_Failure(t *testing.T) {
+	setupTestConfig(t)
+	sim, addr := newTestLight(t, simulator.Config{FailureRate: 1})
+
+	ui := cli.NewMockUi()
+	cmd := &SwitchCommand{Meta: Meta{UI: ui}}
+
+	runCommand(t, cmd, ui, 1, "-light", addr, "on")
+	if l := sim.Options().Lights[0]; l.On != 0 {
+		t.Errorf("expected light to stay off, got %+v", l)
+	}
+}
+
+func TestSwitchCommand_Validation(t *testing.T) {
+	setupTestConfig(t)
+
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{name: "no selection", args: []string{"on"}},
+		{name: "all and light", args: []string{"-all", "-light", "111A", "on"}},
+		{name: "invalid state", args: []string{"-light", "111A", "dim"}},
+		{name: "temperature and kelvin", args: []string{"-light", "111A", "-temperature", "200", "-kelvin", "4000", "on"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := &SwitchCommand{Meta: Meta{UI: ui}}
+			runCommand(t, cmd, ui, 1, tc.args...)
+		})
+	}
+}
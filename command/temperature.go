@@ -0,0 +1,137 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kelvinDisplayStep is the granularity that Kelvin values are rounded to when
+// they are displayed, as the conversion from mireds rarely produces a round
+// number.
+const kelvinDisplayStep = 50
+
+// miredsToKelvin converts a temperature in the device unit (mireds) to Kelvin.
+func miredsToKelvin(mireds int) int {
+	if mireds <= 0 {
+		return 0
+	}
+	return int(math.Round(1e6 / float64(mireds)))
+}
+
+// kelvinToMireds converts a temperature in Kelvin to the device unit
+// (mireds), clamped to the range that is supported by the lights.
+func kelvinToMireds(kelvin int) int {
+	if kelvin <= 0 {
+		return maxTemperature
+	}
+	return clamp(int(math.Round(1e6/float64(kelvin))), minTemperature, maxTemperature)
+}
+
+// formatKelvin returns a human readable Kelvin value for the given device
+// temperature, e.g: `5000K`.
+func formatKelvin(mireds int) string {
+	kelvin := miredsToKelvin(mireds)
+	rounded := int(math.Round(float64(kelvin)/kelvinDisplayStep)) * kelvinDisplayStep
+	return fmt.Sprintf("%dK", rounded)
+}
+
+// kelvinAdjustment is a flag.Value that accepts a temperature in Kelvin,
+// optionally suffixed with K, e.g: `4500` or `4500K`. Like lightAdjustment it
+// also accepts relative changes such as `+500K`.
+type kelvinAdjustment struct {
+	lightAdjustment
+}
+
+func (a *kelvinAdjustment) String() string {
+	if !a.IsSet {
+		return ""
+	}
+	return a.lightAdjustment.String() + "K"
+}
+
+func (a *kelvinAdjustment) Set(value string) error {
+	value = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(value), "K"), "k")
+	if err := a.lightAdjustment.Set(value); err != nil {
+		return err
+	}
+
+	if !a.Relative && a.Value <= 0 {
+		return errors.New("must be a positive temperature in Kelvin")
+	}
+
+	return nil
+}
+
+// UnmarshalJSON accepts either a JSON number or a string in the same format as
+// the flag, e.g: `4500` or `"4500K"`.
+func (a *kelvinAdjustment) UnmarshalJSON(data []byte) error {
+	return a.Set(unquoteJSONValue(data))
+}
+
+// UnmarshalYAML accepts the same formats as the flag.
+func (a *kelvinAdjustment) UnmarshalYAML(value *yaml.Node) error {
+	return a.Set(value.Value)
+}
+
+// Apply returns the device temperature that results from applying the
+// adjustment to the current device temperature.
+func (a *kelvinAdjustment) Apply(current int) int {
+	if !a.IsSet {
+		return current
+	}
+
+	kelvin := a.Value
+	if a.Relative {
+		kelvin = miredsToKelvin(current) + a.Value
+	}
+
+	return kelvinToMireds(kelvin)
+}
+
+// kelvinAdjustments is a flag.Value that accepts a comma separated list of
+// temperatures in the same formats as kelvinAdjustment, one for each selected
+// light of an accessory, e.g: `3200K,5000K`.
+type kelvinAdjustments []kelvinAdjustment
+
+func (a *kelvinAdjustments) String() string {
+	var values []string
+	for idx := range *a {
+		values = append(values, (*a)[idx].String())
+	}
+	return strings.Join(values, ",")
+}
+
+func (a *kelvinAdjustments) Set(value string) error {
+	var result kelvinAdjustments
+	for _, v := range strings.Split(value, ",") {
+		var adj kelvinAdjustment
+		if err := adj.Set(v); err != nil {
+			return err
+		}
+		result = append(result, adj)
+	}
+	*a = result
+	return nil
+}
+
+// IsSet returns whether the flag was provided.
+func (a kelvinAdjustments) IsSet() bool {
+	return len(a) != 0
+}
+
+// At returns the adjustment for the idx'th selected light, like
+// lightAdjustments.At.
+func (a kelvinAdjustments) At(idx int) *kelvinAdjustment {
+	switch len(a) {
+	case 0:
+		return &kelvinAdjustment{}
+	case 1:
+		return &a[0]
+	default:
+		return &a[idx]
+	}
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestKelvinConversions(t *testing.T) {
+	cases := []struct {
+		mireds int
+		kelvin int
+	}{
+		{mireds: 143, kelvin: 6993},
+		{mireds: 200, kelvin: 5000},
+		{mireds: 213, kelvin: 4695},
+		{mireds: 344, kelvin: 2907},
+	}
+
+	for _, tc := range cases {
+		if kelvin := miredsToKelvin(tc.mireds); kelvin != tc.kelvin {
+			t.Errorf("%d mireds: expected %dK, got %dK", tc.mireds, tc.kelvin, kelvin)
+		}
+		if mireds := kelvinToMireds(tc.kelvin); mireds != tc.mireds {
+			t.Errorf("%dK: expected %d mireds, got %d", tc.kelvin, tc.mireds, mireds)
+		}
+	}
+
+	if kelvin := miredsToKelvin(0); kelvin != 0 {
+		t.Errorf("expected 0 mireds to be 0K, got %dK", kelvin)
+	}
+}
+
+func TestKelvinToMireds_Clamped(t *testing.T) {
+	cases := map[int]int{
+		10000: minTemperature,
+		2000:  maxTemperature,
+		0:     maxTemperature,
+		-100:  maxTemperature,
+	}
+
+	for kelvin, expected := range cases {
+		if mireds := kelvinToMireds(kelvin); mireds != expected {
+			t.Errorf("%dK: expected %d mireds, got %d", kelvin, expected, mireds)
+		}
+	}
+}
+
+func TestFormatKelvin(t *testing.T) {
+	cases := map[int]string{
+		143: "7000K",
+		154: "6500K",
+		200: "5000K",
+		213: "4700K",
+		344: "2900K",
+	}
+
+	for mireds, expected := range cases {
+		if s := formatKelvin(mireds); s != expected {
+			t.Errorf("%d mireds: expected %s, got %s", mireds, expected, s)
+		}
+	}
+}
+
+func TestKelvinAdjustment(t *testing.T) {
+	cases := []struct {
+		value    string
+		current  int
+		expected int
+	}{
+		{value: "5000", current: 213, expected: 200},
+		{value: "5000K", current: 213, expected: 200},
+		{value: " 5000k ", current: 213, expected: 200},
+		{value: "+300K", current: 213, expected: 200},
+		{value: "-1795K", current: 213, expected: 344},
+		{value: "+10000K", current: 213, expected: minTemperature},
+	}
+
+	for _, tc := range cases {
+		var adj kelvinAdjustment
+		if err := adj.Set(tc.value); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.value, err)
+			continue
+		}
+		if mireds := adj.Apply(tc.current); mireds != tc.expected {
+			t.Errorf("%q from %d mireds: expected %d mireds, got %d", tc.value, tc.current, tc.expected, mireds)
+		}
+	}
+
+	var unset kelvinAdjustment
+	if mireds := unset.Apply(213); mireds != 213 {
+		t.Errorf("expected an unset adjustment to leave the temperature alone, got %d mireds", mireds)
+	}
+}
+
+func TestKelvinAdjustment_Invalid(t *testing.T) {
+	for _, value := range []string{"", "warm", "0", "0K", "K"} {
+		var adj kelvinAdjustment
+		if err := adj.Set(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestKelvinAdjustments(t *testing.T) {
+	var adjs kelvinAdjustments
+	if err := adjs.Set("3200K,5000K"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := adjs.String(); s != "3200K,5000K" {
+		t.Errorf("expected the adjustments to be formatted as they were provided, got %s", s)
+	}
+	if mireds := adjs.At(1).Apply(213); mireds != 200 {
+		t.Errorf("expected the second light to be set to 200 mireds, got %d", mireds)
+	}
+
+	if err := adjs.Set("3200K,hot"); err == nil {
+		t.Errorf("expected an error for an invalid temperature")
+	}
+}
@@ -0,0 +1,404 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+const (
+	// tuiBrightnessStep and tuiKelvinStep are how far a single key press
+	// moves the brightness and temperature of the selected lights.
+	tuiBrightnessStep = 5
+	tuiKelvinStep     = 250
+
+	// tuiUpdateQueueSize is how many key presses can wait for earlier updates
+	// to be applied before further presses are dropped.
+	tuiUpdateQueueSize = 16
+)
+
+// ANSI escape sequences used to draw the terminal UI.
+const (
+	ansiAltScreen     = "\x1b[?1049h"
+	ansiMainScreen    = "\x1b[?1049l"
+	ansiHideCursor    = "\x1b[?25l"
+	ansiShowCursor    = "\x1b[?25h"
+	ansiClearScreen   = "\x1b[H\x1b[2J"
+	ansiReverseVideo  = "\x1b[7m"
+	ansiResetGraphics = "\x1b[0m"
+)
+
+// tuiKey is a key press that the terminal UI reacts to.
+type tuiKey int
+
+const (
+	tuiKeyNone tuiKey = iota
+	tuiKeyUp
+	tuiKeyDown
+	tuiKeyLeft
+	tuiKeyRight
+	tuiKeyToggle
+	tuiKeyNext
+	tuiKeyPrevious
+	tuiKeyQuit
+)
+
+// parseTUIKeys translates raw terminal input into key presses. Vim style
+// movement keys are accepted as alternatives to the arrow keys. Arrow keys are
+// accepted as both ESC [ x and ESC O x, which terminals send in application
+// cursor mode, and only a lone ESC quits.
+func parseTUIKeys(input []byte) []tuiKey {
+	var keys []tuiKey
+	for len(input) > 0 {
+		if len(input) >= 3 && input[0] == 0x1b && (input[1] == '[' || input[1] == 'O') {
+			var final byte
+			final, input = parseEscapeSequence(input)
+			switch final {
+			case 'A':
+				keys = append(keys, tuiKeyUp)
+			case 'B':
+				keys = append(keys, tuiKeyDown)
+			case 'C':
+				keys = append(keys, tuiKeyRight)
+			case 'D':
+				keys = append(keys, tuiKeyLeft)
+			case 'Z':
+				keys = append(keys, tuiKeyPrevious)
+			}
+			continue
+		}
+
+		switch input[0] {
+		case 'k':
+			keys = append(keys, tuiKeyUp)
+		case 'j':
+			keys = append(keys, tuiKeyDown)
+		case 'l':
+			keys = append(keys, tuiKeyRight)
+		case 'h':
+			keys = append(keys, tuiKeyLeft)
+		case ' ':
+			keys = append(keys, tuiKeyToggle)
+		case '\t':
+			keys = append(keys, tuiKeyNext)
+		case 'q', 0x03:
+			keys = append(keys, tuiKeyQuit)
+		case 0x1b:
+			if len(input) == 1 {
+				keys = append(keys, tuiKeyQuit)
+				break
+			}
+			// The start of an incomplete sequence, or a key pressed with
+			// Alt, which is ignored.
+			input = input[1:]
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+// parseEscapeSequence returns the final byte of the escape sequence at the
+// start of input, i.e. the first byte in the range 0x40-0x7e after the
+// introducer, and the input that follows it. Parameters, e.g. of arrow keys
+// that are pressed with a modifier, are ignored.
+func parseEscapeSequence(input []byte) (byte, []byte) {
+	for i := 2; i < len(input); i++ {
+		if input[i] >= 0x40 && input[i] <= 0x7e {
+			return input[i], input[i+1:]
+		}
+	}
+	return 0, nil
+}
+
+// tuiItem is a selectable row in the terminal UI, either a single light or a
+// group of lights.
+type tuiItem struct {
+	// Key identifies the item across redraws.
+	Key   string
+	Label string
+
+	// Lights are the agent keys of the lights that the item controls.
+	Lights []string
+}
+
+// tuiUpdate is a modification of the lights controlled by an item that is
+// waiting to be applied.
+type tuiUpdate struct {
+	Lights []string
+	Mutate func(l *keylight.KeyLightLight)
+}
+
+// lightTUI is an interactive view of the lights tracked by an agent.
+type lightTUI struct {
+	Agent  *agent
+	Groups *groupConfig
+
+	selected string
+
+	// updates holds modifications until Run applies them, so that a slow or
+	// unreachable light does not block input, and updated is signalled after
+	// each one so the UI can be redrawn.
+	updates chan tuiUpdate
+	updated chan struct{}
+
+	mu     sync.Mutex
+	status string
+}
+
+func newLightTUI(a *agent, groups *groupConfig) *lightTUI {
+	return &lightTUI{
+		Agent:   a,
+		Groups:  groups,
+		updates: make(chan tuiUpdate, tuiUpdateQueueSize),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// Run applies queued modifications until the context is cancelled.
+func (t *lightTUI) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-t.updates:
+			status := ""
+			for _, light := range update.Lights {
+				if err := t.Agent.Modify(ctx, light, update.Mutate); err != nil {
+					status = fmt.Sprintf("Failed to update %s: %v", light, err)
+				}
+			}
+			t.setStatus(status)
+
+			select {
+			case t.updated <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Updated is signalled whenever a modification has been applied.
+func (t *lightTUI) Updated() <-chan struct{} {
+	return t.updated
+}
+
+func (t *lightTUI) setStatus(status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = status
+}
+
+func (t *lightTUI) getStatus() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// Items returns the rows of the UI: every known light followed by every group
+// that has at least one known member.
+func (t *lightTUI) Items() []*tuiItem {
+	var items []*tuiItem
+	for _, key := range t.Agent.Keys() {
+		items = append(items, &tuiItem{Key: key, Label: key, Lights: []string{key}})
+	}
+
+	if t.Groups == nil {
+		return items
+	}
+
+	for _, name := range t.Groups.Names() {
+		members, err := t.Groups.Expand(lightListFlags{groupReferencePrefix + name})
+		if err != nil {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var lights []string
+		for _, member := range members {
+			selector, err := parseLightSelector(member)
+			if err != nil {
+				continue
+			}
+			for _, key := range t.Agent.match(selector) {
+				if !seen[key] {
+					seen[key] = true
+					lights = append(lights, key)
+				}
+			}
+		}
+		if len(lights) == 0 {
+			continue
+		}
+
+		items = append(items, &tuiItem{
+			Key:    groupReferencePrefix + name,
+			Label:  fmt.Sprintf("%s%s (%d light(s))", groupReferencePrefix, name, len(lights)),
+			Lights: lights,
+		})
+	}
+
+	return items
+}
+
+// current returns the index of the selected item, defaulting to the first.
+func (t *lightTUI) current(items []*tuiItem) int {
+	for idx, item := range items {
+		if item.Key == t.selected {
+			return idx
+		}
+	}
+	return 0
+}
+
+// HandleKey applies a key press and returns false if the UI should exit.
+// Modifications of the lights are queued for Run rather than applied
+// immediately.
+func (t *lightTUI) HandleKey(key tuiKey) bool {
+	items := t.Items()
+	if key == tuiKeyQuit {
+		return false
+	}
+	if len(items) == 0 {
+		return true
+	}
+
+	idx := t.current(items)
+	switch key {
+	case tuiKeyNext:
+		t.selected = items[(idx+1)%len(items)].Key
+		return true
+	case tuiKeyPrevious:
+		t.selected = items[(idx+len(items)-1)%len(items)].Key
+		return true
+	}
+
+	var mutate func(l *keylight.KeyLightLight)
+	switch key {
+	case tuiKeyUp, tuiKeyDown:
+		step := tuiBrightnessStep
+		if key == tuiKeyDown {
+			step = -step
+		}
+		adj := lightAdjustment{IsSet: true, Relative: true, Value: step}
+		mutate = func(l *keylight.KeyLightLight) {
+			l.Brightness = adj.Apply(l.Brightness, minBrightness, maxBrightness)
+		}
+	case tuiKeyLeft, tuiKeyRight:
+		step := tuiKelvinStep
+		if key == tuiKeyLeft {
+			step = -step
+		}
+		adj := kelvinAdjustment{lightAdjustment{IsSet: true, Relative: true, Value: step}}
+		mutate = func(l *keylight.KeyLightLight) {
+			l.Temperature = adj.Apply(l.Temperature)
+		}
+	case tuiKeyToggle:
+		on := 1
+		if t.anyOn(items[idx]) {
+			on = 0
+		}
+		mutate = func(l *keylight.KeyLightLight) {
+			l.On = on
+		}
+	default:
+		return true
+	}
+
+	select {
+	case t.updates <- tuiUpdate{Lights: items[idx].Lights, Mutate: mutate}:
+	default:
+		t.setStatus("Still applying earlier changes, ignoring key press")
+	}
+
+	return true
+}
+
+// anyOn returns whether any light controlled by item is switched on, so that
+// toggling a group switches it off if any member is lit.
+func (t *lightTUI) anyOn(item *tuiItem) bool {
+	for _, key := range item.Lights {
+		_, record := t.Agent.client(key)
+		for _, state := range record.Lights {
+			if state.Power == "on" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Render writes the current state of the UI to w. When interactive is true
+// the selected row is highlighted and lines are terminated with \r\n, as the
+// terminal is in raw mode while the UI is running.
+func (t *lightTUI) Render(w io.Writer, interactive bool) {
+	items := t.Items()
+	idx := t.current(items)
+
+	var lines []string
+	if interactive {
+		lines = append(lines, "keylightctl: up/down brightness, left/right temperature, space toggle, tab next, q quit", "")
+	}
+	if len(items) == 0 {
+		lines = append(lines, "  Waiting for lights...")
+	}
+
+	for i, item := range items {
+		line := fmt.Sprintf("%-40s %s", item.Label, t.describe(item))
+		if i == idx {
+			line = "> " + line
+			if interactive {
+				line = ansiReverseVideo + line + ansiResetGraphics
+			}
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+
+	if status := t.getStatus(); status != "" {
+		lines = append(lines, "", status)
+	}
+
+	newline := "\n"
+	if interactive {
+		newline = "\r\n"
+	}
+	fmt.Fprint(w, strings.Join(lines, newline)+newline)
+}
+
+// describe returns the state column of an item. Groups are summarised by the
+// number of lights that are switched on.
+func (t *lightTUI) describe(item *tuiItem) string {
+	if len(item.Lights) != 1 || isGroupReference(item.Key) {
+		on := 0
+		for _, key := range item.Lights {
+			_, record := t.Agent.client(key)
+			for _, state := range record.Lights {
+				if state.Power == "on" {
+					on++
+					break
+				}
+			}
+		}
+		return fmt.Sprintf("%d/%d on", on, len(item.Lights))
+	}
+
+	_, record := t.Agent.client(item.Lights[0])
+	if !record.Reachable {
+		return "unreachable"
+	}
+
+	var states []string
+	for _, state := range record.Lights {
+		states = append(states, fmt.Sprintf("%-3s %3d%% %s", state.Power, state.Brightness, formatKelvin(state.Temperature)))
+	}
+	label := strings.Join(states, ", ")
+	if record.DisplayName != "" {
+		label = fmt.Sprintf("%s  (%s)", label, record.DisplayName)
+	}
+	return label
+}
@@ -0,0 +1,226 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// tuiRedrawInterval is how often the terminal UI is redrawn to pick up state
+// that was refreshed in the background.
+const tuiRedrawInterval = 250 * time.Millisecond
+
+type TUICommand struct {
+	Meta
+}
+
+func (c *TUICommand) Help() string {
+	helpText := `
+Usage: keylightctl tui [options]
+
+ Open a full-screen terminal interface that shows the live state of keylights
+ and allows them to be controlled from the keyboard:
+
+   up/down, k/j       Increase or decrease the brightness
+   left/right, h/l    Make the light warmer or cooler
+   space              Toggle the power
+   tab, shift-tab     Select the next or previous light or group
+   q                  Quit
+
+ When stdout is not a terminal, the current state is printed once instead.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+TUI Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories before the interface is
+    shown (default: 5s)
+
+  -all
+    Show all keylights. Lights that are discovered while the interface is
+    open are added as they appear. This is the default if no lights or groups
+    are provided.
+
+  ` + lightOptionUsage("Show") + `
+
+  -group <group>
+    Show all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -interval <duration>
+    Sets the time between refreshes of the light state (default: 2s)
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs while showing all lights
+    (default: 30s)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TUICommand) Synopsis() string {
+	return "Control keylights from an interactive terminal interface"
+}
+
+func (c *TUICommand) Name() string { return "tui" }
+
+func (c *TUICommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout, interval, discoveryInterval time.Duration
+	var selection lightSelection
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.DurationVar(&interval, "interval", 2*time.Second, "")
+	flags.DurationVar(&discoveryInterval, "discovery-interval", 30*time.Second, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if len(selection.Lights) == 0 && len(selection.Groups) == 0 {
+		selection.AllLights = true
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval <= 0 || discoveryInterval <= 0 {
+		c.UI.Error("--interval and --discovery-interval must be positive")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 && !selection.AllLights {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	groups, err := loadGroups()
+	if err != nil {
+		c.UI.Warn(fmt.Sprintf("Ignoring groups, err: %v", err))
+	}
+
+	a := &agent{
+		UI:               c.UI,
+		DiscoveryTimeout: timeout,
+		PollInterval:     interval,
+	}
+	if selection.AllLights {
+		a.DiscoveryInterval = discoveryInterval
+	}
+
+	var wg sync.WaitGroup
+	for _, light := range found {
+		wg.Add(1)
+		go func(light *keylight.KeyLight) {
+			defer wg.Done()
+			a.observe(ctx, light)
+		}(light)
+	}
+	wg.Wait()
+
+	tui := newLightTUI(a, groups)
+
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !terminal.IsTerminal(stdin) || !terminal.IsTerminal(stdout) {
+		c.UI.Warn("Not running in a terminal, printing the current state instead")
+		tui.Render(os.Stdout, false)
+		return 0
+	}
+
+	oldState, err := terminal.MakeRaw(stdin)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to configure terminal, err: %v", err))
+		return 1
+	}
+	defer terminal.Restore(stdin, oldState)
+
+	// Discovery and polling warnings would corrupt the screen, so they are
+	// dropped while the interface is shown.
+	a.UI = &cli.BasicUi{Writer: io.Discard, ErrorWriter: io.Discard}
+
+	fmt.Fprint(os.Stdout, ansiAltScreen+ansiHideCursor)
+	defer fmt.Fprint(os.Stdout, ansiShowCursor+ansiMainScreen)
+
+	go a.Run(ctx)
+	go tui.Run(ctx)
+
+	keysCh := make(chan tuiKey)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keysCh)
+				return
+			}
+			for _, key := range parseTUIKeys(buf[:n]) {
+				select {
+				case keysCh <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(tuiRedrawInterval)
+	defer ticker.Stop()
+
+	for {
+		fmt.Fprint(os.Stdout, ansiClearScreen)
+		tui.Render(os.Stdout, true)
+
+		select {
+		case <-ctx.Done():
+			return 0
+		case key, ok := <-keysCh:
+			if !ok || !tui.HandleKey(key) {
+				return 0
+			}
+		case <-tui.Updated():
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLightTUI_HandleKeyDoesNotBlock(t *testing.T) {
+	a, sim := newTestAgent(t)
+	sim.SetLatency(500 * time.Millisecond)
+	tui := newLightTUI(a, nil)
+
+	start := time.Now()
+	if !tui.HandleKey(tuiKeyToggle) {
+		t.Fatalf("expected the UI to keep running")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected the key press to be handled immediately, took %s", elapsed)
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFn()
+	go tui.Run(ctx)
+
+	select {
+	case <-tui.Updated():
+	case <-ctx.Done():
+		t.Fatalf("expected the update to be applied")
+	}
+
+	if on := sim.Options().Lights[0].On; on != 1 {
+		t.Errorf("expected the light to be switched on, got %d", on)
+	}
+	if status := tui.getStatus(); status != "" {
+		t.Errorf("expected no status, got %q", status)
+	}
+}
+
+func TestLightTUI_QueueFull(t *testing.T) {
+	a, sim := newTestAgent(t)
+	tui := newLightTUI(a, nil)
+
+	// Nothing applies the updates, so the queue fills up.
+	for i := 0; i < tuiUpdateQueueSize+1; i++ {
+		tui.HandleKey(tuiKeyUp)
+	}
+
+	if status := tui.getStatus(); !strings.Contains(status, "ignoring key press") {
+		t.Errorf("expected the key press to be dropped, got status %q", status)
+	}
+	if on := sim.Options().Lights[0].On; on != 0 {
+		t.Errorf("expected the light to be untouched, got on=%d", on)
+	}
+}
+
+func TestParseTUIKeys(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected []tuiKey
+	}{
+		{name: "arrows", input: "\x1b[A\x1b[B\x1b[C\x1b[D", expected: []tuiKey{tuiKeyUp, tuiKeyDown, tuiKeyRight, tuiKeyLeft}},
+		{name: "application cursor arrows", input: "\x1bOA\x1bOB\x1bOC\x1bOD", expected: []tuiKey{tuiKeyUp, tuiKeyDown, tuiKeyRight, tuiKeyLeft}},
+		{name: "vim keys", input: "kjlh", expected: []tuiKey{tuiKeyUp, tuiKeyDown, tuiKeyRight, tuiKeyLeft}},
+		{name: "tab and shift tab", input: "\t\x1b[Z", expected: []tuiKey{tuiKeyNext, tuiKeyPrevious}},
+		{name: "toggle", input: " ", expected: []tuiKey{tuiKeyToggle}},
+		{name: "quit", input: "q", expected: []tuiKey{tuiKeyQuit}},
+		{name: "ctrl c", input: "\x03", expected: []tuiKey{tuiKeyQuit}},
+		{name: "lone escape", input: "\x1b", expected: []tuiKey{tuiKeyQuit}},
+		{name: "modified arrow", input: "\x1b[1;5A", expected: []tuiKey{tuiKeyUp}},
+		{name: "unknown sequence", input: "\x1b[15~j", expected: []tuiKey{tuiKeyDown}},
+		{name: "alt key", input: "\x1bqj", expected: []tuiKey{tuiKeyDown}},
+		{name: "incomplete sequence", input: "\x1b[", expected: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := parseTUIKeys([]byte(tc.input))
+			if len(keys) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, keys)
+			}
+			for idx, key := range keys {
+				if key != tc.expected[idx] {
+					t.Errorf("expected %v, got %v", tc.expected, keys)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+	"github.com/mitchellh/cli"
+)
+
+// The output formats that are supported by the watch command.
+const (
+	watchFormatText = "text"
+	watchFormatJSON = "json"
+)
+
+type WatchCommand struct {
+	Meta
+}
+
+func (c *WatchCommand) Help() string {
+	helpText := `
+Usage: keylightctl watch [options]
+
+ Watch keylights and print an event whenever a light appears, disappears, or
+ its power, brightness or temperature changes, e.g. because it was adjusted
+ from the Elgato app or the physical button. Events are printed one per line
+ so that they can be piped into other tools.
+
+General Options:
+
+  ` + generalOptionsUsage() + `
+
+Watch Specific Options:
+
+  -timeout <duration>
+    Sets the maximum time to listen for accessories before watching starts
+    (default: 5s)
+
+  -all
+    Watch all keylights. Lights that are discovered while watching are
+    added as they appear.
+
+  ` + lightOptionUsage("Watch") + `
+
+  -group <group>
+    Watch all of the lights in the provided group. -group can be provided
+    multiple times.
+
+  -interval <duration>
+    Sets the time between polls of the light state (default: 1s)
+
+  -discovery-interval <duration>
+    Sets the time between discovery runs while watching with -all
+    (default: 30s)
+
+  -format <format>
+    The format of the events, one of: text, json. JSON events are written as
+    one object per line. (default: text)
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *WatchCommand) Synopsis() string {
+	return "Stream keylight state changes as they happen"
+}
+
+func (c *WatchCommand) Name() string { return "watch" }
+
+func (c *WatchCommand) Run(args []string) int {
+	c.UI = &cli.PrefixedUi{
+		OutputPrefix: "  ",
+		InfoPrefix:   "  ",
+		ErrorPrefix:  "==> ",
+		Ui:           c.UI,
+	}
+
+	var timeout, interval, discoveryInterval time.Duration
+	var selection lightSelection
+	var format string
+
+	flags := c.Meta.FlagSet(c.Name(), FlagSetClient)
+	flags.Usage = func() { c.UI.Output(c.Help()) }
+	flags.DurationVar(&timeout, "timeout", 5*time.Second, "")
+	selection.AddFlags(flags)
+	flags.DurationVar(&interval, "interval", time.Second, "")
+	flags.DurationVar(&discoveryInterval, "discovery-interval", 30*time.Second, "")
+	flags.StringVar(&format, "format", watchFormatText, "")
+
+	if err := c.Meta.parseFlags(flags, args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		c.UI.Error("This command takes no arguments")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if err := selection.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if format != watchFormatText && format != watchFormatJSON {
+		c.UI.Error(fmt.Sprintf("Unsupported format '%s', must be one of: text, json", format))
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	if interval <= 0 || discoveryInterval <= 0 {
+		c.UI.Error("--interval and --discovery-interval must be positive")
+		c.UI.Error(commandErrorText(c))
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	discoveryCtx, cancelFn := context.WithTimeout(ctx, timeout)
+	defer cancelFn()
+	found, err := selection.Resolve(discoveryCtx, c.UI)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to resolve lights, err: %v", err))
+		return 1
+	}
+
+	if len(found) == 0 && !selection.AllLights {
+		c.UI.Error("Found no matching lights during discovery")
+		return 1
+	}
+
+	printer := &eventPrinter{Writer: os.Stdout, Format: format}
+	a := &agent{
+		UI:               c.UI,
+		DiscoveryTimeout: timeout,
+		PollInterval:     interval,
+		OnEvent:          printer.Print,
+	}
+	if selection.AllLights {
+		a.DiscoveryInterval = discoveryInterval
+	}
+
+	var wg sync.WaitGroup
+	for _, light := range found {
+		wg.Add(1)
+		go func(light *keylight.KeyLight) {
+			defer wg.Done()
+			a.observe(ctx, light)
+		}(light)
+	}
+	wg.Wait()
+
+	a.Run(ctx)
+	return 0
+}
+
+// eventPrinter writes events to Writer in the given format. Events may be
+// printed from several goroutines at once.
+type eventPrinter struct {
+	Writer io.Writer
+	Format string
+
+	mu sync.Mutex
+}
+
+func (p *eventPrinter) Print(e *lightEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Format == watchFormatJSON {
+		json.NewEncoder(p.Writer).Encode(e)
+		return
+	}
+
+	fmt.Fprintln(p.Writer, e.String())
+}
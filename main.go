@@ -34,7 +34,7 @@ func main() {
 
 	isTerminal := terminal.IsTerminal(int(os.Stdout.Fd()))
 	args := os.Args
-	color := isColorEnabled(args)
+	color := isColorEnabled(args) && command.ColorEnabled(args[1:])
 
 	// Only use colored UI if stdout is a tty, and not disabled
 	if isTerminal && color {
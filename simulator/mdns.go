@@ -0,0 +1,75 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/oleksandr/bonjour"
+)
+
+// serviceType is the mDNS service type that Elgato accessories advertise.
+const serviceType = "_elg._tcp"
+
+// Advertise advertises the simulated accessory over mDNS with the given
+// instance name, e.g: `Elgato Key Light 111A`, so that it can be found by
+// discovery. The returned function stops advertising.
+func (s *Simulator) Advertise(instance string, port int) (func(), error) {
+	info := s.Info()
+	text := []string{
+		"mf=Elgato",
+		fmt.Sprintf("dt=%d", info.HardwareBoardType),
+		fmt.Sprintf("id=%s", info.SerialNumber),
+		fmt.Sprintf("md=%s", info.ProductName),
+		"pv=1.0",
+	}
+
+	server, err := bonjour.Register(instance, serviceType, "", port, text, nil)
+	if err != nil {
+		// The hostname does not resolve to an address on some machines, e.g.
+		// in containers, so advertise an address of the machine directly.
+		hostname, hostErr := os.Hostname()
+		if hostErr != nil {
+			return nil, err
+		}
+		ip, ipErr := localIPv4()
+		if ipErr != nil {
+			return nil, err
+		}
+		server, err = bonjour.RegisterProxy(instance, serviceType, "", port, hostname, ip.String(), text, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return server.Shutdown, nil
+}
+
+// localIPv4 returns the first IPv4 address of the machine, preferring
+// addresses that are not on the loopback interface.
+func localIPv4() (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var loopback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		if !ipNet.IP.IsLoopback() {
+			return ipNet.IP, nil
+		}
+		if loopback == nil {
+			loopback = ipNet.IP
+		}
+	}
+
+	if loopback == nil {
+		return nil, errors.New("no IPv4 address found")
+	}
+	return loopback, nil
+}
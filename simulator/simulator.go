@@ -0,0 +1,332 @@
+// Package simulator implements a fake Elgato Key Light, serving the same HTTP
+// API as the real accessory. It can be used to exercise keylightctl, or any
+// other client of the API, without any lights.
+package simulator
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+// The ranges that are accepted by the lights. Values outside of these ranges
+// are clamped, as they are by the real accessory.
+const (
+	MinBrightness  = 3
+	MaxBrightness  = 100
+	MinTemperature = 143
+	MaxTemperature = 344
+)
+
+// Config describes the simulated accessory. Any zero values are replaced with
+// those of a factory reset Key Light.
+type Config struct {
+	ProductName         string
+	SerialNumber        string
+	DisplayName         string
+	FirmwareVersion     string
+	FirmwareBuildNumber int
+	HardwareBoardType   int
+
+	// Lights is the number of lights in the accessory.
+	Lights int
+
+	// Latency is added to every request.
+	Latency time.Duration
+
+	// FailureRate is the fraction of requests, between 0 and 1, that fail
+	// with an internal server error.
+	FailureRate float64
+}
+
+// Simulator is a simulated accessory. It implements http.Handler, serving the
+// Elgato API at the same paths as the real accessory.
+type Simulator struct {
+	// OnRequest is called after each request is handled, with the status
+	// code of the response.
+	OnRequest func(r *http.Request, status int)
+
+	mu          sync.Mutex
+	info        keylight.AccessoryInfo
+	options     keylight.KeyLightOptions
+	settings    keylight.KeyLightSettings
+	identified  int
+	latency     time.Duration
+	failureRate float64
+	rand        *rand.Rand
+}
+
+// New returns a simulated accessory with the given config.
+func New(cfg Config) *Simulator {
+	if cfg.ProductName == "" {
+		cfg.ProductName = "Elgato Key Light"
+	}
+	if cfg.SerialNumber == "" {
+		cfg.SerialNumber = "SIM000000001"
+	}
+	if cfg.FirmwareVersion == "" {
+		cfg.FirmwareVersion = "1.0.3"
+	}
+	if cfg.FirmwareBuildNumber == 0 {
+		cfg.FirmwareBuildNumber = 192
+	}
+	if cfg.HardwareBoardType == 0 {
+		cfg.HardwareBoardType = 53
+	}
+	if cfg.Lights <= 0 {
+		cfg.Lights = 1
+	}
+
+	s := &Simulator{
+		info: keylight.AccessoryInfo{
+			ProductName:         cfg.ProductName,
+			HardwareBoardType:   cfg.HardwareBoardType,
+			FirmwareBuildNumber: cfg.FirmwareBuildNumber,
+			FirmwareVersion:     cfg.FirmwareVersion,
+			SerialNumber:        cfg.SerialNumber,
+			DisplayName:         cfg.DisplayName,
+			Features:            []string{"lights"},
+		},
+		settings: keylight.KeyLightSettings{
+			PowerOnBehavior:       1,
+			PowerOnBrightness:     20,
+			PowerOnTemperature:    213,
+			SwitchOnDurationMs:    100,
+			SwitchOffDurationMs:   300,
+			ColorChangeDurationMs: 100,
+		},
+		latency:     cfg.Latency,
+		failureRate: cfg.FailureRate,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	s.options.Count = cfg.Lights
+	for i := 0; i < cfg.Lights; i++ {
+		s.options.Lights = append(s.options.Lights, &keylight.KeyLightLight{
+			On:          0,
+			Brightness:  s.settings.PowerOnBrightness,
+			Temperature: s.settings.PowerOnTemperature,
+		})
+	}
+
+	return s
+}
+
+// Options returns the current state of the lights.
+func (s *Simulator) Options() *keylight.KeyLightOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.options.Copy()
+}
+
+// Settings returns the current device settings.
+func (s *Simulator) Settings() keylight.KeyLightSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+// Info returns the current accessory info.
+func (s *Simulator) Info() keylight.AccessoryInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info
+}
+
+// Identified returns the number of times the accessory was asked to identify
+// itself.
+func (s *Simulator) Identified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.identified
+}
+
+// SetLatency changes the latency that is added to every request.
+func (s *Simulator) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetFailureRate changes the fraction of requests that fail.
+func (s *Simulator) SetFailureRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failureRate = rate
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.serve(rec, r)
+	if s.OnRequest != nil {
+		s.OnRequest(r, rec.status)
+	}
+}
+
+func (s *Simulator) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	latency := s.latency
+	fail := s.failureRate > 0 && s.rand.Float64() < s.failureRate
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail {
+		http.Error(w, "simulated failure", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/elgato/lights":
+		s.handleLights(w, r)
+	case "/elgato/lights/settings":
+		s.handleSettings(w, r)
+	case "/elgato/accessory-info":
+		s.handleInfo(w, r)
+	case "/elgato/identify":
+		s.handleIdentify(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// lightUpdate is a partial update of a light. Fields that are not provided
+// are left unchanged.
+type lightUpdate struct {
+	On          *int `json:"on"`
+	Brightness  *int `json:"brightness"`
+	Temperature *int `json:"temperature"`
+}
+
+func (s *Simulator) handleLights(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			Lights []lightUpdate `json:"lights"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		for idx, update := range req.Lights {
+			if idx >= len(s.options.Lights) {
+				break
+			}
+			l := s.options.Lights[idx]
+			if update.On != nil {
+				l.On = clamp(*update.On, 0, 1)
+			}
+			if update.Brightness != nil {
+				l.Brightness = clamp(*update.Brightness, MinBrightness, MaxBrightness)
+			}
+			if update.Temperature != nil {
+				l.Temperature = clamp(*update.Temperature, MinTemperature, MaxTemperature)
+			}
+		}
+		s.mu.Unlock()
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		return
+	}
+
+	writeJSON(w, s.Options())
+}
+
+// settingsUpdate is a partial update of the device settings.
+type settingsUpdate struct {
+	PowerOnBehavior       *int `json:"powerOnBehavior"`
+	PowerOnBrightness     *int `json:"powerOnBrightness"`
+	PowerOnTemperature    *int `json:"powerOnTemperature"`
+	SwitchOnDurationMs    *int `json:"switchOnDurationMs"`
+	SwitchOffDurationMs   *int `json:"switchOffDurationMs"`
+	ColorChangeDurationMs *int `json:"colorChangeDurationMs"`
+}
+
+func (s *Simulator) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req settingsUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		if req.PowerOnBehavior != nil {
+			s.settings.PowerOnBehavior = clamp(*req.PowerOnBehavior, 1, 2)
+		}
+		if req.PowerOnBrightness != nil {
+			s.settings.PowerOnBrightness = clamp(*req.PowerOnBrightness, MinBrightness, MaxBrightness)
+		}
+		if req.PowerOnTemperature != nil {
+			s.settings.PowerOnTemperature = clamp(*req.PowerOnTemperature, MinTemperature, MaxTemperature)
+		}
+		if req.SwitchOnDurationMs != nil {
+			s.settings.SwitchOnDurationMs = clamp(*req.SwitchOnDurationMs, 0, maxDurationMs)
+		}
+		if req.SwitchOffDurationMs != nil {
+			s.settings.SwitchOffDurationMs = clamp(*req.SwitchOffDurationMs, 0, maxDurationMs)
+		}
+		if req.ColorChangeDurationMs != nil {
+			s.settings.ColorChangeDurationMs = clamp(*req.ColorChangeDurationMs, 0, maxDurationMs)
+		}
+		s.mu.Unlock()
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		return
+	}
+
+	writeJSON(w, s.Settings())
+}
+
+func (s *Simulator) handleInfo(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req struct {
+			DisplayName *string `json:"displayName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.DisplayName != nil {
+			s.mu.Lock()
+			s.info.DisplayName = *req.DisplayName
+			s.mu.Unlock()
+		}
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		return
+	}
+
+	writeJSON(w, s.Info())
+}
+
+func (s *Simulator) handleIdentify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	s.mu.Lock()
+	s.identified++
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
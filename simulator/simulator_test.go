@@ -0,0 +1,268 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/endocrimes/keylight-go"
+)
+
+func newTestServer(t *testing.T, cfg Config) (*Simulator, *httptest.Server) {
+	t.Helper()
+
+	sim := New(cfg)
+	srv := httptest.NewServer(sim)
+	t.Cleanup(srv.Close)
+	return sim, srv
+}
+
+func doRequest(t *testing.T, method, url, body string, result interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if result != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestNew_Defaults(t *testing.T) {
+	sim := New(Config{Lights: 2})
+
+	info := sim.Info()
+	if info.ProductName != "Elgato Key Light" || info.SerialNumber != "SIM000000001" {
+		t.Errorf("unexpected accessory info: %+v", info)
+	}
+
+	opts := sim.Options()
+	if opts.Count != 2 || len(opts.Lights) != 2 {
+		t.Fatalf("expected 2 lights, got count=%d lights=%d", opts.Count, len(opts.Lights))
+	}
+	for idx, l := range opts.Lights {
+		if l.On != 0 || l.Brightness != 20 || l.Temperature != 213 {
+			t.Errorf("light %d: unexpected initial state %+v", idx, l)
+		}
+	}
+}
+
+func TestLights_PartialUpdate(t *testing.T) {
+	sim, srv := newTestServer(t, Config{Lights: 2})
+
+	var opts keylight.KeyLightOptions
+	status := doRequest(t, http.MethodPut, srv.URL+"/elgato/lights", `{"lights":[{"on":1},{"brightness":80}]}`, &opts)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	expected := []keylight.KeyLightLight{
+		{On: 1, Brightness: 20, Temperature: 213},
+		{On: 0, Brightness: 80, Temperature: 213},
+	}
+	for idx, want := range expected {
+		if got := *sim.Options().Lights[idx]; got != want {
+			t.Errorf("light %d: expected %+v, got %+v", idx, want, got)
+		}
+		if got := *opts.Lights[idx]; got != want {
+			t.Errorf("light %d: expected response %+v, got %+v", idx, want, got)
+		}
+	}
+}
+
+func TestLights_Clamping(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		expected keylight.KeyLightLight
+	}{
+		{
+			name:     "below minimum",
+			body:     `{"lights":[{"on":-1,"brightness":0,"temperature":10}]}`,
+			expected: keylight.KeyLightLight{On: 0, Brightness: MinBrightness, Temperature: MinTemperature},
+		},
+		{
+			name:     "above maximum",
+			body:     `{"lights":[{"on":5,"brightness":250,"temperature":1000}]}`,
+			expected: keylight.KeyLightLight{On: 1, Brightness: MaxBrightness, Temperature: MaxTemperature},
+		},
+		{
+			name:     "within range",
+			body:     `{"lights":[{"on":1,"brightness":50,"temperature":200}]}`,
+			expected: keylight.KeyLightLight{On: 1, Brightness: 50, Temperature: 200},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sim, srv := newTestServer(t, Config{})
+
+			if status := doRequest(t, http.MethodPut, srv.URL+"/elgato/lights", tc.body, nil); status != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", status)
+			}
+			if got := *sim.Options().Lights[0]; got != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLights_IgnoresExtraLights(t *testing.T) {
+	sim, srv := newTestServer(t, Config{})
+
+	status := doRequest(t, http.MethodPut, srv.URL+"/elgato/lights", `{"lights":[{"on":1},{"on":1}]}`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if opts := sim.Options(); len(opts.Lights) != 1 || opts.Lights[0].On != 1 {
+		t.Errorf("unexpected state %+v", opts.Lights)
+	}
+}
+
+func TestSettings_PartialUpdate(t *testing.T) {
+	sim, srv := newTestServer(t, Config{})
+
+	var settings keylight.KeyLightSettings
+	body := `{"powerOnBrightness":500,"switchOnDurationMs":250,"colorChangeDurationMs":-5}`
+	if status := doRequest(t, http.MethodPut, srv.URL+"/elgato/lights/settings", body, &settings); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+
+	expected := keylight.KeyLightSettings{
+		PowerOnBehavior:       1,
+		PowerOnBrightness:     MaxBrightness,
+		PowerOnTemperature:    213,
+		SwitchOnDurationMs:    250,
+		SwitchOffDurationMs:   300,
+		ColorChangeDurationMs: 0,
+	}
+	if got := sim.Settings(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if settings != expected {
+		t.Errorf("expected response %+v, got %+v", expected, settings)
+	}
+}
+
+func TestAccessoryInfo_DisplayName(t *testing.T) {
+	sim, srv := newTestServer(t, Config{DisplayName: "Desk"})
+
+	if status := doRequest(t, http.MethodPut, srv.URL+"/elgato/accessory-info", `{"displayName":"Left"}`, nil); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if got := sim.Info().DisplayName; got != "Left" {
+		t.Errorf("expected display name Left, got %q", got)
+	}
+
+	// Updates that don't include a display name leave it unchanged.
+	if status := doRequest(t, http.MethodPut, srv.URL+"/elgato/accessory-info", `{}`, nil); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if got := sim.Info().DisplayName; got != "Left" {
+		t.Errorf("expected display name Left, got %q", got)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	sim, srv := newTestServer(t, Config{})
+
+	if status := doRequest(t, http.MethodGet, srv.URL+"/elgato/identify", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to be rejected, got %d", status)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status := doRequest(t, http.MethodPost, srv.URL+"/elgato/identify", "", nil); status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", status)
+		}
+	}
+	if got := sim.Identified(); got != 2 {
+		t.Errorf("expected 2 identifications, got %d", got)
+	}
+}
+
+func TestFailureInjection(t *testing.T) {
+	sim, srv := newTestServer(t, Config{FailureRate: 1})
+
+	var mu sync.Mutex
+	var statuses []int
+	sim.OnRequest = func(r *http.Request, status int) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, status)
+	}
+
+	if status := doRequest(t, http.MethodPut, srv.URL+"/elgato/lights", `{"lights":[{"on":1}]}`, nil); status != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", status)
+	}
+	if sim.Options().Lights[0].On != 0 {
+		t.Errorf("expected a failed request to leave the light unchanged")
+	}
+
+	sim.SetFailureRate(0)
+	if status := doRequest(t, http.MethodGet, srv.URL+"/elgato/lights", "", nil); status != http.StatusOK {
+		t.Errorf("expected status 200, got %d", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []int{http.StatusInternalServerError, http.StatusOK}
+	if len(statuses) != len(expected) || statuses[0] != expected[0] || statuses[1] != expected[1] {
+		t.Errorf("expected OnRequest statuses %v, got %v", expected, statuses)
+	}
+}
+
+func TestLatencyInjection(t *testing.T) {
+	sim, srv := newTestServer(t, Config{})
+
+	latency := 100 * time.Millisecond
+	sim.SetLatency(latency)
+
+	start := time.Now()
+	if status := doRequest(t, http.MethodGet, srv.URL+"/elgato/lights", "", nil); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", status)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("expected the request to take at least %s, took %s", latency, elapsed)
+	}
+
+	// Clients that give up before the latency has passed see a timeout.
+	ctx, cancelFn := context.WithTimeout(context.Background(), latency/4)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/elgato/lights", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected the request to time out")
+	}
+}
+
+func TestUnknownPath(t *testing.T) {
+	_, srv := newTestServer(t, Config{})
+
+	if status := doRequest(t, http.MethodGet, srv.URL+"/elgato/unknown", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", status)
+	}
+	if status := doRequest(t, http.MethodDelete, srv.URL+"/elgato/lights", "", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", status)
+	}
+}